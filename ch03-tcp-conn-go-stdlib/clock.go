@@ -0,0 +1,52 @@
+package ch03
+
+import "time"
+
+// ## Injecting the Clock
+// Pinger creates its timer with `time.NewTimer`, so a test that wants to see three pings must really wait three intervals
+// (ping_example_test.go sleeps for seconds), and on a busy machine the counts can be off by one.
+//	- The Clock interface is the small part of the time package that Pinger needs: creating a timer and reading the time.
+//	- In production Pinger uses the real clock, which simply forwards to the time package.
+//	- A test can pass its own Clock with WithClock and decide exactly when each timer fires,
+//	  without sleeping and without depending on the scheduler.
+
+// Clock creates timers and tells the time.
+type Clock interface {
+	NewTimer(d time.Duration) Timer
+	Now() time.Time
+}
+
+// Timer is the part of `*time.Timer` that Pinger uses.
+//   - C is a method here (not a field) so that any type can implement it.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// realClock is the Clock backed by the time package.
+type realClock struct{}
+
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+func (realClock) Now() time.Time                 { return time.Now() }
+
+// realTimer adapts `*time.Timer` to the Timer interface.
+type realTimer struct{ *time.Timer }
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+// PingerOption configures optional Pinger behavior.
+type PingerOption func(*pingerConfig)
+
+// pingerConfig holds the settings changed by PingerOptions.
+type pingerConfig struct {
+	clock        Clock
+	body         func() []byte    // see WithBody in ping.go
+	writeTimeout time.Duration    // see WithWriteTimeout in ping.go
+	deadlines    *DeadlineManager // see WithDeadlineManager in ping.go
+}
+
+// WithClock makes Pinger create its timer with c instead of the time package.
+func WithClock(c Clock) PingerOption {
+	return func(cfg *pingerConfig) { cfg.clock = c }
+}
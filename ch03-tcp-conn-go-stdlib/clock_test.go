@@ -0,0 +1,149 @@
+package ch03
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// manualClock is a Clock whose time only moves when the test calls Advance.
+type manualClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*manualTimer
+}
+
+func newManualClock() *manualClock {
+	c := &manualClock{now: time.Unix(0, 0)}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &manualTimer{clock: c, c: make(chan time.Time, 1)}
+	t.arm(d)
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d and fires every timer that is due.
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		if t.active && !t.when.After(c.now) {
+			t.active = false
+			select {
+			case t.c <- c.now:
+			default:
+			}
+		}
+	}
+}
+
+// waitForTimer blocks until at least one timer is running.
+func (c *manualClock) waitForTimer() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for {
+		for _, t := range c.timers {
+			if t.active {
+				return
+			}
+		}
+		c.cond.Wait()
+	}
+}
+
+type manualTimer struct {
+	clock  *manualClock
+	c      chan time.Time
+	when   time.Time // guarded by clock.mu
+	active bool      // guarded by clock.mu
+}
+
+// arm starts the timer. clock.mu must be held.
+func (t *manualTimer) arm(d time.Duration) {
+	t.when = t.clock.now.Add(d)
+	t.active = true
+	t.clock.cond.Broadcast()
+}
+
+func (t *manualTimer) C() <-chan time.Time { return t.c }
+
+func (t *manualTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	wasActive := t.active
+	t.active = false
+	return wasActive
+}
+
+func (t *manualTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	wasActive := t.active
+	t.arm(d)
+	return wasActive
+}
+
+// With a manual clock the test decides when the ping timer fires, so the number of pings is exact:
+//   - half an interval must not produce a ping
+//   - every full interval must produce exactly one
+func TestPingerManualClock(t *testing.T) {
+	const pings = 5
+
+	clock := newManualClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reset := make(chan time.Duration, 1)
+	reset <- time.Second
+
+	written := make(chan struct{}, pings)
+	w := &pingRecorder{onWrite: func(int) { written <- struct{}{} }}
+
+	done := make(chan struct{})
+	go func() {
+		Pinger(ctx, w, reset, WithClock(clock))
+		close(done)
+	}()
+
+	for i := 0; i < pings; i++ {
+		clock.waitForTimer()
+		clock.Advance(500 * time.Millisecond)
+		if n := w.count(); n != i {
+			t.Fatalf("expected %d pings after half an interval; actual: %d", i, n)
+		}
+
+		clock.Advance(500 * time.Millisecond)
+		select {
+		case <-written:
+		case <-time.After(time.Second):
+			t.Fatalf("ping %d was not written", i+1)
+		}
+	}
+
+	cancel()
+	<-done
+
+	if n := w.count(); n != pings {
+		t.Fatalf("expected exactly %d pings; actual: %d", pings, n)
+	}
+}
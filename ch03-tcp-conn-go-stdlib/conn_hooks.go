@@ -0,0 +1,107 @@
+package ch03
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// ## Lifecycle Hooks for Connections
+// Instrumentation (an "active connections" gauge, a log line per connection, ...) needs two events:
+// a connection was established, and it went away — and why.
+//	- ConnHooks holds the two callbacks. URLDialer and the chapter 4 Server embed it, so both get the same fields.
+//	- OnConnect is called once, when the connection is ready to use (after the TLS handshake for tls://).
+//	- OnClose is called once, on the first Close, with the connection's terminal error:
+//		- the last error a Read or Write returned, if any
+//		- io.EOF counts as a clean end, not an error: the peer simply closed its side
+//		- a time-out is forgotten when a later call succeeds, since the connection recovered
+//		- otherwise the error returned by Close itself, which is nil for a clean close
+
+// ConnHooks are optional callbacks for a connection's lifecycle.
+type ConnHooks struct {
+	// OnConnect is called once when a connection is established.
+	OnConnect func(conn net.Conn)
+	// OnClose is called once when the connection is closed, with its terminal error (nil for a clean close).
+	OnClose func(conn net.Conn, err error)
+}
+
+// Attach calls OnConnect for conn and returns conn wrapped so that closing it calls OnClose.
+//   - Without an OnClose hook, conn is returned as is.
+func (h ConnHooks) Attach(conn net.Conn) net.Conn {
+	if h.OnConnect != nil {
+		h.OnConnect(conn)
+	}
+	if h.OnClose == nil {
+		return conn
+	}
+
+	return &hookedConn{Conn: conn, onClose: h.OnClose}
+}
+
+// hookedConn records the terminal error of a connection and reports it on Close.
+type hookedConn struct {
+	net.Conn
+	onClose func(conn net.Conn, err error)
+
+	mu  sync.Mutex
+	err error // the last Read or Write error, see above
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NetConn returns the wrapped connection.
+func (c *hookedConn) NetConn() net.Conn { return c.Conn }
+
+func (c *hookedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.record(err)
+	return n, err
+}
+
+func (c *hookedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.record(err)
+	return n, err
+}
+
+// record keeps the outcome of a Read or Write.
+func (c *hookedConn) record(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case err == nil:
+		if isTimeout(c.err) {
+			c.err = nil
+		}
+	case errors.Is(err, io.EOF):
+	default:
+		c.err = err
+	}
+}
+
+// Close closes the connection and calls OnClose the first time.
+func (c *hookedConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.closeErr = c.Conn.Close()
+
+		c.mu.Lock()
+		err := c.err
+		c.mu.Unlock()
+		if err == nil {
+			err = c.closeErr
+		}
+
+		c.onClose(c.Conn, err)
+	})
+
+	return c.closeErr
+}
+
+// isTimeout reports whether err is a network time-out.
+func isTimeout(err error) bool {
+	var nErr net.Error
+	return errors.As(err, &nErr) && nErr.Timeout()
+}
@@ -0,0 +1,122 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hookRecorder counts the hook calls and keeps the errors passed to OnClose.
+type hookRecorder struct {
+	mu       sync.Mutex
+	connects int
+	closes   []error
+}
+
+func (r *hookRecorder) hooks() ConnHooks {
+	return ConnHooks{
+		OnConnect: func(net.Conn) {
+			r.mu.Lock()
+			r.connects++
+			r.mu.Unlock()
+		},
+		OnClose: func(_ net.Conn, err error) {
+			r.mu.Lock()
+			r.closes = append(r.closes, err)
+			r.mu.Unlock()
+		},
+	}
+}
+
+// listenAndClose accepts connections and closes each one right away.
+func listenAndClose(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestConnHooksCleanClose(t *testing.T) {
+	var rec hookRecorder
+	d := URLDialer{ConnHooks: rec.hooks()}
+
+	conn, err := d.DialURL(context.Background(), "tcp://"+listenAndClose(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.connects != 1 {
+		t.Fatalf("expected OnConnect once after the dial; actual: %d", rec.connects)
+	}
+
+	// The server closes its side: io.EOF is a clean end.
+	if _, err = conn.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF; actual: %v", err)
+	}
+	_ = conn.Close()
+	_ = conn.Close()
+
+	if len(rec.closes) != 1 {
+		t.Fatalf("expected OnClose once; actual: %d", len(rec.closes))
+	}
+	if rec.closes[0] != nil {
+		t.Errorf("expected a nil terminal error; actual: %v", rec.closes[0])
+	}
+}
+
+func TestConnHooksTerminalError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	var rec hookRecorder
+	d := URLDialer{ConnHooks: rec.hooks()}
+	conn, err := d.DialURL(context.Background(), "tcp://"+listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The peer never answers: the read times out and nothing succeeds afterwards.
+	_ = conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err = conn.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected a time-out; actual: %v", err)
+	}
+	_ = conn.Close()
+
+	if len(rec.closes) != 1 || !errors.Is(rec.closes[0], os.ErrDeadlineExceeded) {
+		t.Errorf("expected OnClose once with the time-out; actual: %v", rec.closes)
+	}
+}
+
+func TestConnHooksFailedDial(t *testing.T) {
+	var rec hookRecorder
+	d := URLDialer{ConnHooks: rec.hooks()}
+
+	if _, err := d.DialURL(context.Background(), "tcp://"+deadAddress(t)); err == nil {
+		t.Fatal("expected the dial to fail")
+	}
+	if rec.connects != 0 || len(rec.closes) != 0 {
+		t.Errorf("expected no hook calls for a failed dial; actual: %d connects, %d closes", rec.connects, len(rec.closes))
+	}
+}
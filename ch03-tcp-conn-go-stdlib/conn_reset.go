@@ -0,0 +1,28 @@
+package ch03
+
+import (
+	"errors"
+	"syscall"
+)
+
+// ## Telling a Reset from a Clean Close
+// deadline_test.go reads io.EOF when the peer closes its connection. That is the GRACEFUL case:
+//	- the peer called Close (or CloseWrite), its kernel sent a FIN
+//	- everything it wrote before was delivered; we read it, then io.EOF
+//	- nothing went wrong: the peer is done. Reconnecting only makes sense if we have more to say.
+// A RESET (RST) is different. The Read or Write fails with "connection reset by peer" (ECONNRESET), when:
+//	- the peer's process crashed or was killed while data was still unread in its receive buffer
+//	- the peer closed with SO_LINGER set to 0 (`SetLinger(0)`), which aborts instead of closing
+//	- we wrote to a connection the peer had already closed, and its kernel answered with an RST
+//	- a firewall or load balancer dropped its state for the connection
+// Data may have been lost in transit, so the usual reaction is to reconnect and retry (if the request is safe to repeat).
+//
+// IsConnReset recognizes the reset through all the wrappers the net package adds
+// (`*net.OpError` → `*os.SyscallError` → `syscall.Errno`) with `errors.Is`.
+// On Windows, syscall.ECONNRESET matches WSAECONNRESET as well.
+
+// IsConnReset reports whether err means the peer reset the connection.
+//   - io.EOF (a graceful close) is not a reset.
+func IsConnReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,65 @@
+package ch03
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// closeAccepted accepts one connection on listener and passes it to closeConn once dialed is closed.
+func closeAccepted(listener net.Listener, dialed <-chan struct{}, closeConn func(*net.TCPConn)) {
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		<-dialed
+		closeConn(conn.(*net.TCPConn))
+	}()
+}
+
+func TestIsConnReset(t *testing.T) {
+	tests := []struct {
+		name  string
+		close func(*net.TCPConn)
+		reset bool
+	}{
+		{"graceful close", func(c *net.TCPConn) { _ = c.Close() }, false},
+		{"abortive close", func(c *net.TCPConn) {
+			_ = c.SetLinger(0) // Close sends an RST instead of a FIN
+			_ = c.Close()
+		}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+			dialed := make(chan struct{})
+			closeAccepted(listener, dialed, tc.close)
+
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			close(dialed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			_, err = conn.Read(make([]byte, 1))
+			if IsConnReset(err) != tc.reset {
+				t.Fatalf("IsConnReset(%v) = %t; expected %t", err, !tc.reset, tc.reset)
+			}
+			if !tc.reset && !errors.Is(err, io.EOF) {
+				t.Errorf("expected io.EOF for a graceful close; actual: %v", err)
+			}
+		})
+	}
+
+	if IsConnReset(nil) || IsConnReset(io.EOF) {
+		t.Error("nil and io.EOF must not count as resets")
+	}
+}
@@ -0,0 +1,53 @@
+package ch03
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// ## Tying a Connection's Deadline to a Context
+// Deadlines (Listing 3-9) are the connection's own way to unblock a Read or Write, but most of our code is driven by a context:
+// a request is canceled, a server shuts down, ...
+// 	- ContextConn connects the two. It registers a watcher with `context.AfterFunc`:
+//		- when ctx is done, the watcher sets a deadline in the past
+//		- every blocked Read and Write returns immediately with a time-out error (`os.ErrDeadlineExceeded`)
+//		- so do all future calls, just like after a normal deadline
+// 	- Close stops the watcher, so a connection closed before ctx is done leaves nothing behind.
+//
+// NOTE:
+// 	- The wrapped connection still has its own SetDeadline methods.
+//	  A deadline set after ctx is done replaces the past deadline and brings the connection back to life,
+//	  exactly like pushing the deadline forward in Listing 3-9.
+
+// contextConn is a net.Conn whose deadline expires when its context is done.
+type contextConn struct {
+	net.Conn
+
+	stop      func() bool
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// ContextConn returns conn wrapped so that canceling ctx unblocks its pending Read and Write calls.
+//   - Closing the returned connection closes conn and releases the watcher.
+func ContextConn(ctx context.Context, conn net.Conn) net.Conn {
+	c := &contextConn{Conn: conn}
+	c.stop = context.AfterFunc(ctx, func() {
+		// Any time in the past works; it expires the deadline right away.
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+
+	return c
+}
+
+// Close stops the context watcher and closes the underlying connection.
+func (c *contextConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.stop()
+		c.closeErr = c.Conn.Close()
+	})
+
+	return c.closeErr
+}
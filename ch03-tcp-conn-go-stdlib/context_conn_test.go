@@ -0,0 +1,100 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// A Read blocked on a silent peer must return as soon as the context is canceled.
+func TestContextConn(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// The server accepts the connection and never writes anything.
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Log(err)
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+
+	raw, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server := <-accepted; server != nil {
+		defer server.Close()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn := ContextConn(ctx, raw)
+	defer conn.Close()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err = conn.Read(make([]byte, 1))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Read returned %s after cancellation", elapsed)
+	}
+
+	var nErr net.Error
+	if !errors.As(err, &nErr) || !nErr.Timeout() {
+		t.Fatalf("expected a time-out error; actual: %v", err)
+	}
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected os.ErrDeadlineExceeded; actual: %v", err)
+	}
+}
+
+// deadlineRecorder counts SetDeadline calls.
+type deadlineRecorder struct {
+	net.Conn
+	calls atomic.Int32
+}
+
+func (d *deadlineRecorder) SetDeadline(t time.Time) error {
+	d.calls.Add(1)
+	return d.Conn.SetDeadline(t)
+}
+
+// Closing the connection before the context is done must release the watcher:
+// canceling afterwards must not touch the closed connection.
+func TestContextConnCloseStopsWatcher(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	rec := &deadlineRecorder{Conn: client}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn := ContextConn(ctx, rec)
+
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	time.Sleep(50 * time.Millisecond) // give a (wrongly) running watcher time to fire
+
+	if calls := rec.calls.Load(); calls != 0 {
+		t.Fatalf("expected no SetDeadline after Close; actual: %d calls", calls)
+	}
+
+	// A second Close is harmless.
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,105 @@
+package ch03
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// ## Read and Write Deadlines That Don't Clobber Each Other
+// SetDeadline (Listing 3-9) sets the read AND the write deadline. That's convenient for one goroutine,
+// but a connection is often shared: a reader waits for the next message with a generous deadline,
+// while a heartbeat goroutine writes pings under a short one. If the heartbeat calls SetDeadline,
+// it shortens the reader's deadline as a side effect, and the reader times out for no reason.
+//	- DeadlineManager keeps the deadline each direction asked for, and only ever calls SetReadDeadline
+//	  or SetWriteDeadline: changing one direction never touches the other.
+//	- SetDeadline on the manager sets both, but still as two separate calls with its own bookkeeping.
+//	- ReadDeadline and WriteDeadline report the current deadlines, for example to extend one relative to itself.
+//	- Pinger (ping.go) borrows the write deadline for each ping through the manager and puts the recorded one back.
+//	- A mutex keeps the recorded deadline and the connection's actual deadline in step when several goroutines
+//	  use the manager. It's never held during a Read or Write, which may block until its deadline.
+//
+// NOTE:
+//	- The manager only helps if everybody uses it: a direct conn.SetDeadline still changes both directions.
+
+// DeadlineManager sets a connection's read and write deadlines independently of each other.
+type DeadlineManager struct {
+	conn net.Conn
+
+	mu    sync.Mutex
+	read  time.Time
+	write time.Time
+}
+
+// NewDeadlineManager returns a DeadlineManager for conn, with no deadlines recorded.
+func NewDeadlineManager(conn net.Conn) *DeadlineManager {
+	return &DeadlineManager{conn: conn}
+}
+
+// SetReadDeadline sets the read deadline; the write deadline is left alone.
+func (m *DeadlineManager) SetReadDeadline(t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.conn.SetReadDeadline(t); err != nil {
+		return err
+	}
+	m.read = t
+	return nil
+}
+
+// SetWriteDeadline sets the write deadline; the read deadline is left alone.
+func (m *DeadlineManager) SetWriteDeadline(t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.conn.SetWriteDeadline(t); err != nil {
+		return err
+	}
+	m.write = t
+	return nil
+}
+
+// SetDeadline sets both deadlines to t.
+func (m *DeadlineManager) SetDeadline(t time.Time) error {
+	if err := m.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return m.SetWriteDeadline(t)
+}
+
+// ReadDeadline returns the read deadline last set through m; the zero time means none.
+func (m *DeadlineManager) ReadDeadline() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.read
+}
+
+// WriteDeadline returns the write deadline last set through m; the zero time means none.
+func (m *DeadlineManager) WriteDeadline() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.write
+}
+
+// withWriteDeadline runs fn with the write deadline set to t, then restores the recorded write deadline.
+//   - m.mu is only held to set and to restore, never during fn: a write can block until t,
+//     and the other direction (or the recorded deadline) must stay usable meanwhile.
+//   - A write deadline the application sets while fn runs is recorded, so the restore puts it in place.
+func (m *DeadlineManager) withWriteDeadline(t time.Time, fn func() error) error {
+	m.mu.Lock()
+	err := m.conn.SetWriteDeadline(t)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if rErr := m.conn.SetWriteDeadline(m.write); err == nil {
+		err = rErr
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package ch03
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDeadlineManager(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	peer, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	go func() {
+		// Drain the heartbeats; answer once they are done.
+		buf := make([]byte, 64)
+		_ = peer.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+		for {
+			if _, err := peer.Read(buf); err != nil {
+				break
+			}
+		}
+		_, _ = peer.Write([]byte("late answer"))
+	}()
+
+	m := NewDeadlineManager(conn)
+	readDeadline := time.Now().Add(5 * time.Second)
+	if err = m.SetReadDeadline(readDeadline); err != nil {
+		t.Fatal(err)
+	}
+
+	// The heartbeat: short write deadlines, pushed again and again while the reader waits.
+	heartbeat := make(chan error, 1)
+	go func() {
+		for range 5 {
+			if err := m.SetWriteDeadline(time.Now().Add(20 * time.Millisecond)); err != nil {
+				heartbeat <- err
+				return
+			}
+			if _, err := conn.Write([]byte("ping")); err != nil {
+				heartbeat <- err
+				return
+			}
+			time.Sleep(40 * time.Millisecond) // well past the write deadline
+		}
+		heartbeat <- nil
+	}()
+
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("expected the read to outlive the write deadlines; actual: %v", err)
+	}
+	if got := string(buf[:n]); got != "late answer" {
+		t.Errorf("expected %q; actual: %q", "late answer", got)
+	}
+
+	if err = <-heartbeat; err != nil {
+		t.Fatal(err)
+	}
+	if !m.ReadDeadline().Equal(readDeadline) {
+		t.Errorf("expected the read deadline to be unchanged; actual: %s", m.ReadDeadline())
+	}
+	if m.WriteDeadline().IsZero() {
+		t.Error("expected the write deadline to be recorded")
+	}
+}
+
+// A write blocked under a borrowed write deadline doesn't block the other direction.
+func TestDeadlineManagerBlockedWrite(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+
+	m := NewDeadlineManager(conn)
+	written := make(chan error, 1)
+	go func() {
+		// Nobody reads from peer: the write blocks until its deadline.
+		written <- m.withWriteDeadline(time.Now().Add(time.Second), func() error {
+			_, err := conn.Write([]byte("ping"))
+			return err
+		})
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	set := make(chan error, 1)
+	go func() { set <- m.SetReadDeadline(time.Now().Add(time.Minute)) }()
+	select {
+	case err := <-set:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("SetReadDeadline waited for the blocked write")
+	}
+
+	if err := <-written; err == nil {
+		t.Error("expected the write to time out")
+	}
+}
@@ -0,0 +1,81 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// ## Dialing Scoped IPv6 Addresses
+// An IPv6 address in an address string is enclosed in square brackets (see Listing 3-2): "[2001:ed27::1]:https".
+// Link-local addresses (fe80::/10) add one more piece, the zone, which names the interface the address belongs to:
+//	- "[fe80::1%eth0]:80" means fe80::1 reachable through eth0
+//	- The same link-local address may exist on every interface, so without the zone it is ambiguous.
+// `net.SplitHostPort` returns "fe80::1%eth0" as the host, which is easy to mishandle (for example when looking the host up
+// in a list of known IPs). SplitHostPortZone separates the three parts and rejects malformed forms early:
+//	- a missing closing bracket, or an IPv6 address without brackets
+//	- an empty zone ("[fe80::1%]:80")
+//	- a zone on something that isn't an IPv6 address ("[example.com%eth0]:80")
+// The dial helpers in this package run every address through it, and hand the zone back to `net.Dialer` untouched.
+
+// SplitHostPortZone splits addr into host, zone and port.
+//   - zone is empty for addresses without one.
+func SplitHostPortZone(addr string) (host, zone, port string, err error) {
+	host, port, err = net.SplitHostPort(addr)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	i := strings.LastIndexByte(host, '%')
+	if i < 0 {
+		return host, "", port, nil
+	}
+
+	host, zone = host[:i], host[i+1:]
+	if zone == "" {
+		return "", "", "", &net.AddrError{Err: "empty IPv6 zone", Addr: addr}
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !ip.Is6() {
+		return "", "", "", &net.AddrError{Err: "zone on a non-IPv6 host", Addr: addr}
+	}
+
+	return host, zone, port, nil
+}
+
+// joinHostPortZone is the inverse of SplitHostPortZone.
+func joinHostPortZone(host, zone, port string) string {
+	if zone != "" {
+		host += "%" + zone
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// DialContext dials address with a default `net.Dialer` after validating it with SplitHostPortZone.
+//   - Scoped addresses like "[fe80::1%eth0]:80" are passed to the dialer with their zone.
+func DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+
+	var d net.Dialer
+	return d.DialContext(ctx, network, address)
+}
+
+// normalizeAddress validates address and returns it in canonical form.
+func normalizeAddress(address string) (string, error) {
+	host, zone, port, err := SplitHostPortZone(address)
+	if err != nil {
+		return "", err
+	}
+	if port == "" {
+		return "", errors.New("missing port in address " + address)
+	}
+
+	return joinHostPortZone(host, zone, port), nil
+}
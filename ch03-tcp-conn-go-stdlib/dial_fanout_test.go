@@ -57,6 +57,8 @@ import (
 //
 // Listing 3-8: Canceling all outstanding dialers after receiving the first response
 func TestDialContextCancelFanOut(t *testing.T) {
+	assertNoLeaks(t)
+
 	// Test 1: "with at least one answer"
 	// 	- This scenario says: at least one server will respond (so the context should end with Canceled)
 	t.Run("with at least one answer", func(t *testing.T) {
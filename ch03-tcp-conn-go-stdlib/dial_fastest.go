@@ -0,0 +1,67 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ## Dialing the Fastest Replica
+// Listing 3-8 (dial_fanout_test.go) races several dialers and cancels the rest as soon as one wins,
+// but it only keeps the winner's ID and throws the connection away.
+// DialFastest turns that pattern into a helper you can use with real replicas:
+//	1. dial every address concurrently with one shared, cancelable context
+//	2. return the first connection that succeeds, together with its address
+//	3. cancel the shared context so the slower dials give up
+//	4. close any connection that still completes after the winner (nobody will use it)
+//	5. if every dial fails, return one error that contains all of their errors (`errors.Join`)
+
+// DialFastest dials all addresses concurrently and returns the first connection established and its address.
+//   - The other dials are canceled; connections that complete late are closed.
+//   - If all dials fail, the returned error wraps each individual error.
+func DialFastest(ctx context.Context, network string, addresses []string) (net.Conn, string, error) {
+	if len(addresses) == 0 {
+		return nil, "", errors.New("no addresses to dial")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	type result struct {
+		conn    net.Conn
+		address string
+		err     error
+	}
+	// Buffered, so no dialer ever blocks on sending its result.
+	results := make(chan result, len(addresses))
+	for _, address := range addresses {
+		go func() {
+			conn, err := DialContext(ctx, network, address)
+			results <- result{conn: conn, address: address, err: err}
+		}()
+	}
+
+	var errs []error
+	for remaining := len(addresses); remaining > 0; remaining-- {
+		r := <-results
+		if r.err != nil {
+			errs = append(errs, r.err)
+			continue
+		}
+
+		// We have a winner: stop the others and clean up whatever they still produce.
+		cancel()
+		go func(remaining int) {
+			for ; remaining > 0; remaining-- {
+				if late := <-results; late.conn != nil {
+					_ = late.conn.Close()
+				}
+			}
+		}(remaining - 1)
+
+		return r.conn, r.address, nil
+	}
+
+	cancel()
+	return nil, "", fmt.Errorf("all %d dials failed: %w", len(addresses), errors.Join(errs...))
+}
@@ -0,0 +1,82 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// deadAddress returns the address of a listener that was already closed, so dialing it is refused.
+func deadAddress(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+
+	return address
+}
+
+func TestDialFastest(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("one live, several dead", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+
+		go func() {
+			conn, err := listener.Accept()
+			if err == nil {
+				_ = conn.Close()
+			}
+		}()
+
+		live := listener.Addr().String()
+		addresses := []string{deadAddress(t), deadAddress(t), live, deadAddress(t)}
+
+		conn, address, err := DialFastest(ctx, "tcp", addresses)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		if address != live {
+			t.Errorf("expected the live address %s to win; actual: %s", live, address)
+		}
+		if remote := conn.RemoteAddr().String(); remote != live {
+			t.Errorf("expected a connection to %s; actual: %s", live, remote)
+		}
+	})
+
+	t.Run("all dead", func(t *testing.T) {
+		addresses := []string{deadAddress(t), deadAddress(t), deadAddress(t)}
+
+		conn, address, err := DialFastest(ctx, "tcp", addresses)
+		if err == nil {
+			_ = conn.Close()
+			t.Fatalf("expected an error; connected to %s", address)
+		}
+
+		// Every individual failure is part of the aggregated error.
+		joined, ok := errors.Unwrap(err).(interface{ Unwrap() []error })
+		if !ok {
+			t.Fatalf("expected an aggregated error; actual: %v", err)
+		}
+		if n := len(joined.Unwrap()); n != len(addresses) {
+			t.Errorf("expected %d errors; actual: %d", len(addresses), n)
+		}
+		if !errors.Is(err, syscall.ECONNREFUSED) {
+			t.Errorf("expected connection refused errors; actual: %v", err)
+		}
+	})
+}
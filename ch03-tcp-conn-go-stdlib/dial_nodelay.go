@@ -0,0 +1,42 @@
+package ch03
+
+import (
+	"context"
+	"net"
+)
+
+// ## Turning Off Nagle's Algorithm
+// Nagle's algorithm holds back small writes while earlier data is unacknowledged, to send fewer, fuller segments.
+// That's good for throughput, but request/response traffic pays with latency: a small request may wait for an ACK
+// (which the peer may itself delay) before it leaves. TCP_NODELAY turns the algorithm off.
+//	- Go already sets TCP_NODELAY on every TCP connection it creates.
+//	- DialNoDelay sets it explicitly anyway, so latency-sensitive code documents (and checks) what it relies on,
+//	  instead of depending on a default.
+//	- SetNoDelay exists only on *net.TCPConn: another network fails with ErrNotTCP before anything is dialed.
+
+// DialNoDelay dials address like DialContext and makes sure TCP_NODELAY is set on the connection.
+func DialNoDelay(ctx context.Context, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, &net.OpError{Op: "dial", Net: network, Err: ErrNotTCP}
+	}
+
+	conn, err := DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		_ = conn.Close()
+		return nil, &net.OpError{Op: "dial", Net: network, Addr: conn.RemoteAddr(), Err: ErrNotTCP}
+	}
+
+	if err = tcpConn.SetNoDelay(true); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return tcpConn, nil
+}
@@ -0,0 +1,43 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDialNoDelay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("tcp", func(t *testing.T) {
+		address := listenAndClose(t)
+
+		conn, err := DialNoDelay(ctx, "tcp", address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		tcpConn, ok := conn.(*net.TCPConn)
+		if !ok {
+			t.Fatalf("expected a *net.TCPConn; actual: %T", conn)
+		}
+		if err = tcpConn.SetNoDelay(true); err != nil {
+			t.Errorf("expected SetNoDelay to work on the connection; actual: %v", err)
+		}
+	})
+
+	t.Run("not tcp", func(t *testing.T) {
+		conn, err := DialNoDelay(ctx, "unix", filepath.Join(t.TempDir(), "socket"))
+		if err == nil {
+			_ = conn.Close()
+		}
+		if !errors.Is(err, ErrNotTCP) {
+			t.Errorf("expected ErrNotTCP; actual: %v", err)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ## Separate Budgets for DNS and Connect
+// `net.DialTimeout` (Listing 3-3) spends one timeout on everything: resolving the host name AND connecting.
+// Those two steps fail in different ways:
+//	- A slow DNS server is usually a local problem; waiting longer rarely helps, so we want to fail fast.
+//	- A slow connect may just be a far-away or busy server, which deserves more patience.
+// DialSplitTimeout gives each step its own deadline:
+//	1. Resolve host with dnsTimeout.
+//	2. Dial the resolved IPs one after the other; together they get connectTimeout.
+// The error tells which step ran out of time:
+//	- errors.Is(err, ErrResolveTimeout) or errors.Is(err, ErrConnectTimeout)
+//	- The original error is still wrapped, so `errors.As(err, &netErr)` and netErr.Timeout() work as before.
+//	- If ctx itself is canceled or expires, its error is returned as is: neither step "timed out".
+
+var (
+	// ErrResolveTimeout is returned by DialSplitTimeout when resolving the host takes longer than dnsTimeout.
+	ErrResolveTimeout = errors.New("DNS resolution timed out")
+	// ErrConnectTimeout is returned by DialSplitTimeout when connecting takes longer than connectTimeout.
+	ErrConnectTimeout = errors.New("connect timed out")
+)
+
+// SplitDialer dials with separate DNS and connect timeouts. The zero value is ready to use.
+type SplitDialer struct {
+	// Resolver looks up host names; nil means net.DefaultResolver.
+	Resolver *net.Resolver
+	// Dialer connects to the resolved IPs. Its Timeout is ignored: connectTimeout applies instead.
+	Dialer net.Dialer
+}
+
+// DialSplitTimeout dials host:port with a zero SplitDialer.
+func DialSplitTimeout(ctx context.Context, network, host, port string, dnsTimeout, connectTimeout time.Duration) (net.Conn, error) {
+	var d SplitDialer
+	return d.DialSplitTimeout(ctx, network, host, port, dnsTimeout, connectTimeout)
+}
+
+// DialSplitTimeout resolves host within dnsTimeout, then connects to one of its IPs within connectTimeout.
+//   - network must be "tcp", "tcp4" or "tcp6".
+func (d *SplitDialer) DialSplitTimeout(ctx context.Context, network, host, port string,
+	dnsTimeout, connectTimeout time.Duration) (net.Conn, error) {
+	ipNetwork, ok := map[string]string{"tcp": "ip", "tcp4": "ip4", "tcp6": "ip6"}[network]
+	if !ok {
+		return nil, net.UnknownNetworkError(network)
+	}
+
+	ips, err := d.resolve(ctx, ipNetwork, host, dnsTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	connectCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	var firstErr error
+	for _, ip := range ips {
+		conn, err := d.Dialer.DialContext(connectCtx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if connectCtx.Err() != nil {
+			break // no time left for the other IPs
+		}
+	}
+
+	if ctx.Err() == nil && errors.Is(connectCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w: %w", ErrConnectTimeout, firstErr)
+	}
+	return nil, firstErr
+}
+
+// resolve looks up the IPs of host within timeout.
+func (d *SplitDialer) resolve(ctx context.Context, network, host string, timeout time.Duration) ([]net.IPAddr, error) {
+	resolver := d.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	resolveCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	addrs, err := resolver.LookupIPAddr(resolveCtx, host)
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(resolveCtx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %w", ErrResolveTimeout, err)
+		}
+		return nil, err
+	}
+
+	// Keep only the addresses the network can use.
+	var ips []net.IPAddr
+	for _, addr := range addrs {
+		is4 := addr.IP.To4() != nil
+		if network == "ip" || (network == "ip4") == is4 {
+			ips = append(ips, addr)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no suitable address found", Name: host, IsNotFound: true}
+	}
+
+	return ips, nil
+}
@@ -0,0 +1,89 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDialSplitTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("slow DNS", func(t *testing.T) {
+		// Every DNS query hangs until the lookup gives up.
+		d := SplitDialer{Resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		}}
+
+		start := time.Now()
+		conn, err := d.DialSplitTimeout(ctx, "tcp", "slow.example.com", "80", 100*time.Millisecond, 3*time.Second)
+		if err == nil {
+			_ = conn.Close()
+			t.Fatal("expected the lookup to time out")
+		}
+		if !errors.Is(err, ErrResolveTimeout) || errors.Is(err, ErrConnectTimeout) {
+			t.Fatalf("expected ErrResolveTimeout only; actual: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected to give up after the DNS timeout; took %s", elapsed)
+		}
+	})
+
+	t.Run("slow connect", func(t *testing.T) {
+		// A dead IP: 192.0.2.1 is reserved for documentation, and the hook makes sure the connect
+		// hangs until its deadline whatever the local network does with it.
+		d := SplitDialer{Dialer: net.Dialer{
+			ControlContext: func(ctx context.Context, _, _ string, _ syscall.RawConn) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		}}
+
+		const connectTimeout = 200 * time.Millisecond
+		start := time.Now()
+		conn, err := d.DialSplitTimeout(ctx, "tcp", "192.0.2.1", "80", 100*time.Millisecond, connectTimeout)
+		if err == nil {
+			_ = conn.Close()
+			t.Fatal("expected the connect to time out")
+		}
+		if !errors.Is(err, ErrConnectTimeout) || errors.Is(err, ErrResolveTimeout) {
+			t.Fatalf("expected ErrConnectTimeout only; actual: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < connectTimeout {
+			t.Errorf("expected the connect to get its full %s; gave up after %s", connectTimeout, elapsed)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+		_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+		conn, err := DialSplitTimeout(ctx, "tcp4", "localhost", port, time.Second, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.Close()
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := DialSplitTimeout(canceled, "tcp", "127.0.0.1", "80", time.Second, time.Second)
+		if errors.Is(err, ErrResolveTimeout) || errors.Is(err, ErrConnectTimeout) {
+			t.Errorf("expected a cancellation, not a step timeout; actual: %v", err)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// ## A Timeout That Can Also Be Canceled
+// `net.DialTimeout` (and the DialTimeout of Listing 3-3) only knows a duration: once the dial started,
+// nothing from the outside can stop it, not even a shutdown.
+// DialTimeoutContext takes both:
+//	- it derives a child context with `context.WithTimeout(ctx, timeout)` and dials with DialContext,
+//	  so whichever comes first, the timeout or the parent's cancellation, aborts the dial (Listing 3-5 and 3-4 in one).
+//	- The error tells which one it was:
+//		- the timeout: a dialTimeoutError, as from the DialTimeout of dial_timeout_test.go.
+//		  errors.Is(err, ErrDialTimeout) matches it, and it is still a net.Error whose Timeout() reports true.
+//		- the parent: errors.Is(err, context.Canceled), or context.DeadlineExceeded if the parent had its own deadline.
+//		  That is the parent's business, not our timeout, so it is NOT reported as ErrDialTimeout.
+
+// ErrDialTimeout is matched by `errors.Is` for every time-out returned by DialTimeout and DialTimeoutContext.
+var ErrDialTimeout = errors.New("dial timed out")
+
+// dialTimeoutError is a time-out that still implements net.Error and also matches ErrDialTimeout.
+type dialTimeoutError struct {
+	err net.Error
+}
+
+func (e *dialTimeoutError) Error() string   { return e.err.Error() }
+func (e *dialTimeoutError) Unwrap() error   { return e.err }
+func (e *dialTimeoutError) Timeout() bool   { return e.err.Timeout() }
+func (e *dialTimeoutError) Temporary() bool { return e.err.Temporary() }
+
+// Is lets `errors.Is(err, ErrDialTimeout)` succeed.
+func (e *dialTimeoutError) Is(target error) bool { return target == ErrDialTimeout }
+
+// DialTimeoutContext dials address within timeout, unless ctx is done first.
+func DialTimeoutContext(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+	var d net.Dialer
+	return dialTimeoutContext(ctx, &d, network, address, timeout)
+}
+
+// dialTimeoutContext is DialTimeoutContext with a given Dialer, so tests can hook into the connect.
+func dialTimeoutContext(ctx context.Context, d *net.Dialer, network, address string, timeout time.Duration) (net.Conn, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := d.DialContext(dialCtx, network, address)
+	if err == nil {
+		return conn, nil
+	}
+
+	// Only our own deadline is a time-out of this dial; the parent's cancellation or deadline is returned as is.
+	var nErr net.Error
+	if ctx.Err() == nil && errors.Is(dialCtx.Err(), context.DeadlineExceeded) && errors.As(err, &nErr) {
+		return nil, &dialTimeoutError{err: nErr}
+	}
+	return nil, err
+}
@@ -0,0 +1,70 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDialTimeoutContext(t *testing.T) {
+	// hang makes every connect wait until its context is done, whatever the network does.
+	hang := &net.Dialer{
+		ControlContext: func(ctx context.Context, _, _ string, _ syscall.RawConn) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		start := time.Now()
+		conn, err := dialTimeoutContext(context.Background(), hang, "tcp", "192.0.2.1:80", 100*time.Millisecond)
+		if err == nil {
+			_ = conn.Close()
+			t.Fatal("expected the dial to time out")
+		}
+		if !errors.Is(err, ErrDialTimeout) {
+			t.Fatalf("expected ErrDialTimeout; actual: %v", err)
+		}
+		if nErr, ok := err.(net.Error); !ok || !nErr.Timeout() {
+			t.Errorf("expected a net.Error time-out; actual: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected to give up after the timeout; took %s", elapsed)
+		}
+	})
+
+	t.Run("parent canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+
+		start := time.Now()
+		conn, err := dialTimeoutContext(ctx, hang, "tcp", "192.0.2.1:80", time.Minute)
+		if err == nil {
+			_ = conn.Close()
+			t.Fatal("expected the dial to be canceled")
+		}
+		if !errors.Is(err, context.Canceled) || errors.Is(err, ErrDialTimeout) {
+			t.Fatalf("expected context.Canceled only; actual: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected the cancellation to stop the dial; took %s", elapsed)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+
+		conn, err := DialTimeoutContext(context.Background(), "tcp", listener.Addr().String(), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.Close()
+	})
+}
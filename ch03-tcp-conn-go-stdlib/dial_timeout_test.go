@@ -1,6 +1,7 @@
 package ch03
 
 import (
+	"errors"
 	"net"
 	"syscall"
 	"testing"
@@ -65,9 +66,29 @@ func DialTimeout(network, address string, timeout time.Duration) (net.Conn, erro
 	//  - So in practice, this function:
 	// 		- Does not always (or in this case) establish a connection
 	// 		- And only returns an artificial `timeout error`.
-	return d.Dial(network, address)
+	// 	- If the error is a time-out, we wrap it in a `dialTimeoutError` so callers can also check it with
+	// 	  `errors.Is(err, ErrDialTimeout)` without losing the `net.Error` behavior.
+	conn, err := d.Dial(network, address)
+	if err != nil {
+		if nErr, ok := err.(net.Error); ok && nErr.Timeout() {
+			return nil, &dialTimeoutError{err: nErr}
+		}
+		return nil, err
+	}
+	return conn, nil
 }
 
+// ## Making the time-out error work with `errors.Is`
+// The error DialTimeout returns is a `*net.OpError` wrapping our fake `*net.DNSError`.
+// 	- Checking it requires a type assertion to `net.Error` and a call to `Timeout()`.
+// 	- Many callers prefer the sentinel style: `if errors.Is(err, ErrDialTimeout) { ... }`
+// 	- So DialTimeout wraps every time-out error in a `dialTimeoutError`, which:
+// 		- still implements `net.Error` (Timeout and Temporary are delegated to the original error)
+// 		- reports itself as `ErrDialTimeout` through its `Is` method
+// 		- returns the original error from `Unwrap`, so `errors.As(err, &dnsErr)` keeps working too
+
+// ErrDialTimeout and dialTimeoutError live in dial_timeout.go, next to DialTimeoutContext, which returns them too.
+
 // Unlike the `net.Dial` function, the DialTimeout function includes an additional argument, the time-out duration (3).
 // Since the time-out duration is five seconds in this case, the connection attempt will time out if a connection isn’t successful within five seconds.
 // In this test, you dial 10.0.0.0, which is a non-routable IP address, meaning your connection attempt assuredly times out.
@@ -105,6 +126,37 @@ func TestDialTimeout(t *testing.T) {
 	}
 }
 
+// This test checks both ways of recognizing the time-out:
+//   - the sentinel check with `errors.Is`
+//   - the original `net.Error` path, including `Temporary()` and the wrapped `*net.DNSError`
+func TestDialTimeoutErrorsIs(t *testing.T) {
+	c, err := DialTimeout("tcp", "10.0.0.1:http", 5*time.Second)
+	if err == nil {
+		_ = c.Close()
+		t.Fatal("connection did not time out")
+	}
+
+	if !errors.Is(err, ErrDialTimeout) {
+		t.Errorf("expected errors.Is(err, ErrDialTimeout); actual: %v", err)
+	}
+
+	var nErr net.Error
+	if !errors.As(err, &nErr) {
+		t.Fatalf("expected a net.Error; actual: %T", err)
+	}
+	if !nErr.Timeout() {
+		t.Error("error is not a timeout")
+	}
+	if !nErr.Temporary() {
+		t.Error("error is not temporary")
+	}
+
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		t.Errorf("expected the wrapped *net.DNSError; actual: %T", err)
+	}
+}
+
 // ## `net.Dial` and `net.Dialer` are both for "connecting", but one is a ready-made function and one is a configurable tool.
 //
 // ### What is `net.Dial`?
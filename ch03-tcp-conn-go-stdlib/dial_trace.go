@@ -0,0 +1,50 @@
+package ch03
+
+import (
+	"context"
+	"net"
+)
+
+// ## Tracing Dials
+// In a distributed trace, a slow request often turns out to be a slow connect. To see that, every dial
+// should show up as a span: a named, timed operation that ended with or without an error.
+// Pulling in a tracing library for one call is too much, so the dialer only knows a tiny interface:
+//	- Tracer.StartSpan(name) starts a span and returns the function that ends it.
+//	- TracingDialer calls StartSpan right before DialContext and the returned function right after it,
+//	  with DialContext's error (nil on success). The span's duration is the time between the two calls.
+//	- The span is named after the operation, like "dial tcp 127.0.0.1:80".
+//	- An adapter for OpenTelemetry (or any other tracer) is a few lines: start a span, end it, record err.
+//	- A nil Tracer disables tracing: DialContext is then exactly net.Dialer's.
+
+// Tracer starts spans. It must be safe for concurrent use.
+type Tracer interface {
+	// StartSpan starts a span called name. The returned function ends it with the operation's error.
+	StartSpan(name string) func(err error)
+}
+
+// TracingDialer is a net.Dialer that records every dial as a span. The zero value dials without tracing.
+type TracingDialer struct {
+	net.Dialer
+
+	// Tracer receives a span per dial; nil disables tracing.
+	Tracer Tracer
+}
+
+// DialContext dials address like net.Dialer.DialContext, inside a span of Tracer.
+func (d *TracingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.Tracer == nil {
+		return d.Dialer.DialContext(ctx, network, address)
+	}
+
+	end := d.Tracer.StartSpan("dial " + network + " " + address)
+	conn, err := d.Dialer.DialContext(ctx, network, address)
+	end(err)
+
+	return conn, err
+}
+
+// Dial dials address like net.Dialer.Dial, inside a span of Tracer.
+//   - Without it, the embedded net.Dialer's Dial would be promoted and skip the span.
+func (d *TracingDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
@@ -0,0 +1,138 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// span is what fakeTracer recorded about one span.
+type span struct {
+	name     string
+	ended    bool
+	err      error
+	duration time.Duration
+}
+
+// fakeTracer records every span it starts.
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*span
+}
+
+func (f *fakeTracer) StartSpan(name string) func(err error) {
+	s := &span{name: name}
+	f.mu.Lock()
+	f.spans = append(f.spans, s)
+	f.mu.Unlock()
+
+	start := time.Now()
+	return func(err error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		s.ended, s.err, s.duration = true, err, time.Since(start)
+	}
+}
+
+// only returns the one span recorded, or fails the test.
+func (f *fakeTracer) only(t *testing.T) span {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.spans) != 1 {
+		t.Fatalf("expected 1 span; actual: %d", len(f.spans))
+	}
+	return *f.spans[0]
+}
+
+func TestTracingDialer(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+
+		tracer := new(fakeTracer)
+		d := TracingDialer{Tracer: tracer}
+		conn, err := d.DialContext(context.Background(), "tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.Close()
+
+		s := tracer.only(t)
+		if want := "dial tcp " + listener.Addr().String(); s.name != want {
+			t.Errorf("expected span %q; actual: %q", want, s.name)
+		}
+		if !s.ended || s.err != nil {
+			t.Errorf("expected the span to end without an error; ended: %t, err: %v", s.ended, s.err)
+		}
+	})
+
+	t.Run("Dial", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+
+		tracer := new(fakeTracer)
+		d := TracingDialer{Tracer: tracer}
+		conn, err := d.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.Close()
+
+		if s := tracer.only(t); !s.ended || s.err != nil {
+			t.Errorf("expected the span to end without an error; ended: %t, err: %v", s.ended, s.err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		tracer := new(fakeTracer)
+		d := TracingDialer{Tracer: tracer}
+		// Every connect waits until its context is done, whatever the network does.
+		d.ControlContext = func(ctx context.Context, _, _ string, _ syscall.RawConn) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		conn, err := d.DialContext(ctx, "tcp", "192.0.2.1:80")
+		if err == nil {
+			_ = conn.Close()
+			t.Fatal("expected the dial to time out")
+		}
+
+		s := tracer.only(t)
+		if !s.ended || !errors.Is(s.err, context.DeadlineExceeded) || s.err != err {
+			t.Errorf("expected the span to end with the dial's error %v; ended: %t, err: %v", err, s.ended, s.err)
+		}
+		if s.duration < 100*time.Millisecond {
+			t.Errorf("expected the span to last until the timeout; actual: %s", s.duration)
+		}
+	})
+
+	t.Run("nil tracer", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+
+		var d TracingDialer
+		conn, err := d.DialContext(context.Background(), "tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.Close()
+	})
+}
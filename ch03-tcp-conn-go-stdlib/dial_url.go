@@ -0,0 +1,91 @@
+package ch03
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/url"
+)
+
+// ## Dialing from a URL
+// Configuration files usually describe a server as one string: "tcp://db:5432" or "tls://api.example.com:443".
+// DialURL reads that string and does the right thing for the scheme:
+//	- tcp:// dials a plain TCP connection (through DialContext, so scoped IPv6 addresses work too)
+//	- tls:// dials TCP and completes a TLS handshake before returning
+//	- any other scheme fails with ErrUnsupportedScheme, before anything touches the network
+// For TLS the minimum protocol version is enforced: TLS 1.2 by default.
+//	- A server that only offers older versions fails the handshake instead of silently getting a weak connection.
+
+// ErrUnsupportedScheme is returned by DialURL for a scheme other than tcp or tls.
+var ErrUnsupportedScheme = errors.New("unsupported URL scheme")
+
+// URLDialer dials tcp:// and tls:// URLs. The zero value is ready to use.
+type URLDialer struct {
+	// TLSConfig is used for tls:// URLs; nil means a default config.
+	// ServerName defaults to the URL's host.
+	TLSConfig *tls.Config
+	// MinVersion is the minimum TLS version for tls:// URLs.
+	// It only raises TLSConfig.MinVersion, never lowers it; when both are zero, TLS 1.2 is used.
+	MinVersion uint16
+
+	// ConnHooks, if set, are attached to every connection the dialer returns.
+	ConnHooks
+}
+
+// DialURL dials rawurl with a zero URLDialer.
+func DialURL(ctx context.Context, rawurl string) (net.Conn, error) {
+	var d URLDialer
+	return d.DialURL(ctx, rawurl)
+}
+
+// DialURL dials the address in rawurl according to its scheme.
+func (d *URLDialer) DialURL(ctx context.Context, rawurl string) (net.Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+	switch u.Scheme {
+	case "tcp":
+		conn, err = DialContext(ctx, "tcp", u.Host)
+	case "tls":
+		conn, err = d.dialTLS(ctx, u)
+	default:
+		return nil, &net.OpError{Op: "dial", Net: u.Scheme, Err: ErrUnsupportedScheme}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Attach(conn), nil
+}
+
+// dialTLS dials u.Host and performs the TLS handshake within ctx.
+func (d *URLDialer) dialTLS(ctx context.Context, u *url.URL) (net.Conn, error) {
+	cfg := &tls.Config{}
+	if d.TLSConfig != nil {
+		cfg = d.TLSConfig.Clone()
+	}
+	cfg.MinVersion = max(cfg.MinVersion, d.MinVersion)
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = u.Hostname()
+	}
+
+	conn, err := DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConn := tls.Client(conn, cfg)
+	if err = tlsConn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
@@ -0,0 +1,174 @@
+package ch03
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// selfSignedCert returns a certificate for 127.0.0.1 and a pool that trusts it.
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+// serveTLS accepts connections on a TLS listener with cfg and completes each handshake.
+func serveTLS(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = conn.(*tls.Conn).Handshake()
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestDialURL(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("tcp", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+		go func() {
+			conn, err := listener.Accept()
+			if err == nil {
+				_ = conn.Close()
+			}
+		}()
+
+		conn, err := DialURL(ctx, "tcp://"+listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		if _, ok := conn.(*tls.Conn); ok {
+			t.Error("expected a plaintext connection for tcp://")
+		}
+	})
+
+	cert, pool := selfSignedCert(t)
+
+	t.Run("tls", func(t *testing.T) {
+		addr := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+		d := URLDialer{TLSConfig: &tls.Config{RootCAs: pool}}
+		conn, err := d.DialURL(ctx, "tls://"+addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		tlsConn, ok := conn.(*tls.Conn)
+		if !ok {
+			t.Fatalf("expected a *tls.Conn; actual: %T", conn)
+		}
+		if v := tlsConn.ConnectionState().Version; v < tls.VersionTLS12 {
+			t.Errorf("expected at least TLS 1.2; actual: %#x", v)
+		}
+	})
+
+	t.Run("tls rejects TLS 1.0", func(t *testing.T) {
+		addr := serveTLS(t, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS10,
+			MaxVersion:   tls.VersionTLS10,
+		})
+
+		d := URLDialer{TLSConfig: &tls.Config{RootCAs: pool}}
+		conn, err := d.DialURL(ctx, "tls://"+addr)
+		if err == nil {
+			_ = conn.Close()
+			t.Fatal("expected the handshake to fail against a TLS 1.0 server")
+		}
+
+		// Lowering the minimum proves it's the setting that rejected the server.
+		d.MinVersion = tls.VersionTLS10
+		conn, err = d.DialURL(ctx, "tls://"+addr)
+		if err != nil {
+			t.Fatalf("expected TLS 1.0 to work with MinVersion TLS 1.0: %v", err)
+		}
+		_ = conn.Close()
+	})
+
+	t.Run("tls keeps a higher TLSConfig.MinVersion", func(t *testing.T) {
+		addr := serveTLS(t, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MaxVersion:   tls.VersionTLS12,
+		})
+
+		d := URLDialer{
+			TLSConfig:  &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS13},
+			MinVersion: tls.VersionTLS12,
+		}
+		conn, err := d.DialURL(ctx, "tls://"+addr)
+		if err == nil {
+			_ = conn.Close()
+			t.Fatal("expected the handshake to fail against a TLS 1.2 server")
+		}
+	})
+
+	t.Run("unsupported scheme", func(t *testing.T) {
+		_, err := DialURL(ctx, "udp://127.0.0.1:53")
+		if !errors.Is(err, ErrUnsupportedScheme) {
+			t.Fatalf("expected ErrUnsupportedScheme; actual: %v", err)
+		}
+	})
+}
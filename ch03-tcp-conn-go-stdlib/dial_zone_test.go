@@ -0,0 +1,86 @@
+package ch03
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestSplitHostPortZone(t *testing.T) {
+	tests := []struct {
+		addr             string
+		host, zone, port string
+		err              bool
+	}{
+		{addr: "127.0.0.1:80", host: "127.0.0.1", port: "80"},
+		{addr: "[2001:ed27::1]:https", host: "2001:ed27::1", port: "https"},    // global IPv6
+		{addr: "[fe80::1%eth0]:80", host: "fe80::1", zone: "eth0", port: "80"}, // scoped link-local
+		{addr: "[fe80::1%25]:80", host: "fe80::1", zone: "25", port: "80"},     // numeric zone (interface index)
+		{addr: "[fe80::1%eth0:80", err: true},                                  // missing closing bracket
+		{addr: "fe80::1%eth0]:80", err: true},                                  // missing opening bracket
+		{addr: "fe80::1:80", err: true},                                        // IPv6 without brackets
+		{addr: "[fe80::1%]:80", err: true},                                     // empty zone
+		{addr: "[example.com%eth0]:80", err: true},                             // zone on a hostname
+		{addr: "[127.0.0.1%eth0]:80", err: true},                               // zone on IPv4
+	}
+
+	for _, tc := range tests {
+		host, zone, port, err := SplitHostPortZone(tc.addr)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected an error; got host=%q zone=%q port=%q", tc.addr, host, zone, port)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.addr, err)
+			continue
+		}
+		if host != tc.host || zone != tc.zone || port != tc.port {
+			t.Errorf("%s: expected (%q, %q, %q); actual (%q, %q, %q)",
+				tc.addr, tc.host, tc.zone, tc.port, host, zone, port)
+		}
+	}
+}
+
+// Dialing the IPv6 loopback with the loopback interface as zone must reach the listener.
+func TestDialContextZone(t *testing.T) {
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 loopback not available:", err)
+	}
+	defer listener.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var zone string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			zone = iface.Name
+			break
+		}
+	}
+	if zone == "" {
+		t.Skip("no loopback interface")
+	}
+
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	conn, err := DialContext(context.Background(), "tcp6", joinHostPortZone("::1", zone, port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	if _, err = DialContext(context.Background(), "tcp6", "[::1%]:"+port); err == nil {
+		t.Fatal("expected an error for an empty zone")
+	}
+}
@@ -0,0 +1,110 @@
+package ch03
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ## Catching Leaked Goroutines
+// Many tests here start goroutines (dialers, Pingers, servers) and rely on wg.Wait, a done channel
+// or a deferred Close to stop them. Nothing checks that they really did. assertNoLeaks does:
+//	1. Before the test: take a snapshot of the IDs of all running goroutines.
+//	2. After the test (t.Cleanup, so after the test's own defers): list the goroutines again.
+//	   Any goroutine that is not in the snapshot was started by the test and is still running.
+//	3. Ignore goroutines that belong to the test framework (first frame in package testing).
+//	   Runtime goroutines (GC, finalizers, ...) never show up: runtime.Stack hides system goroutines.
+//
+// ### Timing
+// Stopping a goroutine is usually asynchronous: cancel() or Close() returns before the goroutine
+// notices and exits. Checking right away would fail on goroutines that are just about to return.
+// So the check polls: it retries every few milliseconds and only fails if extra goroutines are
+// still there after leakSettle. A real leak is blocked forever, so it is still reported;
+// a goroutine on its way out gets enough time to leave.
+//
+// NOTE:
+//   - Goroutine IDs are never reused, so comparing IDs (not counts) can't mistake a new goroutine for an old one.
+//   - Don't use it in parallel tests: goroutines of other tests running at the same time would look like leaks.
+
+// leakSettle is how long the goroutines started by a test get to exit after it ends.
+const leakSettle = time.Second
+
+// assertNoLeaks fails t if goroutines started during the test are still running after it (see above).
+func assertNoLeaks(t *testing.T) {
+	t.Helper()
+
+	before := make(map[string]bool)
+	for _, g := range goroutines() {
+		before[goroutineID(g)] = true
+	}
+
+	t.Cleanup(func() {
+		if leaked := leakedGoroutines(before, leakSettle); len(leaked) > 0 {
+			t.Errorf("%d leaked goroutine(s):\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+		}
+	})
+}
+
+// leakedGoroutines waits up to settle for all goroutines not in before to exit and returns the stacks of those that didn't.
+func leakedGoroutines(before map[string]bool, settle time.Duration) []string {
+	deadline := time.Now().Add(settle)
+	for {
+		var leaked []string
+		for _, g := range goroutines() {
+			if !before[goroutineID(g)] && !ignoredGoroutine(g) {
+				leaked = append(leaked, g)
+			}
+		}
+
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			return leaked
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// goroutines returns the stack of every goroutine, one string each, starting with "goroutine N [state]:".
+func goroutines() []string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return strings.Split(strings.TrimSpace(string(buf[:n])), "\n\n")
+		}
+		buf = make([]byte, 2*len(buf)) // the dump didn't fit
+	}
+}
+
+// goroutineID returns the N of "goroutine N [state]:".
+func goroutineID(stack string) string {
+	id, _, _ := strings.Cut(strings.TrimPrefix(stack, "goroutine "), " ")
+	return id
+}
+
+// ignoredGoroutine reports whether the goroutine belongs to the test framework.
+func ignoredGoroutine(stack string) bool {
+	_, frames, _ := strings.Cut(stack, "\n")
+	return strings.HasPrefix(frames, "testing.")
+}
+
+// The helper must see a goroutine that never exits, and must not report one that exits during the settle time.
+func TestLeakedGoroutines(t *testing.T) {
+	before := make(map[string]bool)
+	for _, g := range goroutines() {
+		before[goroutineID(g)] = true
+	}
+
+	block := make(chan struct{})
+	go func() { <-block }()
+
+	leaked := leakedGoroutines(before, 50*time.Millisecond)
+	if len(leaked) != 1 || !strings.Contains(leaked[0], "TestLeakedGoroutines") {
+		t.Fatalf("expected the blocked goroutine to be reported; actual: %q", leaked)
+	}
+
+	time.AfterFunc(50*time.Millisecond, func() { close(block) })
+	if leaked = leakedGoroutines(before, leakSettle); len(leaked) > 0 {
+		t.Errorf("expected no leaks after the goroutine exited; actual: %q", leaked)
+	}
+}
@@ -0,0 +1,85 @@
+package ch03
+
+import (
+	"errors"
+	"net"
+)
+
+// ## Listening on Every Address Explicitly
+// Listing 3-1 notes that an empty host ("tcp", ":8080") listens on all addresses with ONE socket.
+// Sometimes one listener per address is what you want instead:
+//	- to know which local address a connection came in on (and log or route by it)
+//	- to stop listening on one interface without touching the others
+// ListenAll enumerates the addresses of every interface that is up and opens a listener on each of them:
+//	- "tcp" takes IPv4 and IPv6 addresses, "tcp4" and "tcp6" only their own family.
+//	- IPv6 link-local addresses (fe80::/10) need their interface as the zone ("[fe80::1%eth0]:80"),
+//	  which is why the interfaces are walked one by one (net.Interfaces) rather than with net.InterfaceAddrs.
+//	- If any listener fails, the ones already opened are closed and the error is returned: all or nothing.
+//	- With port "0" (or ""), every listener gets its own random port: check each listener's Addr().
+
+// ListenAll opens a listener on port for every address of every interface that is up.
+func ListenAll(network, port string) ([]net.Listener, error) {
+	var want4, want6 bool
+	switch network {
+	case "tcp":
+		want4, want6 = true, true
+	case "tcp4":
+		want4 = true
+	case "tcp6":
+		want6 = true
+	default:
+		return nil, net.UnknownNetworkError(network)
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var listeners []net.Listener
+	closeAll := func() {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+
+			ip, zone := ipNet.IP, ""
+			is4 := ip.To4() != nil
+			if (is4 && !want4) || (!is4 && !want6) {
+				continue
+			}
+			if !is4 && ip.IsLinkLocalUnicast() {
+				zone = iface.Name
+			}
+
+			l, err := net.Listen(network, joinHostPortZone(ip.String(), zone, port))
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+			listeners = append(listeners, l)
+		}
+	}
+
+	if len(listeners) == 0 {
+		return nil, &net.OpError{Op: "listen", Net: network, Err: errors.New("no usable local address")}
+	}
+
+	return listeners, nil
+}
@@ -0,0 +1,36 @@
+package ch03
+
+import (
+	"net"
+	"testing"
+)
+
+func TestListenAll(t *testing.T) {
+	listeners, err := ListenAll("tcp", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var loopback bool
+	for _, l := range listeners {
+		t.Logf("listening on %s", l.Addr())
+		if l.Addr().(*net.TCPAddr).IP.IsLoopback() {
+			loopback = true
+		}
+	}
+	if !loopback {
+		t.Error("expected a listener on a loopback address")
+	}
+
+	for _, l := range listeners {
+		if err = l.Close(); err != nil {
+			t.Errorf("closing %s: %v", l.Addr(), err)
+		}
+	}
+}
+
+func TestListenAllUnknownNetwork(t *testing.T) {
+	if _, err := ListenAll("udp", "0"); err == nil {
+		t.Error("expected an error for a non-TCP network")
+	}
+}
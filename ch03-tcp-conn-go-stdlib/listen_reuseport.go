@@ -0,0 +1,43 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+)
+
+// ## Sharing a Port Between Listeners (SO_REUSEPORT)
+// Normally a second listener on a port that is already in use fails with "address already in use".
+// With the SO_REUSEPORT socket option, several sockets may bind the same address and port:
+//	- the kernel spreads incoming connections across them
+//	- so you can run several server processes (or several listeners in one process) on one port
+// The option must be set after the socket is created and BEFORE it is bound.
+//	- That is exactly the moment `net.ListenConfig.Control` runs, the same hook the dial tests
+//	  use on `net.Dialer` (see dial_timeout_test.go).
+//	- Not every platform has SO_REUSEPORT. The platform-specific part (setReusePort) lives in the
+//	  reuseport_*.go files, selected by build tags; on other platforms ListenReusePort returns ErrReusePortUnsupported.
+
+// ErrReusePortUnsupported is returned by ListenReusePort on platforms without SO_REUSEPORT.
+var ErrReusePortUnsupported = errors.New("SO_REUSEPORT is not supported on this platform")
+
+// ListenReusePort listens like `net.Listen`, but with SO_REUSEPORT set on the socket,
+// so other listeners created the same way can share the address.
+func ListenReusePort(network, address string) (net.Listener, error) {
+	if !reusePortSupported {
+		return nil, &net.OpError{Op: "listen", Net: network, Err: ErrReusePortUnsupported}
+	}
+
+	lc := net.ListenConfig{
+		Control: func(_, _ string, c syscall.RawConn) error {
+			var err error
+			cErr := c.Control(func(fd uintptr) { err = setReusePort(fd) })
+			if cErr != nil {
+				return cErr
+			}
+			return err
+		},
+	}
+
+	return lc.Listen(context.Background(), network, address)
+}
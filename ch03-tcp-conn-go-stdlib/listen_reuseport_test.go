@@ -0,0 +1,48 @@
+//go:build (linux && !(mips || mipsle || mips64 || mips64le)) || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package ch03
+
+import (
+	"net"
+	"testing"
+)
+
+// Two listeners with SO_REUSEPORT can bind the same port, and both accept connections.
+func TestListenReusePort(t *testing.T) {
+	first, err := ListenReusePort("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	second, err := ListenReusePort("tcp", first.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	if first.Addr().String() != second.Addr().String() {
+		t.Fatalf("expected the same address; actual: %s and %s", first.Addr(), second.Addr())
+	}
+
+	// Without the option, the port is taken.
+	if l, err := net.Listen("tcp", first.Addr().String()); err == nil {
+		_ = l.Close()
+		t.Fatal("expected a plain listener to fail on a shared port")
+	}
+
+	// Both listeners are usable.
+	for _, l := range []net.Listener{first, second} {
+		go func() {
+			conn, err := l.Accept()
+			if err == nil {
+				_ = conn.Close()
+			}
+		}()
+	}
+	conn, err := net.Dial("tcp", first.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+}
@@ -0,0 +1,113 @@
+package ch03
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+)
+
+// ## Listening for TLS with a Handshake Timeout
+// `tls.Listen` returns connections before the handshake has happened: it runs later, on the first Read or Write.
+// A client that connects and then sends nothing keeps that connection (and the goroutine serving it) busy forever.
+// ListenTLS completes the handshake before Accept returns the connection:
+//	1. The accept loop hands every new TCP connection to its own goroutine.
+//		- A slow handshake must not hold up Accept for the clients behind it.
+//	2. That goroutine sets a deadline of handshakeTimeout and calls `Handshake()` explicitly.
+//	3. If the handshake fails or the deadline passes, the connection is closed and Accept never sees it.
+//	4. Otherwise the deadline is cleared and the ready *tls.Conn is passed to Accept.
+// Close stops the accept loop. Handshakes still in progress end at their deadline and their connections are closed.
+
+// DefaultHandshakeTimeout is used by ListenTLS when handshakeTimeout is zero.
+const DefaultHandshakeTimeout = 10 * time.Second
+
+// ListenTLS listens like `tls.Listen`, but Accept only returns connections that completed
+// their TLS handshake within handshakeTimeout (zero means DefaultHandshakeTimeout).
+func ListenTLS(network, address string, config *tls.Config, handshakeTimeout time.Duration) (net.Listener, error) {
+	inner, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	if handshakeTimeout <= 0 {
+		handshakeTimeout = DefaultHandshakeTimeout
+	}
+
+	l := &tlsListener{
+		Listener: inner,
+		config:   config,
+		timeout:  handshakeTimeout,
+		ready:    make(chan net.Conn),
+		failed:   make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+	go l.acceptLoop()
+
+	return l, nil
+}
+
+// tlsListener accepts TCP connections and returns them once their handshake is done.
+type tlsListener struct {
+	net.Listener // the TCP listener
+	config       *tls.Config
+	timeout      time.Duration
+
+	ready  chan net.Conn // connections that completed the handshake
+	failed chan struct{} // closed when the accept loop stops; err holds the reason
+	err    error
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Accept waits for the next connection that completed its handshake.
+func (l *tlsListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.ready:
+		return conn, nil
+	case <-l.failed:
+		return nil, l.err
+	}
+}
+
+// Close stops the listener. Connections that were not accepted yet are closed.
+func (l *tlsListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return l.Listener.Close()
+}
+
+func (l *tlsListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			l.err = err
+			close(l.failed)
+			return
+		}
+
+		go l.handshake(conn)
+	}
+}
+
+// handshake completes the TLS handshake on conn within the timeout and passes it to Accept.
+func (l *tlsListener) handshake(conn net.Conn) {
+	tlsConn := tls.Server(conn, l.config)
+
+	err := conn.SetDeadline(time.Now().Add(l.timeout))
+	if err == nil {
+		err = tlsConn.Handshake()
+	}
+	if err == nil {
+		err = conn.SetDeadline(time.Time{})
+	}
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	select {
+	case l.ready <- tlsConn:
+	case <-l.closed:
+		_ = tlsConn.Close()
+	}
+}
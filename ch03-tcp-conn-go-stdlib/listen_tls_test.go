@@ -0,0 +1,79 @@
+package ch03
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// A client that connects but never starts the handshake is closed after the handshake timeout,
+// and it doesn't keep a real TLS client from being accepted in the meantime.
+func TestListenTLSHandshakeTimeout(t *testing.T) {
+	assertNoLeaks(t)
+
+	cert, pool := selfSignedCert(t)
+	const timeout = 200 * time.Millisecond
+
+	listener, err := ListenTLS("tcp", "127.0.0.1:", &tls.Config{Certificates: []tls.Certificate{cert}}, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	// The stalled client: a raw TCP connection that sends no TLS bytes.
+	start := time.Now()
+	stalled, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stalled.Close()
+
+	// A real client connects after it and is accepted well before the timeout.
+	client, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	select {
+	case conn := <-accepted:
+		if _, ok := conn.(*tls.Conn); !ok {
+			t.Errorf("expected a *tls.Conn; actual: %T", conn)
+		}
+		_ = conn.Close()
+	case <-time.After(timeout / 2):
+		t.Fatal("the TLS client was not accepted before the stalled one timed out")
+	}
+
+	// The server closes the stalled connection once the timeout passes.
+	_ = stalled.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = stalled.Read(make([]byte, 1))
+	elapsed := time.Since(start)
+	if !errors.Is(err, io.EOF) && !IsConnReset(err) {
+		t.Fatalf("expected the server to close the stalled connection; actual: %v", err)
+	}
+	if elapsed < timeout {
+		t.Errorf("closed after %s; expected no sooner than the %s handshake timeout", elapsed, timeout)
+	}
+
+	// Accept never returned the stalled connection, and fails once the listener is closed.
+	_ = listener.Close()
+	if conn, ok := <-accepted; ok {
+		t.Errorf("expected no more connections; got one from %s", conn.RemoteAddr())
+	}
+}
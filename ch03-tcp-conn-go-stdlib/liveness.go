@@ -0,0 +1,88 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ## One Place to Configure Liveness
+// There are two ways to notice a dead peer, and they complement each other:
+//	- TCP keepalive (chapter 4): the kernel sends empty probes on an idle connection.
+//		- free for the application and works with any protocol
+//		- but it only proves the peer's KERNEL is alive, and some middleboxes drop the probes
+//	- an application heartbeat (Listing 3-10): Pinger writes "ping" every interval.
+//		- proves the peer's application is reading, and passes through anything that passes data
+//		- but the protocol on the connection must tolerate the ping messages
+// ApplyLivenessPolicy sets up either or both from one LivenessPolicy:
+//	- KeepAlive enables TCP keepalive on the connection; KeepAlivePeriod sets the probe interval (zero: OS default).
+//	- PingInterval > 0 starts a Pinger writing to the connection every PingInterval.
+//	- The returned stop function stops the Pinger and waits for it; keepalive stays as configured.
+//
+// NOTE:
+//	- The Pinger writes to the connection from its own goroutine. Other writers must not interleave with it
+//	  in a way the protocol can't handle (see the chapter 4 Monitor for a framed heartbeat).
+
+// ErrNotTCP is returned when TCP keepalive is requested for a connection that isn't TCP.
+var ErrNotTCP = errors.New("not a TCP connection")
+
+// LivenessPolicy describes how a connection's liveness is checked. The zero value does nothing.
+type LivenessPolicy struct {
+	KeepAlive       bool          // enable TCP keepalive
+	KeepAlivePeriod time.Duration // time between keepalive probes; zero keeps the OS default
+	PingInterval    time.Duration // time between heartbeat pings; zero disables the heartbeat
+}
+
+// ApplyLivenessPolicy configures TCP keepalive and/or a heartbeat on conn.
+//   - stop ends the heartbeat; it is safe to call more than once and never nil.
+func ApplyLivenessPolicy(conn net.Conn, policy LivenessPolicy) (stop func(), err error) {
+	if policy.KeepAlive {
+		if err = enableKeepAlive(conn, policy.KeepAlivePeriod); err != nil {
+			return func() {}, err
+		}
+	}
+
+	if policy.PingInterval <= 0 {
+		return func() {}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reset := make(chan time.Duration, 1)
+	reset <- policy.PingInterval
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Pinger(ctx, conn, reset)
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}, nil
+}
+
+// enableKeepAlive turns on TCP keepalive on conn, looking through wrappers (NetConn) for the *net.TCPConn.
+func enableKeepAlive(conn net.Conn, period time.Duration) error {
+	for {
+		switch c := conn.(type) {
+		case *net.TCPConn:
+			if err := c.SetKeepAlive(true); err != nil {
+				return err
+			}
+			if period > 0 {
+				return c.SetKeepAlivePeriod(period)
+			}
+			return nil
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return ErrNotTCP
+		}
+	}
+}
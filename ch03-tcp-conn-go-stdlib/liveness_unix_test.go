@@ -0,0 +1,114 @@
+//go:build unix
+
+package ch03
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// keepAliveEnabled reads SO_KEEPALIVE from the socket of conn.
+func keepAliveEnabled(t *testing.T, conn *net.TCPConn) bool {
+	t.Helper()
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var value int
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		value, sockErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)
+	})
+	if err != nil || sockErr != nil {
+		t.Fatal(err, sockErr)
+	}
+
+	return value != 0
+}
+
+func TestApplyLivenessPolicy(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	// Keepalive is off from the start, so the policy is what turns it on.
+	d := net.Dialer{KeepAlive: -1}
+	conn, err := d.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	peer := <-accepted
+	defer peer.Close()
+
+	if keepAliveEnabled(t, conn.(*net.TCPConn)) {
+		t.Fatal("keepalive should be off before the policy is applied")
+	}
+
+	const interval = 20 * time.Millisecond
+	stop, err := ApplyLivenessPolicy(conn, LivenessPolicy{
+		KeepAlive:       true,
+		KeepAlivePeriod: 15 * time.Second,
+		PingInterval:    interval,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !keepAliveEnabled(t, conn.(*net.TCPConn)) {
+		t.Error("expected keepalive to be enabled")
+	}
+
+	// Five pings arrive, roughly one per interval.
+	start := time.Now()
+	buf := make([]byte, 4)
+	for i := 0; i < 5; i++ {
+		if _, err = io.ReadFull(peer, buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, []byte("ping")) {
+			t.Fatalf("expected a ping; actual: %q", buf)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 4*interval {
+		t.Errorf("5 pings in %s: faster than the %s interval", elapsed, interval)
+	}
+
+	// After stop, no more pings.
+	stop()
+	stop()
+	_ = peer.SetReadDeadline(time.Now().Add(5 * interval))
+	var nErr net.Error
+	if n, err := peer.Read(buf); !errors.As(err, &nErr) || !nErr.Timeout() {
+		t.Errorf("expected silence after stop; read %d bytes, %v", n, err)
+	}
+}
+
+func TestApplyLivenessPolicyNotTCP(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	stop, err := ApplyLivenessPolicy(a, LivenessPolicy{KeepAlive: true})
+	stop()
+	if !errors.Is(err, ErrNotTCP) {
+		t.Errorf("expected ErrNotTCP; actual: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package ch03
 import (
 	"context"
 	"io"
+	"net"
 	"time"
 )
 
@@ -57,12 +58,89 @@ import (
 //	- If you wanted, you could use this case to keep track of any consecutive time-outs that occur while writing to the writer.
 //		- To do this, you could pass in the context’s cancel function and call it here if you reach a threshold of consecutive time-outs.
 
+// ### Sending status with every ping
+// A ping only says "I'm alive". For free, it can carry a little telemetry too: a sequence number, the current load, ...
+//	- WithBody sets a function that produces the ping's bytes; Pinger calls it once per ping, right before writing.
+//	- Without it, every ping is the string "ping", as in Listing 3-10.
+//	- Pinger writes the body as is. Wrapping it in a frame is up to w (the chapter 4 Monitor sends it as a Ping payload).
+
+// ### Not getting stuck on a write
+// A ping to a peer that stopped reading fills the socket's send buffer, and then w.Write blocks.
+// Canceling ctx doesn't help: Pinger is stuck inside Write, not in its select.
+//	- When w is a net.Conn, Pinger sets a write deadline before every ping and clears it afterwards.
+//	- A ping that can't be written in time fails with a timeout error, and Pinger returns like on any other write error.
+//	- The deadline is one ping interval unless WithWriteTimeout says otherwise: a ping that isn't out by the time
+//	  the next one is due will not get out at all.
+//	- Other writers are a no-op: Pinger can't interrupt their Write.
+//	- A net.Conn can't tell which write deadline it has, so Pinger can't restore one it doesn't know about.
+//	  Without a DeadlineManager, Pinger owns the write deadline: after each ping the connection has none,
+//	  whatever the application had set.
+//	- So an application that uses write deadlines on the same connection MUST set them through a DeadlineManager
+//	  (deadline_manager.go) and pass that with WithDeadlineManager. Pinger then puts the application's deadline
+//	  back after every ping.
+
+// WithWriteTimeout sets how long a ping may take to write when w is a net.Conn. Zero means one ping interval.
+func WithWriteTimeout(d time.Duration) PingerOption {
+	return func(cfg *pingerConfig) { cfg.writeTimeout = d }
+}
+
+// WithDeadlineManager makes Pinger restore the write deadline recorded in m after every ping.
+//   - m must manage the same connection Pinger writes to.
+//   - It's required when the application sets write deadlines on that connection: without it, every ping clears them.
+func WithDeadlineManager(m *DeadlineManager) PingerOption {
+	return func(cfg *pingerConfig) { cfg.deadlines = m }
+}
+
+// writePing writes one ping to w, within timeout if w is a net.Conn.
+//   - With deadlines, the write deadline recorded there is restored afterwards; otherwise the deadline is cleared.
+func writePing(w io.Writer, body []byte, timeout time.Duration, deadlines *DeadlineManager) error {
+	conn, ok := w.(net.Conn)
+	if !ok {
+		_, err := w.Write(body)
+		return err
+	}
+
+	// The kernel compares the deadline with the real time, so it comes from the time package, not from the Clock.
+	deadline := time.Now().Add(timeout)
+	if deadlines != nil {
+		return deadlines.withWriteDeadline(deadline, func() error {
+			_, err := conn.Write(body)
+			return err
+		})
+	}
+
+	if err := conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	_, err := conn.Write(body)
+	if err != nil {
+		return err
+	}
+
+	return conn.SetWriteDeadline(time.Time{})
+}
+
+// defaultPingBody is the body of every ping without WithBody.
+func defaultPingBody() []byte { return []byte("ping") }
+
+// WithBody makes Pinger write body() as each ping instead of "ping".
+func WithBody(body func() []byte) PingerOption {
+	return func(cfg *pingerConfig) { cfg.body = body }
+}
+
 // ---
 // Step 0) Default value
 //   - If interval is not specified, a ping is performed every 30 seconds.
 const defaultPingInterval = 30 * time.Second
 
-func Pinger(ctx context.Context, w io.Writer, reset <-chan time.Duration) {
+// Pinger writes "ping" to w every interval until ctx is canceled.
+//   - Optional behavior (for example a fake clock in tests, see clock.go) is passed as PingerOptions.
+//   - When w is a net.Conn, each ping clears its write deadline unless WithDeadlineManager is given.
+func Pinger(ctx context.Context, w io.Writer, reset <-chan time.Duration, opts ...PingerOption) {
+	cfg := pingerConfig{clock: realClock{}, body: defaultPingBody}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 
 	// Step 1) Get the initial interval
 	// 	- This section has three states:
@@ -87,19 +165,35 @@ func Pinger(ctx context.Context, w io.Writer, reset <-chan time.Duration) {
 	}
 
 	// Step 3) Making the timer
-	//	- Creates a timer that sends a signal to `timer.C` after a specified interval.
-	timer := time.NewTimer(interval) // (2)
+	//	- Creates a timer that sends a signal to `timer.C()` after a specified interval.
+	//	- The timer comes from the configured Clock (the time package unless WithClock was used).
+	timer := cfg.clock.NewTimer(interval) // (2)
 	// Step 4) Timer cleaning with defer
 	// 	- This means:
 	//		- When the function finishes, stop the timer
 	// 		- If stopping “fails” it means:
 	// 			- The timer may have “fired” at the same time and there is something left in timer.C
 	// 			- So it reads timer.C once to empty the channel
+	// 			- Without blocking: if we return from the timer case, the value was already received and nothing is left
 	// 		- Purpose:
 	// 			- Preventing timers from getting stuck/leaking resources/behaving strangely
+	// 	- It also drains the reset channel without blocking:
+	// 		- A value may be in flight on reset at the moment ctx is canceled.
+	// 		- Nobody will ever read it once we return, so we take what is there (at most its capacity plus one)
+	// 		  and a sender waiting on a full channel is released instead of blocking forever.
 	defer func() {
 		if !timer.Stop() {
-			<-timer.C
+			select {
+			case <-timer.C():
+			default:
+			}
+		}
+		for i := 0; i <= cap(reset); i++ {
+			select {
+			case <-reset:
+			default:
+				return
+			}
 		}
 	}()
 
@@ -120,19 +214,37 @@ func Pinger(ctx context.Context, w io.Writer, reset <-chan time.Duration) {
 	// 		- If the write fails:
 	// 			- This means there is probably a connection problem → the function returns (stops)
 	// 			- The comment says that here you can count the number of consecutive timeouts and make a more serious decision (e.g. reconnect).
+	// 	- Cancellation takes priority:
+	// 		- When several cases are ready, select picks one at random.
+	// 		- So if ctx is canceled while a reset is in flight or the timer just fired, select may still pick
+	// 		  reset or timer.C, and we could send one ping after cancellation.
+	// 		- We therefore check ctx first at the top of every iteration, and once more right before writing.
 	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
 		select {
 		case <-ctx.Done(): // (3)
 			return
 		case newInterval := <-reset: // (4)
 			if !timer.Stop() {
-				<-timer.C
+				<-timer.C()
 			}
 			if newInterval > 0 {
 				interval = newInterval
 			}
-		case <-timer.C: // (5)
-			if _, err := w.Write([]byte("ping")); err != nil {
+		case <-timer.C(): // (5)
+			if ctx.Err() != nil {
+				return
+			}
+			timeout := cfg.writeTimeout
+			if timeout <= 0 {
+				timeout = interval
+			}
+			if err := writePing(w, cfg.body(), timeout, cfg.deadlines); err != nil {
 				// track and act on consecutive timeouts here
 
 				return
@@ -2,8 +2,12 @@ package ch03
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
 	"io"
 	"net"
+	"os"
+	"sync"
 	"testing"
 	"time"
 )
@@ -42,6 +46,8 @@ import (
 //   - Server: goroutine inside go func(){...} that accepts and executes Pinger.
 //   - Client: below function that dials and reads pings and sends PONG!!! once.
 func TestPingerAdvanceDeadline(t *testing.T) {
+	assertNoLeaks(t)
+
 	// A) Server part (goroutine)
 	// A-1) Preparation
 	// 	- `done` is to let us know that the server is finished.
@@ -200,3 +206,225 @@ func TestPingerAdvanceDeadline(t *testing.T) {
 		t.Fatalf("expected EOF at 9 seconds; actual %s", end)
 	}
 }
+
+// pingRecorder counts the pings written to it and calls onWrite after every write.
+type pingRecorder struct {
+	mu      sync.Mutex
+	pings   int
+	onWrite func(pings int)
+}
+
+func (r *pingRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	r.pings++
+	pings := r.pings
+	r.mu.Unlock()
+
+	if r.onWrite != nil {
+		r.onWrite(pings)
+	}
+	return len(p), nil
+}
+
+func (r *pingRecorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pings
+}
+
+// This test starts spamming the reset channel from inside the first ping write and then cancels the context.
+//   - When Pinger gets control back, a reset value is ready, the context is done, and soon the timer fires too.
+//   - Once ctx is canceled, Pinger must not write another ping, no matter which select case is ready.
+//   - Pinger must return promptly, and the spamming goroutine must not stay blocked on its send.
+func TestPingerCancelWhileResetting(t *testing.T) {
+	assertNoLeaks(t)
+
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		reset := make(chan time.Duration, 1)
+		reset <- time.Millisecond
+
+		stop := make(chan struct{})
+		spammed := make(chan struct{})
+		spam := func() {
+			defer close(spammed)
+			for {
+				select {
+				case reset <- 0:
+				case <-stop:
+					return
+				}
+			}
+		}
+
+		w := &pingRecorder{onWrite: func(pings int) {
+			if pings == 1 {
+				go spam()
+				time.Sleep(5 * time.Millisecond) // let a reset value get in flight
+				cancel()
+			}
+		}}
+
+		done := make(chan struct{})
+		go func() {
+			Pinger(ctx, w, reset)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Pinger did not exit after cancellation")
+		}
+		close(stop)
+		<-spammed
+
+		if pings := w.count(); pings != 1 {
+			t.Fatalf("expected exactly 1 ping; actual: %d", pings)
+		}
+	}
+}
+
+// Every ping carries what the body function returns at that moment: here an increasing sequence number.
+func TestPingerWithBody(t *testing.T) {
+	assertNoLeaks(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r, w := io.Pipe()
+	defer r.Close()
+
+	var seq uint64
+	body := func() []byte {
+		seq++
+		return binary.BigEndian.AppendUint64(nil, seq)
+	}
+
+	reset := make(chan time.Duration, 1)
+	reset <- 10 * time.Millisecond
+	go Pinger(ctx, w, reset, WithBody(body))
+
+	buf := make([]byte, 8)
+	var last uint64
+	for i := 0; i < 5; i++ {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			t.Fatal(err)
+		}
+		n := binary.BigEndian.Uint64(buf)
+		if n <= last {
+			t.Fatalf("expected increasing sequence numbers; got %d after %d", n, last)
+		}
+		last = n
+	}
+}
+
+// A peer that never reads fills the send buffer, and the next ping write blocks.
+// The write deadline turns that into a timeout, and Pinger returns instead of hanging.
+func TestPingerWriteTimeout(t *testing.T) {
+	assertNoLeaks(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn // never read from
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	peer, ok := <-accepted
+	if !ok {
+		t.Fatal("accept failed")
+	}
+	defer peer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reset := make(chan time.Duration, 1)
+	reset <- time.Millisecond
+	body := make([]byte, 1<<20) // a few of these fill any send buffer
+	done := make(chan struct{})
+	go func() {
+		Pinger(ctx, conn, reset, WithBody(func() []byte { return body }), WithWriteTimeout(50*time.Millisecond))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Pinger is still blocked writing to a peer that doesn't read")
+	}
+}
+
+// With a DeadlineManager, the application's write deadline is back in place after a ping.
+func TestPingerDeadlineManager(t *testing.T) {
+	assertNoLeaks(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	pinged := make(chan struct{})
+	go func() {
+		peer, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer peer.Close()
+
+		buf := make([]byte, 4)
+		if _, err = io.ReadFull(peer, buf); err == nil {
+			close(pinged)
+		}
+		_, _ = io.Copy(io.Discard, peer)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The application's deadline has passed: its writes must keep failing, pings or not.
+	m := NewDeadlineManager(conn)
+	if err = m.SetWriteDeadline(time.Unix(1, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reset := make(chan time.Duration, 1)
+	reset <- time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		Pinger(ctx, conn, reset, WithDeadlineManager(m))
+		close(done)
+	}()
+
+	select {
+	case <-pinged:
+	case <-time.After(time.Second):
+		t.Fatal("the ping wasn't written")
+	}
+	cancel()
+	<-done
+
+	if _, err = conn.Write([]byte("app")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected the application's write deadline to survive the ping; actual: %v", err)
+	}
+}
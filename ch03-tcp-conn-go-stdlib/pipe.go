@@ -0,0 +1,21 @@
+package ch03
+
+import "net"
+
+// ## In-Memory Connection Pairs
+// Most tests in this repository listen on 127.0.0.1 and dial themselves. That is realistic, but every test
+// pays for a socket pair, and on a busy machine it competes for ephemeral ports.
+//	- Pipe returns two connected `net.Conn` values that live entirely in memory: what one writes, the other reads.
+//	- Deadlines work: since Go 1.10 `net.Pipe` implements SetDeadline, SetReadDeadline and SetWriteDeadline,
+//	  and an expired deadline returns `os.ErrDeadlineExceeded`, exactly like a TCP connection.
+//	  So Pipe needs no wrapper for them; it is `net.Pipe` under a name that says what it is for.
+//
+// NOTE:
+//	- A pipe has NO buffer: a Write blocks until the other end Reads all of it.
+//	  Two peers that both write before they read deadlock on a pipe, while TCP's socket buffers would hide it.
+//	  Tests of such protocols should keep using a real TCP pair.
+
+// Pipe returns a pair of connected in-memory connections with working deadlines.
+func Pipe() (net.Conn, net.Conn) {
+	return net.Pipe()
+}
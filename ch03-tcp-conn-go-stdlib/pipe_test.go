@@ -0,0 +1,54 @@
+package ch03
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPipeReadDeadline(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	if err := b.SetReadDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err := b.Read(make([]byte, 1)) // nothing is ever written
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected os.ErrDeadlineExceeded; actual: %v", err)
+	}
+	var nErr net.Error
+	if !errors.As(err, &nErr) || !nErr.Timeout() {
+		t.Errorf("expected a time-out net.Error; actual: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Read returned after %s", elapsed)
+	}
+
+	// Pushing the deadline forward brings the pipe back to life.
+	if err = b.SetReadDeadline(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	go func() { _, _ = a.Write([]byte("x")) }()
+	if _, err = io.ReadFull(b, make([]byte, 1)); err != nil {
+		t.Fatalf("read after clearing the deadline: %v", err)
+	}
+}
+
+func TestPipeWriteDeadline(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	// Nobody reads from b, so the unbuffered write can only time out.
+	_ = a.SetWriteDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := a.Write([]byte("x")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected os.ErrDeadlineExceeded; actual: %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build linux && !(mips || mipsle || mips64 || mips64le)
+
+package ch03
+
+import "syscall"
+
+// The syscall package doesn't define SO_REUSEPORT for every Linux architecture, but its value is the same on all of these.
+const soReusePort = 0xf
+
+const reusePortSupported = true
+
+func setReusePort(fd uintptr) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+}
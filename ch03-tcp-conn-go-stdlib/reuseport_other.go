@@ -0,0 +1,7 @@
+//go:build !(linux && !(mips || mipsle || mips64 || mips64le)) && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package ch03
+
+const reusePortSupported = false
+
+func setReusePort(uintptr) error { return ErrReusePortUnsupported }
@@ -0,0 +1,207 @@
+package ch03
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ## A Dialer That Learns Which Replica Is Fastest
+// DialFastest starts every dial at once. That is fair, but wasteful: every dial opens a connection on some replica,
+// even though we only keep one. And it forgets everything between calls.
+// SmartDialer remembers how fast each address connected and uses it next time:
+//	- It keeps an EWMA (exponentially weighted moving average) of the connect latency per address:
+//	  ewma = Alpha × sample + (1 - Alpha) × ewma. Recent dials count most, but one outlier doesn't dominate.
+//	- Each Dial sorts the addresses by that average (addresses it has never connected to come last)
+//	  and starts them one after the other:
+//		- the fastest address gets a head start of Stagger before the second one is tried,
+//		  the second one a head start of Stagger before the third, and so on
+//		- a dial that fails starts the next address right away instead of waiting out its head start
+//		- the first connection established wins; the other dials are canceled, late connections are closed
+//	- The winner's latency updates its average. A failed dial counts as failurePenalty, so a broken replica
+//	  drops to the end of the list (and climbs back once it connects again).
+//	- With a healthy fast replica, usually only ONE dial happens per call.
+// A SmartDialer is safe for concurrent use and keeps its state for its whole life.
+
+// failurePenalty is the latency sample recorded for a failed dial.
+const failurePenalty = 5 * time.Second
+
+// SmartDialer dials the historically fastest of several addresses first. The zero value is ready to use.
+type SmartDialer struct {
+	// Network is the network to dial; empty means "tcp".
+	Network string
+	// Stagger is the head start each address gets over the next one; zero means 100ms.
+	Stagger time.Duration
+	// Alpha is the weight of a new sample in the moving average, between 0 and 1; zero means 0.3.
+	Alpha float64
+
+	dial func(ctx context.Context, network, address string) (net.Conn, error) // DialContext if nil
+
+	mu      sync.Mutex
+	latency map[string]time.Duration // EWMA per address
+}
+
+// Dial connects to one of addresses, trying the historically fastest first.
+//   - It returns the connection and the address it is connected to.
+//   - If every dial fails, the returned error wraps each individual error.
+func (d *SmartDialer) Dial(ctx context.Context, addresses []string) (net.Conn, string, error) {
+	if len(addresses) == 0 {
+		return nil, "", errors.New("no addresses to dial")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ordered := d.order(addresses)
+
+	type result struct {
+		conn    net.Conn
+		address string
+		elapsed time.Duration
+		err     error
+	}
+	results := make(chan result, len(ordered))
+	start := func(address string) {
+		go func() {
+			begin := time.Now()
+			conn, err := d.dialFunc()(ctx, d.network(), address)
+			results <- result{conn: conn, address: address, elapsed: time.Since(begin), err: err}
+		}()
+	}
+
+	stagger := time.NewTimer(d.stagger())
+	defer stagger.Stop()
+
+	start(ordered[0])
+	next, pending := 1, 1
+	var errs []error
+	for pending > 0 {
+		var headStartOver <-chan time.Time
+		if next < len(ordered) {
+			headStartOver = stagger.C
+		}
+
+		select {
+		case <-headStartOver:
+			start(ordered[next])
+			next++
+			pending++
+			stagger.Reset(d.stagger())
+
+		case r := <-results:
+			pending--
+			if r.err != nil {
+				errs = append(errs, r.err)
+				if ctx.Err() == nil {
+					d.record(r.address, failurePenalty)
+				}
+				if next < len(ordered) && ctx.Err() == nil {
+					// Don't wait for the head start of an address that already failed.
+					start(ordered[next])
+					next++
+					pending++
+					stagger.Reset(d.stagger())
+				}
+				continue
+			}
+
+			d.record(r.address, r.elapsed)
+			cancel()
+			go func(remaining int) {
+				for ; remaining > 0; remaining-- {
+					if late := <-results; late.conn != nil {
+						_ = late.conn.Close()
+					}
+				}
+			}(pending)
+
+			return r.conn, r.address, nil
+		}
+	}
+
+	if err := ctx.Err(); err != nil && next < len(ordered) {
+		errs = append(errs, err) // the remaining addresses were never tried
+	}
+	return nil, "", fmt.Errorf("all %d dials failed: %w", len(errs), errors.Join(errs...))
+}
+
+// Latency returns the moving average of the connect latency to address, if the dialer has any.
+func (d *SmartDialer) Latency(address string) (time.Duration, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	latency, ok := d.latency[address]
+	return latency, ok
+}
+
+// order returns addresses sorted by their moving average; unknown addresses keep their order at the end.
+func (d *SmartDialer) order(addresses []string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ordered := slices.Clone(addresses)
+	slices.SortStableFunc(ordered, func(a, b string) int {
+		la, knownA := d.latency[a]
+		lb, knownB := d.latency[b]
+		switch {
+		case knownA && knownB:
+			return cmp.Compare(la, lb)
+		case knownA:
+			return -1
+		case knownB:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return ordered
+}
+
+// record adds a latency sample for address to its moving average.
+func (d *SmartDialer) record(address string, sample time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.latency == nil {
+		d.latency = make(map[string]time.Duration)
+	}
+
+	previous, ok := d.latency[address]
+	if !ok {
+		d.latency[address] = sample
+		return
+	}
+
+	alpha := d.Alpha
+	if alpha <= 0 || alpha > 1 {
+		alpha = 0.3
+	}
+	d.latency[address] = time.Duration(alpha*float64(sample) + (1-alpha)*float64(previous))
+}
+
+func (d *SmartDialer) network() string {
+	if d.Network == "" {
+		return "tcp"
+	}
+	return d.Network
+}
+
+func (d *SmartDialer) stagger() time.Duration {
+	if d.Stagger <= 0 {
+		return 100 * time.Millisecond
+	}
+	return d.Stagger
+}
+
+func (d *SmartDialer) dialFunc() func(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.dial == nil {
+		return DialContext
+	}
+	return d.dial
+}
@@ -0,0 +1,101 @@
+package ch03
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeReplicas dials in memory: every address connects after its own delay.
+// It records the order in which addresses are attempted.
+type fakeReplicas struct {
+	delays map[string]time.Duration
+
+	mu       sync.Mutex
+	attempts []string
+}
+
+func (f *fakeReplicas) dial(ctx context.Context, _, address string) (net.Conn, error) {
+	f.mu.Lock()
+	f.attempts = append(f.attempts, address)
+	f.mu.Unlock()
+
+	select {
+	case <-time.After(f.delays[address]):
+		c1, c2 := net.Pipe()
+		_ = c2.Close()
+		return c1, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// first returns the first address attempted since the last call and forgets the attempts.
+func (f *fakeReplicas) first() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	first := f.attempts[0]
+	f.attempts = nil
+	return first
+}
+
+func TestSmartDialerPrefersFaster(t *testing.T) {
+	replicas := &fakeReplicas{delays: map[string]time.Duration{
+		"slow:1": 150 * time.Millisecond,
+		"fast:1": 5 * time.Millisecond,
+	}}
+	d := &SmartDialer{Stagger: 20 * time.Millisecond, dial: replicas.dial}
+	addresses := []string{"slow:1", "fast:1"} // the slow one is listed first
+
+	for i := 0; i < 5; i++ {
+		conn, address, err := d.Dial(context.Background(), addresses)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.Close()
+
+		if address != "fast:1" {
+			t.Errorf("dial %d: expected the fast replica to win; actual: %s", i, address)
+		}
+
+		first := replicas.first()
+		switch {
+		case i == 0 && first != "slow:1":
+			t.Errorf("without history, expected the listed order; actual first attempt: %s", first)
+		case i > 0 && first != "fast:1":
+			t.Errorf("dial %d: expected the fast replica to be attempted first; actual: %s", i, first)
+		}
+	}
+
+	if latency, ok := d.Latency("fast:1"); !ok || latency > 100*time.Millisecond {
+		t.Errorf("unexpected latency for the fast replica: %s (known: %t)", latency, ok)
+	}
+}
+
+func TestSmartDialerFailureFallsBack(t *testing.T) {
+	d := &SmartDialer{Stagger: time.Second}
+
+	// The dead address is listed first; its failure must start the next dial without waiting a second.
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	start := time.Now()
+	conn, address, err := d.Dial(context.Background(), []string{deadAddress(t), listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	if address != listener.Addr().String() {
+		t.Errorf("expected %s; actual: %s", listener.Addr(), address)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("the failed dial didn't start the next one right away: %s", elapsed)
+	}
+}
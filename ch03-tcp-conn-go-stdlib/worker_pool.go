@@ -0,0 +1,153 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"sync"
+)
+
+// ## A Bounded Pool of Connection Handlers
+// The listener in Listing 3-1 starts a goroutine for every connection it accepts.
+// Goroutines are cheap, but not free: a flood of connections means a flood of goroutines (and their buffers),
+// and the process can run out of memory even if each handler does very little.
+// WorkerPool caps the number of connections handled at the same time:
+//	1. Serve starts Workers goroutines up front. They are the only goroutines that run Handler.
+//	2. The accept loop passes every accepted connection to the workers over a channel (the queue).
+//	   By default it is unbuffered; QueueSize lets that many accepted connections wait for a worker.
+//	3. When all workers are busy (and the queue is full), that send blocks, so the loop stops calling Accept:
+//		- new connections wait in the kernel's accept queue (the listen backlog) instead of in our memory
+//		- once the backlog is full, the kernel refuses (or ignores) further connection attempts
+//		- that's the backpressure: the flood is slowed down before it reaches us
+//	4. A worker closes the connection when Handler returns and picks up the next one.
+//
+// ### Draining on shutdown
+// Stopping the process by closing the listener works, but the connections we already accepted deserve an answer.
+// Shutdown stops the pool gracefully:
+//	1. Close every listener, so no new connection is accepted. Serve returns ErrPoolClosed.
+//	2. Close the queue: the workers still take every connection that is in it (plus the one the accept loop
+//	   was waiting to queue), handle it, and exit when it's empty.
+//	3. Wait for the workers to exit, or for ctx to be done. In the second case Shutdown returns ctx.Err()
+//	   and the workers keep going in the background: handlers are not interrupted.
+
+// ErrPoolClosed is returned by Serve after Shutdown.
+var ErrPoolClosed = errors.New("worker pool closed")
+
+// WorkerPool accepts connections and hands them to a fixed number of workers.
+//   - Set the fields before calling Serve and don't change them afterwards.
+type WorkerPool struct {
+	// Workers is the number of connections handled at the same time; zero means runtime.GOMAXPROCS(0).
+	Workers int
+	// QueueSize is the number of accepted connections that may wait for a worker; zero means none.
+	QueueSize int
+	// Handler serves one connection. The connection is closed when it returns.
+	Handler func(conn net.Conn)
+
+	mu        sync.Mutex
+	closed    bool
+	listeners map[net.Listener]struct{}
+	serving   sync.WaitGroup // one per Serve call, done when its workers have exited
+}
+
+// Serve accepts connections on listener until Accept fails, then waits for the workers to finish and returns the error.
+//   - After Shutdown, it returns ErrPoolClosed.
+func (p *WorkerPool) Serve(listener net.Listener) error {
+	if !p.track(listener) {
+		_ = listener.Close()
+		return ErrPoolClosed
+	}
+	defer p.serving.Done() // last: after the workers exited
+	defer p.untrack(listener)
+
+	workers := p.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	conns := make(chan net.Conn, max(p.QueueSize, 0)) // a send waits for room in the queue or an idle worker
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for conn := range conns {
+				p.handle(conn)
+			}
+		})
+	}
+
+	defer wg.Wait()
+	defer close(conns) // the workers finish what is queued, then exit
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if p.isClosed() {
+				return ErrPoolClosed
+			}
+			return err
+		}
+
+		conns <- conn // blocks while all workers are busy and the queue is full
+	}
+}
+
+// Shutdown stops accepting, lets the workers handle every queued connection, and waits for them to exit.
+//   - If ctx is done first, it returns ctx.Err(); the workers still finish in the background.
+func (p *WorkerPool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	for l := range p.listeners {
+		_ = l.Close()
+	}
+	p.mu.Unlock()
+
+	idle := make(chan struct{})
+	go func() {
+		p.serving.Wait()
+		close(idle)
+	}()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// track registers a listener for Shutdown; it returns false after Shutdown.
+func (p *WorkerPool) track(listener net.Listener) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return false
+	}
+	if p.listeners == nil {
+		p.listeners = make(map[net.Listener]struct{})
+	}
+	p.listeners[listener] = struct{}{}
+	p.serving.Add(1) // under p.mu: Shutdown can't be waiting yet
+	return true
+}
+
+func (p *WorkerPool) untrack(listener net.Listener) {
+	p.mu.Lock()
+	delete(p.listeners, listener)
+	p.mu.Unlock()
+}
+
+func (p *WorkerPool) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// handle runs Handler for conn and closes conn afterwards.
+func (p *WorkerPool) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	if p.Handler != nil {
+		p.Handler(conn)
+	}
+}
@@ -0,0 +1,186 @@
+package ch03
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool(t *testing.T) {
+	assertNoLeaks(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, conns = 2, 6
+	var active, peak, handled atomic.Int32
+	pool := &WorkerPool{
+		Workers: workers,
+		Handler: func(net.Conn) {
+			n := active.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond) // quick, but long enough to overlap
+			active.Add(-1)
+			handled.Add(1)
+		},
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- pool.Serve(listener) }()
+
+	// All clients connect at once and wait for the server to close their connection.
+	var wg sync.WaitGroup
+	for range conns {
+		wg.Go(func() {
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+
+			_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			if _, err = conn.Read(make([]byte, 1)); err == nil {
+				t.Error("expected the connection to be closed")
+			}
+		})
+	}
+	wg.Wait()
+
+	if n := handled.Load(); n != conns {
+		t.Errorf("expected %d handled connections; actual: %d", conns, n)
+	}
+	if n := peak.Load(); n > workers {
+		t.Errorf("expected at most %d concurrent handlers; actual: %d", workers, n)
+	}
+
+	_ = listener.Close()
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after the listener was closed")
+	}
+}
+
+// acceptCounter counts the calls to Accept.
+type acceptCounter struct {
+	net.Listener
+	calls atomic.Int32
+}
+
+func (l *acceptCounter) Accept() (net.Conn, error) {
+	l.calls.Add(1)
+	return l.Listener.Accept()
+}
+
+// One worker is busy and three connections wait in the queue when Shutdown is called.
+// Shutdown must not return before all four were handled.
+func TestWorkerPoolShutdown(t *testing.T) {
+	assertNoLeaks(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := &acceptCounter{Listener: listener}
+
+	release := make(chan struct{})
+	var handled atomic.Int32
+	pool := &WorkerPool{
+		Workers:   1,
+		QueueSize: 3,
+		Handler: func(net.Conn) {
+			<-release
+			handled.Add(1)
+		},
+	}
+	served := make(chan error, 1)
+	go func() { served <- pool.Serve(counter) }()
+
+	const conns = 4
+	for range conns {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+	}
+
+	// The 5th Accept call means the 4 connections were all handed over: one to the worker, three to the queue.
+	deadline := time.Now().Add(time.Second)
+	for counter.calls.Load() <= conns {
+		if time.Now().After(deadline) {
+			t.Fatal("the connections were not all accepted")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- pool.Shutdown(context.Background()) }()
+
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned before the queued connections were handled: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+	if n := handled.Load(); n != conns {
+		t.Errorf("expected %d handled connections when Shutdown returned; actual: %d", conns, n)
+	}
+	if err := <-served; !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("expected ErrPoolClosed from Serve; actual: %v", err)
+	}
+
+	if err := pool.Serve(listener); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("expected ErrPoolClosed from Serve after Shutdown; actual: %v", err)
+	}
+}
+
+// A handler that doesn't finish in time makes Shutdown return ctx.Err().
+func TestWorkerPoolShutdownTimeout(t *testing.T) {
+	assertNoLeaks(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handling, release := make(chan struct{}), make(chan struct{})
+	pool := &WorkerPool{Workers: 1, Handler: func(net.Conn) {
+		close(handling)
+		<-release
+	}}
+	served := make(chan error, 1)
+	go func() { served <- pool.Serve(listener) }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	<-handling
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := pool.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded; actual: %v", err)
+	}
+
+	close(release)
+	<-served
+}
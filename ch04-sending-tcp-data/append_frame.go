@@ -0,0 +1,32 @@
+package ch04
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// ## Building Several Frames in One Buffer
+// Writing payloads one by one costs a Write per frame (or more, see small_frame.go).
+// When the frames are known up front, it's cheaper to encode them all into one slice and write it once.
+// AppendFrame follows the `append` convention of the standard library (strconv.AppendInt, binary.BigEndian.AppendUint32, ...):
+//	- It appends the complete frame to dst and returns the extended slice.
+//	- When dst has enough spare capacity, nothing is allocated: reuse the buffer with `buf = buf[:0]`.
+//	- `bytes.NewBuffer(dst)` does the work: a bytes.Buffer writes after the existing bytes, into dst's spare capacity,
+//	  so the payload's own WriteTo encodes the frame and every payload type works.
+//	- A frame over MaxPayloadSize is refused with ErrMaxPayloadSize, like on the reading side.
+//	  On any error dst is returned unchanged (same length), so a partial frame never ends up in the buffer.
+
+// AppendFrame appends p's complete TLV frame to dst and returns the extended slice.
+func AppendFrame(dst []byte, p Payload) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	if _, err := p.WriteTo(buf); err != nil {
+		return dst, err
+	}
+
+	frame := buf.Bytes()[len(dst):]
+	if len(frame) < tlvHeaderSize || binary.BigEndian.Uint32(frame[1:tlvHeaderSize]) > MaxPayloadSize {
+		return dst, ErrMaxPayloadSize
+	}
+
+	return buf.Bytes(), nil
+}
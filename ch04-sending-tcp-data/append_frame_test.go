@@ -0,0 +1,50 @@
+package ch04
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestAppendFrame(t *testing.T) {
+	payloads := []Payload{ptr(Binary("binary")), ptr(String("string")), ptr(Ping("ping"))}
+
+	buf := make([]byte, 0, 64)
+	var err error
+	for _, p := range payloads {
+		if buf, err = AppendFrame(buf, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cap(buf) != 64 {
+		t.Errorf("expected the frames to fit in the existing capacity; cap is now %d", cap(buf))
+	}
+
+	r := bytes.NewReader(buf)
+	for i, expected := range payloads {
+		actual, err := Decode(r)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !bytes.Equal(actual.Bytes(), expected.Bytes()) {
+			t.Errorf("frame %d: expected %q; actual: %q", i, expected, actual)
+		}
+	}
+	if _, err = Decode(r); err != io.EOF {
+		t.Errorf("expected io.EOF after the last frame; actual: %v", err)
+	}
+}
+
+func TestAppendFrameTooLarge(t *testing.T) {
+	dst := []byte("existing")
+	huge := Binary(make([]byte, MaxPayloadSize+1))
+
+	out, err := AppendFrame(dst, &huge)
+	if !errors.Is(err, ErrMaxPayloadSize) {
+		t.Fatalf("expected ErrMaxPayloadSize; actual: %v", err)
+	}
+	if string(out) != "existing" {
+		t.Errorf("expected dst unchanged; actual: %d bytes", len(out))
+	}
+}
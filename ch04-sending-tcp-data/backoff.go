@@ -0,0 +1,52 @@
+package ch04
+
+import "time"
+
+// ## Exponential Backoff
+// When a dial fails, trying again immediately usually fails again and wastes resources on both ends.
+//	- Backoff doubles the wait after every failed attempt, starting at Min and never exceeding Max.
+//	- After a success, call Reset so the next outage starts again from Min.
+//	- The zero value is usable: it waits between 100ms and 10s.
+
+const (
+	defaultBackoffMin = 100 * time.Millisecond
+	defaultBackoffMax = 10 * time.Second
+)
+
+// Backoff computes exponentially growing delays between retries.
+//   - It is not safe for concurrent use.
+type Backoff struct {
+	Min time.Duration
+	Max time.Duration
+
+	next time.Duration
+}
+
+// Next returns how long to wait before the next attempt.
+func (b *Backoff) Next() time.Duration {
+	lower, upper := b.Min, b.Max
+	if lower <= 0 {
+		lower = defaultBackoffMin
+	}
+	if upper <= 0 {
+		upper = defaultBackoffMax
+	}
+
+	if b.next < lower {
+		b.next = lower
+	}
+	d := b.next
+
+	b.next *= 2
+	if b.next > upper {
+		b.next = upper
+	}
+	if d > upper {
+		d = upper
+	}
+
+	return d
+}
+
+// Reset starts the sequence over from Min.
+func (b *Backoff) Reset() { b.next = 0 }
@@ -0,0 +1,22 @@
+package ch04
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Min: 10 * time.Millisecond, Max: 50 * time.Millisecond}
+
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for i, e := range expected {
+		if actual := b.Next(); actual != e*time.Millisecond {
+			t.Errorf("%d: expected %s; actual: %s", i, e*time.Millisecond, actual)
+		}
+	}
+
+	b.Reset()
+	if actual := b.Next(); actual != 10*time.Millisecond {
+		t.Errorf("expected %s after Reset; actual: %s", 10*time.Millisecond, actual)
+	}
+}
@@ -0,0 +1,136 @@
+package ch04
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ## Sending Many Small Payloads in One Frame
+// Every frame costs a header and (without a BufferedEncoder) at least one write.
+// For a burst of tiny payloads it is cheaper to put them all into one frame: a Batch.
+//	- The value of a Batch frame is a count followed by the complete TLV frames of its payloads:
+//		- [BatchType][Length:4 bytes] [Count:4 bytes][Frame 1][Frame 2]...[Frame Count]
+//	- The receiver decodes the Batch like any other payload and gets the inner payloads back, in order.
+//	- The inner frames are decoded through DefaultRegistry, so every registered type can be batched.
+//	- MaxPayloadSize applies to the whole batch, on both sides.
+//	- A Batch can't contain another Batch: nesting buys nothing and would let a peer make us recurse very deeply.
+
+// BatchType is the type byte of a Batch frame.
+const BatchType uint8 = 6
+
+// ErrNestedBatch is returned when a Batch contains another Batch.
+var ErrNestedBatch = errors.New("nested Batch")
+
+func init() {
+	Register(BatchType, func() Payload { return new(Batch) })
+}
+
+// Batch is a list of payloads sent as one frame.
+type Batch []Payload
+
+// Bytes returns the encoded value: the count and the inner frames.
+func (m Batch) Bytes() []byte {
+	value, _ := m.value()
+	return value
+}
+
+func (m Batch) String() string { return fmt.Sprintf("batch of %d payloads", len(m)) }
+
+// WriteTo writes the whole batch as one frame.
+func (m Batch) WriteTo(w io.Writer) (int64, error) {
+	value, err := m.value()
+	if err != nil {
+		return 0, err
+	}
+	if len(value) > int(MaxPayloadSize) {
+		return 0, ErrMaxPayloadSize
+	}
+
+	return writeTLV(w, BatchType, value)
+}
+
+// value encodes the count and the inner frames.
+func (m Batch) value() ([]byte, error) {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(m))) // 4-byte count
+
+	for _, p := range m {
+		if _, ok := p.(*Batch); ok {
+			return nil, ErrNestedBatch
+		}
+		if _, err := p.WriteTo(&buf); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ReadFrom reads a Batch frame and decodes its inner payloads.
+func (m *Batch) ReadFrom(r io.Reader) (int64, error) {
+	value, n, err := readTLV(r, BatchType, "Batch")
+	if err != nil {
+		return n, err
+	}
+
+	payloads, err := decodeBatch(value)
+	if err != nil {
+		return n, err
+	}
+
+	*m = payloads
+	return n, nil
+}
+
+// decodeBatch splits a Batch value back into its payloads.
+func decodeBatch(value []byte) (Batch, error) {
+	if len(value) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	count := binary.BigEndian.Uint32(value) // 4-byte count
+	frames := bytes.NewReader(value[4:])
+
+	// Every frame is at least a header, which bounds the count a peer can make us allocate for.
+	payloads := make(Batch, 0, min(count, uint32(frames.Len()/tlvHeaderSize)))
+	for range count {
+		if frames.Len() > 0 {
+			if typ, _ := frames.ReadByte(); typ == BatchType {
+				return nil, ErrNestedBatch
+			}
+			_ = frames.UnreadByte()
+		}
+
+		p, err := Decode(frames)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF // fewer frames than the count says
+			}
+			return nil, err
+		}
+		payloads = append(payloads, p)
+	}
+
+	if frames.Len() != 0 {
+		return nil, errors.New("trailing bytes in Batch")
+	}
+
+	return payloads, nil
+}
+
+// DecodeBatch reads one frame from r and returns its payloads.
+//   - A Batch frame returns its inner payloads; any other frame returns a slice with just that payload.
+func DecodeBatch(r io.Reader) ([]Payload, error) {
+	p, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if b, ok := p.(*Batch); ok {
+		return *b, nil
+	}
+
+	return []Payload{p}, nil
+}
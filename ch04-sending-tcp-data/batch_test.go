@@ -0,0 +1,79 @@
+package ch04
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	var batch Batch
+	for i := 0; i < 100; i++ {
+		s := String(fmt.Sprintf("message %d", i))
+		batch = append(batch, &s)
+	}
+
+	var buf bytes.Buffer
+	if _, err := batch.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Bytes()[0] != BatchType {
+		t.Fatalf("expected one Batch frame; first type byte: %d", buf.Bytes()[0])
+	}
+
+	payloads, err := DecodeBatch(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]Payload(batch), payloads) {
+		t.Fatalf("expected the same 100 payloads in order; got %d", len(payloads))
+	}
+	if buf.Len() != 0 {
+		t.Errorf("%d bytes left after the batch", buf.Len())
+	}
+
+	// A frame that isn't a Batch comes back on its own.
+	b := Binary("single")
+	buf.Reset()
+	_, _ = b.WriteTo(&buf)
+	payloads, err = DecodeBatch(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]Payload{&b}, payloads) {
+		t.Errorf("expected just the Binary; actual: %v", payloads)
+	}
+}
+
+func TestBatchErrors(t *testing.T) {
+	inner := Batch{}
+	nested := Batch{&inner}
+	if _, err := nested.WriteTo(new(bytes.Buffer)); !errors.Is(err, ErrNestedBatch) {
+		t.Errorf("expected ErrNestedBatch writing a nested batch; actual: %v", err)
+	}
+
+	big := Binary(make([]byte, MaxPayloadSize-100))
+	tooBig := Batch{&big, &big}
+	if _, err := tooBig.WriteTo(new(bytes.Buffer)); !errors.Is(err, ErrMaxPayloadSize) {
+		t.Errorf("expected ErrMaxPayloadSize; actual: %v", err)
+	}
+
+	s := String("x")
+	var frame bytes.Buffer
+	_, _ = s.WriteTo(&frame)
+	tests := map[string][]byte{
+		"count larger than frames": append([]byte{0, 0, 0, 2}, frame.Bytes()...),
+		"trailing bytes":           append(append([]byte{0, 0, 0, 1}, frame.Bytes()...), 0),
+		"nested batch":             {0, 0, 0, 1, BatchType, 0, 0, 0, 4, 0, 0, 0, 0},
+		"no count":                 {0, 0},
+	}
+	for name, value := range tests {
+		var buf bytes.Buffer
+		_, _ = writeTLV(&buf, BatchType, value)
+		if _, err := Decode(&buf); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
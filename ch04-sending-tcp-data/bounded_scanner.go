@@ -0,0 +1,50 @@
+package ch04
+
+import (
+	"bufio"
+	"io"
+	"math"
+)
+
+// ## Scanning Long Tokens
+// The Scanner in Listing 4-3 uses its default buffer: tokens may be at most `bufio.MaxScanTokenSize` (64KB).
+// A longer line from the network doesn't panic, but Scan stops and Err returns `bufio.ErrTooLong`,
+// which is easy to miss until production traffic sends a long line.
+//	- `scanner.Buffer(buf, max)` sets both the initial buffer and the maximum it may grow to.
+//	- NewBoundedScanner picks those for a given maximum token size:
+//		- the buffer starts small (at most 4KB) and only grows when long tokens actually arrive
+//		- it may grow to maxToken plus two bytes, so a token of exactly maxToken bytes still fits with its delimiter,
+//		  even when that is "\r\n"
+//		- the extra room would let a longer LF-terminated token through, so the split function is wrapped
+//		  by BoundSplit, which rejects any token longer than maxToken
+//	- Anything longer still fails with `bufio.ErrTooLong`: the limit protects us from a peer that never sends a delimiter.
+
+// initialScanBuffer is the starting buffer size of a bounded scanner.
+const initialScanBuffer = 4096
+
+// NewBoundedScanner returns a Scanner reading from r that accepts tokens of up to maxToken bytes.
+//   - The split function is `bufio.ScanLines` wrapped by BoundSplit. Split replaces it as usual;
+//     wrap the new one with BoundSplit too to keep the exact limit.
+//   - A negative maxToken is treated as 0: only empty tokens fit.
+func NewBoundedScanner(r io.Reader, maxToken int) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+
+	maxToken = min(max(maxToken, 0), math.MaxInt-2)
+	limit := maxToken + 2 // room for a "\r\n" delimiter
+	scanner.Buffer(make([]byte, 0, min(initialScanBuffer, limit)), limit)
+	scanner.Split(BoundSplit(bufio.ScanLines, maxToken))
+
+	return scanner
+}
+
+// BoundSplit returns a split function that fails with `bufio.ErrTooLong` when split returns a token
+// longer than maxToken bytes.
+func BoundSplit(split bufio.SplitFunc, maxToken int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := split(data, atEOF)
+		if len(token) > maxToken {
+			return 0, nil, bufio.ErrTooLong
+		}
+		return advance, token, err
+	}
+}
@@ -0,0 +1,79 @@
+package ch04
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestNewBoundedScanner(t *testing.T) {
+	const maxToken = 100 << 10 // 100KB, more than the default 64KB
+
+	tests := []struct {
+		name  string
+		token int
+		delim string
+		err   error
+	}{
+		{"under the default limit", 1 << 10, "\n", nil},
+		{"over the default limit", 80 << 10, "\n", nil},
+		{"exactly the max", maxToken, "\n", nil},
+		{"exactly the max with CRLF", maxToken, "\r\n", nil},
+		{"over the max", maxToken + 1, "\n", bufio.ErrTooLong},
+		{"over the max with CRLF", maxToken + 1, "\r\n", bufio.ErrTooLong},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			line := strings.Repeat("x", tc.token)
+			input := line + tc.delim + "next\n"
+
+			scanner := NewBoundedScanner(strings.NewReader(input), maxToken)
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != tc.err {
+					t.Fatalf("expected error %v; actual: %v", tc.err, err)
+				}
+				return
+			}
+			if tc.err != nil {
+				t.Fatalf("expected error %v; scanned %d bytes", tc.err, len(scanner.Bytes()))
+			}
+
+			if !bytes.Equal(scanner.Bytes(), []byte(line)) {
+				t.Fatalf("expected a %d-byte token; actual: %d bytes", len(line), len(scanner.Bytes()))
+			}
+			if !scanner.Scan() || scanner.Text() != "next" {
+				t.Fatalf("expected the next line; actual: %q, %v", scanner.Text(), scanner.Err())
+			}
+		})
+	}
+
+	// The default Scanner fails on the same 80KB line.
+	scanner := bufio.NewScanner(strings.NewReader(strings.Repeat("x", 80<<10) + "\n"))
+	if scanner.Scan() || scanner.Err() != bufio.ErrTooLong {
+		t.Fatalf("expected the default scanner to fail with ErrTooLong; actual: %v", scanner.Err())
+	}
+}
+
+// A negative maxToken doesn't panic: it is treated as 0, so only empty tokens fit.
+func TestNewBoundedScannerNegative(t *testing.T) {
+	for _, maxToken := range []int{-1, -2, -100} {
+		scanner := NewBoundedScanner(strings.NewReader("\nx\n"), maxToken)
+		if !scanner.Scan() || scanner.Text() != "" {
+			t.Fatalf("%d: expected an empty token; actual: %q, %v", maxToken, scanner.Text(), scanner.Err())
+		}
+		if scanner.Scan() || scanner.Err() != bufio.ErrTooLong {
+			t.Fatalf("%d: expected ErrTooLong for a 1-byte token; actual: %v", maxToken, scanner.Err())
+		}
+	}
+}
+
+// A maxToken close to math.MaxInt doesn't overflow the buffer limit.
+func TestNewBoundedScannerHuge(t *testing.T) {
+	scanner := NewBoundedScanner(strings.NewReader("x\n"), math.MaxInt)
+	if !scanner.Scan() || scanner.Text() != "x" {
+		t.Fatalf("expected %q; actual: %q, %v", "x", scanner.Text(), scanner.Err())
+	}
+}
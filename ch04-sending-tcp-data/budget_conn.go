@@ -0,0 +1,138 @@
+package ch04
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// ## Byte Budgets per Connection
+// A quota like "a client may transfer at most 100MB per connection" is easiest to enforce at the net.Conn itself:
+// every byte of every frame goes through Read and Write, no matter which payload type carries it.
+//	- BudgetConn wraps a `net.Conn` and counts the bytes read and written.
+//	- A Budget sets the limits. Each limit is optional (0 means no limit):
+//		- Read and Write limit each direction independently
+//		- Total limits both directions combined
+//	- Read and Write never go past a limit: a call is shortened to what is left of the budget.
+//	- A call that finds the budget used up closes the underlying connection and returns ErrBudgetExceeded.
+//	  From then on, every Read and Write returns ErrBudgetExceeded.
+//
+// NOTE:
+//	- The budget is reserved BEFORE the system call and the unused part is given back afterwards,
+//	  so a Read and a Write running at the same time can't exceed Total together.
+
+// ErrBudgetExceeded is returned by a BudgetConn that used up its byte budget.
+var ErrBudgetExceeded = errors.New("connection byte budget exceeded")
+
+// Budget limits the bytes a BudgetConn may transfer. A zero limit means no limit.
+type Budget struct {
+	Read  int64 // bytes read
+	Write int64 // bytes written
+	Total int64 // bytes read and written together
+}
+
+// BudgetConn is a net.Conn that is closed once it transferred its Budget.
+type BudgetConn struct {
+	net.Conn
+	budget Budget
+
+	mu       sync.Mutex
+	read     int64 // including reservations of Reads in progress
+	written  int64 // including reservations of Writes in progress
+	exceeded bool
+}
+
+// NewBudgetConn wraps conn with the given budget.
+func NewBudgetConn(conn net.Conn, budget Budget) *BudgetConn {
+	return &BudgetConn{Conn: conn, budget: budget}
+}
+
+// NetConn returns the wrapped connection.
+func (c *BudgetConn) NetConn() net.Conn { return c.Conn }
+
+// Used returns the bytes read and written so far.
+func (c *BudgetConn) Used() (read, written int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.read, c.written
+}
+
+// Read reads at most what is left of the read (and total) budget.
+func (c *BudgetConn) Read(p []byte) (int, error) {
+	allowed, err := c.reserve(&c.read, c.budget.Read, len(p))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := c.Conn.Read(p[:allowed])
+	c.refund(&c.read, allowed-n)
+
+	return n, err
+}
+
+// Write writes what fits in the write (and total) budget.
+//   - If p doesn't fit, the part that fits is written and ErrBudgetExceeded is returned.
+func (c *BudgetConn) Write(p []byte) (int, error) {
+	allowed, err := c.reserve(&c.written, c.budget.Write, len(p))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := c.Conn.Write(p[:allowed])
+	c.refund(&c.written, allowed-n)
+	if err == nil && n < len(p) {
+		c.exceed()
+		err = ErrBudgetExceeded
+	}
+
+	return n, err
+}
+
+// reserve books up to want bytes on counter (limited by limit and the total budget).
+func (c *BudgetConn) reserve(counter *int64, limit int64, want int) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.exceeded {
+		return 0, ErrBudgetExceeded
+	}
+
+	allowed := int64(want)
+	if limit > 0 {
+		allowed = min(allowed, limit-*counter)
+	}
+	if c.budget.Total > 0 {
+		allowed = min(allowed, c.budget.Total-c.read-c.written)
+	}
+
+	if allowed <= 0 && want > 0 {
+		c.exceedLocked()
+		return 0, ErrBudgetExceeded
+	}
+
+	*counter += allowed
+	return int(allowed), nil
+}
+
+// refund gives back the reserved bytes a call didn't use.
+func (c *BudgetConn) refund(counter *int64, unused int) {
+	c.mu.Lock()
+	*counter -= int64(unused)
+	c.mu.Unlock()
+}
+
+func (c *BudgetConn) exceed() {
+	c.mu.Lock()
+	c.exceedLocked()
+	c.mu.Unlock()
+}
+
+// exceedLocked closes the connection the first time the budget runs out. c.mu must be held.
+func (c *BudgetConn) exceedLocked() {
+	if c.exceeded {
+		return
+	}
+	c.exceeded = true
+	_ = c.Conn.Close()
+}
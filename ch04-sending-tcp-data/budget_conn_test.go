@@ -0,0 +1,69 @@
+package ch04
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestBudgetConnWrite(t *testing.T) {
+	client, server := tcpPair(t)
+	go func() { _, _ = io.Copy(io.Discard, server) }()
+
+	conn := NewBudgetConn(client, Budget{Write: 100})
+
+	// Each frame is 5 header bytes + 10 value bytes: 6 frames fit, the 7th doesn't.
+	var err error
+	frames := 0
+	for ; frames < 10; frames++ {
+		if _, err = Binary("0123456789").WriteTo(conn); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded; actual: %v", err)
+	}
+	if frames != 6 {
+		t.Errorf("expected 6 frames to fit; actual: %d", frames)
+	}
+	if _, written := conn.Used(); written != 100 {
+		t.Errorf("expected 100 bytes written; actual: %d", written)
+	}
+
+	// Every later operation fails, in both directions.
+	if _, err = conn.Write([]byte("x")); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected ErrBudgetExceeded from Write; actual: %v", err)
+	}
+	if _, err = conn.Read(make([]byte, 1)); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected ErrBudgetExceeded from Read; actual: %v", err)
+	}
+}
+
+func TestBudgetConnTotal(t *testing.T) {
+	client, server := tcpPair(t)
+	go func() { _, _ = io.Copy(server, server) }() // echo
+
+	conn := NewBudgetConn(client, Budget{Total: 40})
+
+	// 15 bytes out and 15 back per round trip: the second round trip runs out of budget.
+	var err error
+	for range 2 {
+		if _, err = Binary("0123456789").WriteTo(conn); err != nil {
+			break
+		}
+		if _, err = Decode(conn); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded; actual: %v", err)
+	}
+
+	read, written := conn.Used()
+	if read+written != 40 {
+		t.Errorf("expected 40 bytes in total; actual: %d read + %d written", read, written)
+	}
+	if _, err = conn.Write([]byte("x")); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected ErrBudgetExceeded after the budget ran out; actual: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package ch04
+
+import (
+	"bufio"
+	"io"
+)
+
+// ## Coalescing Writes with a Buffered Encoder
+// Calling WriteTo directly on a `net.Conn` costs at least one system call per frame
+// (in our TLV implementation it is actually three: type, length and value).
+//	- For a burst of tiny messages this overhead dominates: the kernel does far more work than the data requires.
+//	- `bufio.Writer` solves this by collecting many small writes in memory and handing them to the
+//	  underlying writer in a single, larger Write.
+//	- The BufferedEncoder wraps a `bufio.Writer` so frames accumulate until either the buffer reaches the
+//	  flush threshold or you call Flush yourself.
+//
+// What must you remember?
+//	- Buffered data is NOT on the wire yet.
+//	- If you close the connection without flushing, the frames still sitting in the buffer are lost.
+//	- That is why BufferedEncoder has its own Close method: it always flushes first and only then closes the writer.
+//	- A BufferedEncoder is not safe for concurrent use; use one encoder per goroutine (or guard it with a mutex).
+
+// defaultFlushThreshold is used when the caller passes a threshold <= 0.
+const defaultFlushThreshold = 4096
+
+// BufferedEncoder writes TLV frames to an underlying writer through a `bufio.Writer`.
+type BufferedEncoder struct {
+	w       io.Writer
+	bw      *bufio.Writer
+	maxSize uint32 // largest value Encode accepts; zero means MaxPayloadSize
+}
+
+// NewBufferedEncoder returns an encoder that flushes to w whenever threshold bytes are buffered.
+//   - The threshold is the size of the `bufio.Writer` buffer,
+//     so bufio itself flushes as soon as the next write would not fit.
+func NewBufferedEncoder(w io.Writer, threshold int) *BufferedEncoder {
+	if threshold <= 0 {
+		threshold = defaultFlushThreshold
+	}
+
+	return &BufferedEncoder{w: w, bw: bufio.NewWriterSize(w, threshold)}
+}
+
+// Encode appends the frame of p to the buffer.
+//   - Nothing may reach the underlying writer until the threshold is hit or Flush is called.
+//   - A value larger than the encoder's maximum size is refused with ErrMaxPayloadSize, before anything is buffered.
+func (e *BufferedEncoder) Encode(p Payload) error {
+	if e.maxSize > 0 && uint32(len(p.Bytes())) > e.maxSize {
+		return ErrMaxPayloadSize
+	}
+
+	_, err := p.WriteTo(e.bw)
+	return err
+}
+
+// SetMaxSize limits the values Encode accepts to n bytes, for example after NegotiateMaxSize.
+//   - Zero means MaxPayloadSize, the limit of every payload's WriteTo.
+func (e *BufferedEncoder) SetMaxSize(n uint32) { e.maxSize = n }
+
+// Buffered returns the number of bytes waiting to be flushed.
+func (e *BufferedEncoder) Buffered() int { return e.bw.Buffered() }
+
+// Flush writes every buffered frame to the underlying writer.
+func (e *BufferedEncoder) Flush() error { return e.bw.Flush() }
+
+// Close flushes the buffer and then closes the underlying writer if it is an `io.Closer`.
+//   - The flush happens first, so no frame is lost because the connection closed too early.
+//   - The writer is closed even if the flush fails; the flush error is returned in that case.
+func (e *BufferedEncoder) Close() error {
+	err := e.bw.Flush()
+
+	if c, ok := e.w.(io.Closer); ok {
+		if cErr := c.Close(); err == nil {
+			err = cErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,149 @@
+package ch04
+
+import (
+	"io"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// countingWriter counts how many times Write is called.
+//   - Each call on a real `net.Conn` is (at least) one system call,
+//     so the counter is a stand-in for the number of syscalls.
+type countingWriter struct {
+	writes int
+	bytes  int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.writes++
+	c.bytes += len(p)
+	return len(p), nil
+}
+
+// This test sends frames through a BufferedEncoder over a real TCP connection and then closes the encoder.
+//   - If Close forgot to flush, the client would miss the last frames.
+func TestBufferedEncoder(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	b1 := Binary("Clear is better than clever.")
+	s1 := String("Errors are values.")
+	b2 := Binary("Don't panic.")
+	payloads := []Payload{&b1, &s1, &b2}
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		enc := NewBufferedEncoder(conn, 1024)
+		for _, p := range payloads {
+			if err := enc.Encode(p); err != nil {
+				t.Error(err)
+				break
+			}
+		}
+
+		// All three frames fit in the buffer, so nothing has been sent yet.
+		if enc.Buffered() == 0 {
+			t.Error("expected buffered frames before Close")
+		}
+
+		if err := enc.Close(); err != nil { // flushes, then closes conn
+			t.Error(err)
+		}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < len(payloads); i++ {
+		actual, err := decode(conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := payloads[i]; !reflect.DeepEqual(expected, actual) {
+			t.Errorf("value mismatch: %v != %v", expected, actual)
+		}
+	}
+
+	if _, err = decode(conn); err != io.EOF {
+		t.Errorf("expected EOF after the last frame; actual: %v", err)
+	}
+}
+
+// This test checks that coalescing really reduces the number of writes.
+func TestBufferedEncoderCoalesces(t *testing.T) {
+	var direct, buffered countingWriter
+	p := Binary("x")
+
+	for i := 0; i < 100; i++ {
+		if _, err := p.WriteTo(&direct); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	enc := NewBufferedEncoder(&buffered, 4096)
+	for i := 0; i < 100; i++ {
+		if err := enc.Encode(&p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if direct.bytes != buffered.bytes {
+		t.Fatalf("expected %d bytes; actual: %d", direct.bytes, buffered.bytes)
+	}
+	if buffered.writes != 1 {
+		t.Errorf("expected a single coalesced write; actual: %d", buffered.writes)
+	}
+	t.Logf("direct writes: %d, buffered writes: %d", direct.writes, buffered.writes)
+}
+
+// BenchmarkEncode compares the number of writes (syscalls on a real connection) for 10000 tiny frames.
+//   - Run it with: go test -bench Encode -run ^$
+//   - The "writes/op" metric shows the difference between the two approaches.
+func BenchmarkEncode(b *testing.B) {
+	const frames = 10000
+	p := Binary("x")
+
+	b.Run("direct", func(b *testing.B) {
+		var w countingWriter
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < frames; j++ {
+				if _, err := p.WriteTo(&w); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		b.ReportMetric(float64(w.writes)/float64(b.N), "writes/op")
+	})
+
+	b.Run("coalesced", func(b *testing.B) {
+		var w countingWriter
+		for i := 0; i < b.N; i++ {
+			enc := NewBufferedEncoder(&w, 0)
+			for j := 0; j < frames; j++ {
+				if err := enc.Encode(&p); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := enc.Flush(); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(w.writes)/float64(b.N), "writes/op")
+	})
+}
@@ -0,0 +1,63 @@
+package ch04
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ## Frames with a Different Byte Order
+// The TLV length is big-endian ("network byte order"), like almost every network protocol.
+// Some systems we have to talk to wrote it little-endian instead. Only the 4-byte length is affected:
+// the type is a single byte and the value is opaque bytes.
+//	- DecoderOptions.ByteOrder and TLVCodec.ByteOrder choose the order of the length; nil means big-endian.
+//	- The payload types themselves always read and write big-endian. So the Decoder and the codec translate:
+//		- reading: the header's length is rewritten to big-endian before the payload's ReadFrom sees it
+//		- writing: the frame is built in memory and its length rewritten before it is written
+//	- Both sides must agree: a big-endian reader takes a little-endian length of 3 (03 00 00 00) for 50331648.
+
+// isBigEndian reports whether order is big-endian (nil counts as the default, big-endian).
+func isBigEndian(order binary.ByteOrder) bool {
+	return order == nil || order == binary.BigEndian
+}
+
+// reorderLength rewrites the length in a TLV header from one byte order to the other.
+func reorderLength(header []byte, from, to binary.ByteOrder) {
+	to.PutUint32(header[1:tlvHeaderSize], from.Uint32(header[1:tlvHeaderSize]))
+}
+
+// writeOrdered writes the frame of p with its length in order.
+func writeOrdered(w io.Writer, p Payload, order binary.ByteOrder) error {
+	if isBigEndian(order) {
+		_, err := p.WriteTo(w)
+		return err
+	}
+
+	var frame bytes.Buffer
+	if _, err := p.WriteTo(&frame); err != nil {
+		return err
+	}
+	reorderLength(frame.Bytes(), binary.BigEndian, order)
+
+	_, err := w.Write(frame.Bytes())
+	return err
+}
+
+// readOrdered decodes a frame whose length is in order through reg.
+func readOrdered(r io.Reader, reg *Registry, order binary.ByteOrder) (Payload, error) {
+	if isBigEndian(order) {
+		return reg.Decode(r)
+	}
+
+	var header [tlvHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrTruncatedFrame
+		}
+		return nil, err
+	}
+	reorderLength(header[:], order, binary.BigEndian)
+
+	return reg.Decode(io.MultiReader(bytes.NewReader(header[:]), r))
+}
@@ -0,0 +1,63 @@
+package ch04
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLittleEndianRoundTrip(t *testing.T) {
+	b := Binary("little")
+	s := String("endian")
+	payloads := []Payload{&b, &s}
+
+	var stream bytes.Buffer
+	enc := NewEncoder(&stream, TLVCodec{ByteOrder: binary.LittleEndian})
+	for _, p := range payloads {
+		if err := enc.Encode(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The length really is little-endian on the wire.
+	if length := binary.LittleEndian.Uint32(stream.Bytes()[1:tlvHeaderSize]); length != uint32(len(b)) {
+		t.Fatalf("expected a little-endian length of %d; actual: % x", len(b), stream.Bytes()[1:tlvHeaderSize])
+	}
+
+	decoders := map[string]*Decoder{
+		"options": NewDecoder(bytes.NewReader(stream.Bytes()), DecoderOptions{ByteOrder: binary.LittleEndian}),
+		"codec":   NewDecoder(bytes.NewReader(stream.Bytes()), DecoderOptions{Codec: TLVCodec{ByteOrder: binary.LittleEndian}}),
+	}
+	for name, dec := range decoders {
+		for i, expected := range payloads {
+			actual, err := dec.Decode()
+			if err != nil {
+				t.Fatalf("%s: payload %d: %v", name, i, err)
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s: payload %d: expected %#v; actual: %#v", name, i, expected, actual)
+			}
+		}
+	}
+}
+
+// A big-endian reader takes the little-endian length 06 00 00 00 for 100663296 bytes: the setting matters.
+func TestByteOrderMismatch(t *testing.T) {
+	var frame bytes.Buffer
+	p := Binary("little")
+	if err := (TLVCodec{ByteOrder: binary.LittleEndian}).Encode(&frame, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	misread := binary.BigEndian.Uint32(frame.Bytes()[1:tlvHeaderSize])
+	if misread == uint32(len(p)) {
+		t.Fatalf("big-endian read of the length should differ from %d", len(p))
+	}
+
+	_, err := NewDecoder(&frame, DecoderOptions{}).Decode()
+	if !errors.Is(err, ErrMaxPayloadSize) {
+		t.Errorf("expected the misread length %d to exceed MaxPayloadSize; actual: %v", misread, err)
+	}
+}
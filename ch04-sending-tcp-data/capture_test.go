@@ -0,0 +1,159 @@
+package ch04
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// capture returns the frames of a short recorded session.
+func capture(t *testing.T) ([]Payload, []byte) {
+	t.Helper()
+
+	b := Binary("\x00\x01\x02")
+	s := String("captured")
+	ping := Ping("1")
+	empty := Binary("")
+	payloads := []Payload{&b, &s, &ping, &empty}
+
+	var buf bytes.Buffer
+	for _, p := range payloads {
+		if _, err := p.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return payloads, buf.Bytes()
+}
+
+// decoders lists both ways of decoding a stream.
+var decoders = map[string]func(r io.Reader) func() (Payload, error){
+	"Decode": func(r io.Reader) func() (Payload, error) {
+		return func() (Payload, error) { return Decode(r) }
+	},
+	"Decoder": func(r io.Reader) func() (Payload, error) {
+		return NewDecoder(r, DecoderOptions{}).Decode
+	},
+}
+
+func TestDecodeCapture(t *testing.T) {
+	payloads, stream := capture(t)
+
+	for name, newDecode := range decoders {
+		t.Run(name, func(t *testing.T) {
+			decode := newDecode(bytes.NewReader(stream))
+			for i, expected := range payloads {
+				actual, err := decode()
+				if err != nil {
+					t.Fatalf("frame %d: %v", i, err)
+				}
+				if !bytes.Equal(actual.Bytes(), expected.Bytes()) {
+					t.Errorf("frame %d: expected %q; actual: %q", i, expected, actual)
+				}
+			}
+
+			// The capture ends at a frame boundary: a clean io.EOF.
+			if _, err := decode(); err != io.EOF {
+				t.Errorf("expected io.EOF at the end; actual: %v", err)
+			}
+		})
+	}
+}
+
+func TestDecodeTruncatedCapture(t *testing.T) {
+	_, stream := capture(t)
+	first := tlvHeaderSize + 3 // the Binary frame
+
+	cuts := map[string]int{
+		"inside the header":     first + 2,
+		"right after a header":  first + tlvHeaderSize,
+		"inside a value":        first + tlvHeaderSize + 4,
+		"inside the last frame": len(stream) - 1,
+	}
+
+	for name, newDecode := range decoders {
+		for cutName, cut := range cuts {
+			t.Run(name+"/"+cutName, func(t *testing.T) {
+				decode := newDecode(bytes.NewReader(stream[:cut]))
+
+				var err error
+				for err == nil {
+					_, err = decode()
+				}
+				if !errors.Is(err, ErrTruncatedFrame) {
+					t.Fatalf("expected ErrTruncatedFrame; actual: %v", err)
+				}
+				if !errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+					t.Errorf("expected the error to be io.ErrUnexpectedEOF but not io.EOF; actual: %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestReadFrameBoundaries(t *testing.T) {
+	ping := Ping("ping")
+	readers := map[string]struct {
+		frame Payload // the frame the reader expects
+		read  func(io.Reader) error
+	}{
+		"Decoder": {&ping, func(r io.Reader) error {
+			_, err := NewDecoder(r, DecoderOptions{}).Decode()
+			return err
+		}},
+		"readTLV": {&ping, func(r io.Reader) error {
+			var p Ping
+			_, err := p.ReadFrom(r)
+			return err
+		}},
+		"Raw": {&ping, func(r io.Reader) error {
+			var raw Raw
+			_, err := raw.ReadFrom(r)
+			return err
+		}},
+		"Binary.ReadFrom": {ptr(Binary("binary")), func(r io.Reader) error {
+			var b Binary
+			_, err := b.ReadFrom(r)
+			return err
+		}},
+		"String.ReadFrom": {ptr(String("string")), func(r io.Reader) error {
+			var s String
+			_, err := s.ReadFrom(r)
+			return err
+		}},
+	}
+
+	cases := []struct {
+		name     string
+		cut      int
+		expected error
+	}{
+		{"EOF before any byte", 0, io.EOF},
+		{"EOF after 2 of 5 header bytes", 2, ErrTruncatedFrame},
+		{"EOF after a partial value", tlvHeaderSize + 2, ErrTruncatedFrame},
+	}
+
+	for name, reader := range readers {
+		var frame bytes.Buffer
+		if _, err := reader.frame.WriteTo(&frame); err != nil {
+			t.Fatal(err)
+		}
+		stream := frame.Bytes()
+
+		for _, c := range cases {
+			t.Run(name+"/"+c.name, func(t *testing.T) {
+				err := reader.read(bytes.NewReader(stream[:c.cut]))
+				if c.expected == io.EOF {
+					if err != io.EOF { // callers compare with ==, so it must be the bare io.EOF
+						t.Errorf("expected io.EOF; actual: %v", err)
+					}
+					return
+				}
+				if !errors.Is(err, c.expected) {
+					t.Errorf("expected %v; actual: %v", c.expected, err)
+				}
+			})
+		}
+	}
+}
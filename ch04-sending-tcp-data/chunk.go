@@ -0,0 +1,184 @@
+package ch04
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ## Splitting a Large Value into Chunks
+// Some protocols cap the frame size far below MaxPayloadSize (say 64KB), so small messages never wait
+// behind a huge one. A larger value then travels as a sequence of Chunk frames:
+//	- [ChunkType][Length] [Seq: 4 bytes][Flags: 1 byte][Data]
+//	- Seq counts the chunks of one value from 0. The last chunk has the final flag set.
+//	- ChunkedWriter splits a value into chunks of at most chunkSize data bytes.
+//	- ChunkedReader reads chunks until the final one and returns the reassembled value.
+//	- Chunks lift the frame limit, not the value limit: by default a reassembled value may not exceed
+//	  MaxPayloadSize either. A reader that really wants no limit has to ask for it with NoMaxSize.
+// On TCP chunks can't arrive out of order or go missing, but ChunkedReader checks Seq anyway:
+//	- a wrong Seq means a buggy sender (or a stream that lost sync), and the reassembled value would be garbage.
+//	- it fails with ErrChunkSequence instead of returning it.
+
+// ChunkType is the type byte of a Chunk frame.
+const ChunkType uint8 = 8
+
+const (
+	chunkHeaderSize = 5 // Seq and Flags
+	chunkFinal      = 1 // Flags bit of the last chunk
+)
+
+// DefaultChunkSize is used by NewChunkedWriter when chunkSize is zero.
+const DefaultChunkSize = 64 << 10
+
+// NoMaxSize, as ChunkedReader.MaxSize, lets a reassembled value grow without limit.
+const NoMaxSize = -1
+
+var (
+	// ErrInvalidChunk is returned for a Chunk frame whose value is shorter than the chunk header,
+	// or whose flags have a bit other than the final flag set.
+	ErrInvalidChunk = errors.New("invalid Chunk")
+	// ErrChunkSequence is returned by ChunkedReader when a chunk is missing, repeated or out of order.
+	ErrChunkSequence = errors.New("chunk out of sequence")
+)
+
+func init() {
+	Register(ChunkType, func() Payload { return new(Chunk) })
+}
+
+// Chunk is one piece of a value split by ChunkedWriter.
+type Chunk struct {
+	Seq   uint32
+	Final bool
+	Data  []byte
+}
+
+// Bytes returns the encoded value: the chunk header followed by the data.
+func (m Chunk) Bytes() []byte {
+	value := make([]byte, 0, chunkHeaderSize+len(m.Data))
+	value = binary.BigEndian.AppendUint32(value, m.Seq)
+	var flags byte
+	if m.Final {
+		flags |= chunkFinal
+	}
+	value = append(value, flags)
+	return append(value, m.Data...)
+}
+
+func (m Chunk) String() string {
+	return fmt.Sprintf("chunk %d (%d bytes, final: %t)", m.Seq, len(m.Data), m.Final)
+}
+
+func (m Chunk) WriteTo(w io.Writer) (int64, error) { return writeTLV(w, ChunkType, m.Bytes()) }
+
+// ReadFrom reads a Chunk frame.
+func (m *Chunk) ReadFrom(r io.Reader) (int64, error) {
+	value, n, err := readTLV(r, ChunkType, "Chunk")
+	if err != nil {
+		return n, err
+	}
+	if len(value) < chunkHeaderSize {
+		return n, fmt.Errorf("%w: value too short", ErrInvalidChunk)
+	}
+	if flags := value[4]; flags&^chunkFinal != 0 {
+		return n, fmt.Errorf("%w: unknown flags %#x", ErrInvalidChunk, flags) // it wouldn't encode back to the same frame
+	}
+
+	m.Seq = binary.BigEndian.Uint32(value)
+	m.Final = value[4]&chunkFinal != 0
+	m.Data = value[chunkHeaderSize:]
+	return n, nil
+}
+
+// clampChunkSize returns DefaultChunkSize for a chunkSize of zero or less,
+// and lowers one that doesn't fit in a frame along with the chunk header.
+func clampChunkSize(chunkSize int) int {
+	if chunkSize <= 0 {
+		return DefaultChunkSize
+	}
+	return min(chunkSize, int(MaxPayloadSize)-chunkHeaderSize)
+}
+
+// ChunkedWriter writes every value passed to Write as a sequence of Chunk frames.
+type ChunkedWriter struct {
+	w    io.Writer
+	size int
+}
+
+// NewChunkedWriter returns a ChunkedWriter that puts at most chunkSize bytes of data in each chunk.
+//   - Zero means DefaultChunkSize; a size that doesn't fit in a frame is lowered to fit.
+func NewChunkedWriter(w io.Writer, chunkSize int) *ChunkedWriter {
+	return &ChunkedWriter{w: w, size: clampChunkSize(chunkSize)}
+}
+
+// Write sends p as one value: chunks 0, 1, ... with the final flag on the last one.
+//   - An empty p is sent as a single empty final chunk.
+func (cw *ChunkedWriter) Write(p []byte) (int, error) {
+	var written int
+	for seq := uint32(0); ; seq++ {
+		n := min(cw.size, len(p)-written)
+		chunk := Chunk{Seq: seq, Final: written+n == len(p), Data: p[written : written+n]}
+
+		if _, err := chunk.WriteTo(cw.w); err != nil {
+			return written, err
+		}
+		written += n
+
+		if chunk.Final {
+			return written, nil
+		}
+	}
+}
+
+// ChunkedReader reassembles the values written by a ChunkedWriter.
+type ChunkedReader struct {
+	r io.Reader
+
+	// MaxSize limits the size of a reassembled value; zero means MaxPayloadSize and NoMaxSize means no limit.
+	// A bigger value fails with ErrMaxPayloadSize.
+	MaxSize int
+}
+
+// NewChunkedReader returns a ChunkedReader reading Chunk frames from r.
+func NewChunkedReader(r io.Reader) *ChunkedReader {
+	return &ChunkedReader{r: r}
+}
+
+// ReadValue reads chunks up to and including the final one and returns the reassembled value.
+//   - io.EOF means the stream ended cleanly between two values.
+//   - If it ends after the first chunk of a value, the error is io.ErrUnexpectedEOF.
+func (cr *ChunkedReader) ReadValue() ([]byte, error) {
+	var value []byte
+	for seq := uint32(0); ; seq++ {
+		var chunk Chunk
+		if _, err := chunk.ReadFrom(cr.r); err != nil {
+			if err == io.EOF && seq > 0 {
+				err = io.ErrUnexpectedEOF // the value is incomplete
+			}
+			return nil, err
+		}
+
+		if chunk.Seq != seq {
+			return nil, fmt.Errorf("%w: expected %d; got %d", ErrChunkSequence, seq, chunk.Seq)
+		}
+		if limit := cr.maxSize(); limit >= 0 && len(value)+len(chunk.Data) > limit {
+			return nil, ErrMaxPayloadSize
+		}
+		value = append(value, chunk.Data...)
+
+		if chunk.Final {
+			return value, nil
+		}
+	}
+}
+
+// maxSize returns the effective MaxSize; a negative value means no limit.
+func (cr *ChunkedReader) maxSize() int {
+	switch {
+	case cr.MaxSize == 0:
+		return int(MaxPayloadSize)
+	case cr.MaxSize < 0:
+		return NoMaxSize
+	}
+	return cr.MaxSize
+}
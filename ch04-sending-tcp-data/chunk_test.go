@@ -0,0 +1,132 @@
+package ch04
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestChunkedRoundTrip(t *testing.T) {
+	payload := make([]byte, 3<<20) // 3MB
+	if _, err := rand.Read(payload); err != nil {
+		t.Fatal(err)
+	}
+	const chunkSize = 64 << 10
+
+	var stream bytes.Buffer
+	w := NewChunkedWriter(&stream, chunkSize)
+	n, err := w.Write(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(payload) {
+		t.Fatalf("expected %d bytes written; actual: %d", len(payload), n)
+	}
+	if _, err = w.Write(nil); err != nil { // a second, empty value
+		t.Fatal(err)
+	}
+
+	// 48 chunks of 64KB for the payload, one empty chunk for the second value.
+	frames := len(payload)/chunkSize + 1
+	if expected := len(payload) + frames*(tlvHeaderSize+chunkHeaderSize); stream.Len() != expected {
+		t.Fatalf("expected a %d-byte stream; actual: %d", expected, stream.Len())
+	}
+
+	r := NewChunkedReader(&stream)
+	value, err := r.ReadValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(value, payload) {
+		t.Fatal("the reassembled value differs from the payload")
+	}
+
+	if value, err = r.ReadValue(); err != nil || len(value) != 0 {
+		t.Fatalf("expected an empty value; actual: %d bytes, %v", len(value), err)
+	}
+	if _, err = r.ReadValue(); err != io.EOF {
+		t.Errorf("expected io.EOF after the last value; actual: %v", err)
+	}
+}
+
+func TestChunkedReaderSequence(t *testing.T) {
+	tests := map[string][]Chunk{
+		"gap":          {{Seq: 0, Data: []byte("a")}, {Seq: 2, Final: true, Data: []byte("c")}},
+		"out of order": {{Seq: 1, Data: []byte("b")}, {Seq: 0, Final: true, Data: []byte("a")}},
+		"repeated":     {{Seq: 0, Data: []byte("a")}, {Seq: 0, Final: true, Data: []byte("a")}},
+	}
+
+	for name, chunks := range tests {
+		t.Run(name, func(t *testing.T) {
+			var stream bytes.Buffer
+			for _, c := range chunks {
+				if _, err := c.WriteTo(&stream); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := NewChunkedReader(&stream).ReadValue(); !errors.Is(err, ErrChunkSequence) {
+				t.Errorf("expected ErrChunkSequence; actual: %v", err)
+			}
+		})
+	}
+}
+
+func TestChunkedReaderErrors(t *testing.T) {
+	var stream bytes.Buffer
+	if _, err := NewChunkedWriter(&stream, 4).Write([]byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	frame := tlvHeaderSize + chunkHeaderSize + 4
+
+	t.Run("value ends early", func(t *testing.T) {
+		_, err := NewChunkedReader(bytes.NewReader(stream.Bytes()[:frame])).ReadValue()
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("expected io.ErrUnexpectedEOF; actual: %v", err)
+		}
+	})
+
+	t.Run("value too big", func(t *testing.T) {
+		r := NewChunkedReader(bytes.NewReader(stream.Bytes()))
+		r.MaxSize = 8
+		if _, err := r.ReadValue(); !errors.Is(err, ErrMaxPayloadSize) {
+			t.Errorf("expected ErrMaxPayloadSize; actual: %v", err)
+		}
+	})
+
+	t.Run("default limit", func(t *testing.T) {
+		var big bytes.Buffer
+		if _, err := NewChunkedWriter(&big, 0).Write(make([]byte, MaxPayloadSize+1)); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewChunkedReader(bytes.NewReader(big.Bytes())).ReadValue(); !errors.Is(err, ErrMaxPayloadSize) {
+			t.Errorf("expected ErrMaxPayloadSize; actual: %v", err)
+		}
+
+		r := NewChunkedReader(bytes.NewReader(big.Bytes()))
+		r.MaxSize = NoMaxSize
+		if value, err := r.ReadValue(); err != nil || len(value) != int(MaxPayloadSize)+1 {
+			t.Errorf("expected %d bytes with NoMaxSize; actual: %d, %v", MaxPayloadSize+1, len(value), err)
+		}
+	})
+
+	t.Run("unknown flags", func(t *testing.T) {
+		frame := []byte{ChunkType, 0, 0, 0, chunkHeaderSize, 0, 0, 0, 0, 0x30}
+		if _, err := Decode(bytes.NewReader(frame)); !errors.Is(err, ErrInvalidChunk) {
+			t.Errorf("expected ErrInvalidChunk; actual: %v", err)
+		}
+	})
+
+	t.Run("Decode", func(t *testing.T) {
+		p, err := Decode(bytes.NewReader(stream.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c, ok := p.(*Chunk); !ok || c.Seq != 0 || c.Final || string(c.Data) != "0123" {
+			t.Errorf("expected the first chunk; actual: %v", p)
+		}
+	})
+}
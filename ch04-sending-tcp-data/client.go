@@ -0,0 +1,171 @@
+package ch04
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ## Matching Responses to Requests
+// ExchangeContext writes a request and reads "the next frame" as its response.
+// That only works if one request is in flight at a time: with two concurrent requests nobody knows which response is whose.
+//	- Client puts an 8-byte request ID in front of every frame:
+//		- [Request ID:8 bytes][Type:1 byte][Length:4 bytes][Value:Length bytes]
+//	- The server copies the ID from the request to its response, so the responses may come back in any order.
+//	- Every Call registers a channel for its ID in the pending map and waits on it.
+//	- A single reader goroutine reads all responses and delivers each one to the channel of its ID.
+//	- A canceled Call removes its ID, so a response that arrives later is simply dropped.
+//	- CallTimeout is Call with a context.WithTimeout made for you: after timeout it returns context.DeadlineExceeded,
+//	  its ID is gone from the pending map, and a late response is dropped like any other.
+//	- If the connection fails, every waiting Call returns the reader's error.
+
+// requestIDSize is the size of the request ID in front of every frame.
+const requestIDSize = 8
+
+// Client sends requests over one connection and matches the responses by request ID.
+//   - It is safe for concurrent use.
+type Client struct {
+	conn net.Conn
+
+	writeMu sync.Mutex // one frame at a time on the wire
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan Payload
+	err     error         // set when the reader stops
+	done    chan struct{} // closed when the reader stops
+}
+
+// NewClient starts reading responses from conn.
+func NewClient(conn net.Conn) *Client {
+	c := &Client{
+		conn:    conn,
+		pending: make(map[uint64]chan Payload),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+
+	return c
+}
+
+// Call sends p and waits for the response with the same request ID, or until ctx is done.
+func (c *Client) Call(ctx context.Context, p Payload) (Payload, error) {
+	// 1) Register before writing, so even an immediate response finds its channel.
+	response := make(chan Payload, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = response
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	// 2) Send the request.
+	c.writeMu.Lock()
+	err := writeCorrelated(c.conn, id, p)
+	c.writeMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// 3) Wait for our own response.
+	select {
+	case r := <-response:
+		return r, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, c.err
+	}
+}
+
+// CallTimeout sends p and waits at most timeout for the response.
+//   - On timeout it returns context.DeadlineExceeded; the request is no longer pending, so its response is dropped.
+func (c *Client) CallTimeout(p Payload, timeout time.Duration) (Payload, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.Call(ctx, p)
+}
+
+// Close closes the connection; pending and future Calls return an error.
+func (c *Client) Close() error {
+	err := c.conn.Close()
+	<-c.done
+
+	return err
+}
+
+// readLoop delivers every response to the Call waiting for its ID.
+func (c *Client) readLoop() {
+	var err error
+	defer func() {
+		c.mu.Lock()
+		if err == io.EOF {
+			err = net.ErrClosed
+		}
+		c.err = err
+		c.mu.Unlock()
+		close(c.done)
+	}()
+
+	for {
+		var id uint64
+		var p Payload
+		id, p, err = readCorrelated(c.conn)
+		if err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		response, ok := c.pending[id]
+		c.mu.Unlock()
+		if ok {
+			select {
+			case response <- p: // buffered: the first response for an ID always fits
+			default: // a duplicate response for the same ID; nobody wants it
+			}
+		}
+	}
+}
+
+// writeCorrelated writes one frame preceded by its request ID.
+//   - The ID and the frame are assembled first and sent with a single Write.
+func writeCorrelated(w io.Writer, id uint64, p Payload) error {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.BigEndian, id) // 8-byte request ID
+	if _, err := p.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// readCorrelated reads one request ID and the frame following it.
+func readCorrelated(r io.Reader) (uint64, Payload, error) {
+	var id uint64
+	if err := binary.Read(r, binary.BigEndian, &id); err != nil {
+		return 0, nil, err
+	}
+
+	p, err := Decode(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return id, p, nil
+}
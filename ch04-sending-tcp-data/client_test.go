@@ -0,0 +1,179 @@
+package ch04
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// serveCorrelated answers every request on conn in its own goroutine after a random delay,
+// so responses go back in a different order than the requests came in.
+func serveCorrelated(conn net.Conn) {
+	var writeMu sync.Mutex
+	for {
+		id, p, err := readCorrelated(conn)
+		if err != nil {
+			return
+		}
+
+		go func() {
+			time.Sleep(time.Duration(rand.IntN(20)) * time.Millisecond)
+
+			response := String("response to " + p.String())
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			_ = writeCorrelated(conn, id, &response)
+		}()
+	}
+}
+
+func TestClientConcurrentCalls(t *testing.T) {
+	server, conn := net.Pipe()
+	defer server.Close()
+	go serveCorrelated(server)
+
+	client := NewClient(conn)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Go(func() {
+			request := String(fmt.Sprintf("request %d", i))
+			response, err := client.Call(ctx, &request)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if expected := "response to request " + fmt.Sprint(i); response.String() != expected {
+				t.Errorf("expected %q; actual: %q", expected, response)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// A canceled Call returns ctx.Err(), and its late response doesn't disturb the next Call.
+func TestClientCallCanceled(t *testing.T) {
+	server, conn := net.Pipe()
+	defer server.Close()
+
+	client := NewClient(conn)
+	defer client.Close()
+
+	requests := make(chan uint64)
+	go func() {
+		for {
+			id, _, err := readCorrelated(server)
+			if err != nil {
+				return
+			}
+			requests <- id
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() {
+		request := String("slow")
+		_, err := client.Call(ctx, &request)
+		errc <- err
+	}()
+	first := <-requests
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("expected context.Canceled; actual: %v", err)
+	}
+
+	go func() {
+		second := <-requests
+		late := String("late")
+		_ = writeCorrelated(server, first, &late)
+		onTime := String("on time")
+		_ = writeCorrelated(server, second, &onTime)
+	}()
+
+	request := String("fast")
+	response, err := client.Call(context.Background(), &request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.String() != "on time" {
+		t.Fatalf("expected %q; actual: %q", "on time", response)
+	}
+}
+
+// The responder answers after the timeout: CallTimeout gives up with DeadlineExceeded,
+// the request is removed from the pending map, and the late response is dropped.
+func TestClientCallTimeout(t *testing.T) {
+	server, conn := net.Pipe()
+	defer server.Close()
+
+	client := NewClient(conn)
+	defer client.Close()
+
+	const timeout = 50 * time.Millisecond
+	answered := make(chan struct{})
+	go func() {
+		defer close(answered)
+		id, _, err := readCorrelated(server)
+		if err != nil {
+			return
+		}
+		time.Sleep(3 * timeout)
+		late := String("late")
+		_ = writeCorrelated(server, id, &late)
+	}()
+
+	request := String("slow")
+	start := time.Now()
+	if _, err := client.CallTimeout(&request, timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded; actual: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*timeout {
+		t.Errorf("expected to give up after %s; took %s", timeout, elapsed)
+	}
+
+	client.mu.Lock()
+	pending := len(client.pending)
+	client.mu.Unlock()
+	if pending != 0 {
+		t.Errorf("expected no pending requests after the timeout; actual: %d", pending)
+	}
+
+	// The late response arrives and is dropped; the client keeps working.
+	<-answered
+	go serveCorrelated(server)
+	response, err := client.CallTimeout(ptr(String("next")), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "response to next"; response.String() != expected {
+		t.Errorf("expected %q; actual: %q", expected, response)
+	}
+}
+
+// When the connection goes away, a waiting Call returns instead of hanging.
+func TestClientConnectionLost(t *testing.T) {
+	server, conn := net.Pipe()
+	client := NewClient(conn)
+	defer client.Close()
+
+	go func() {
+		_, _, _ = readCorrelated(server)
+		_ = server.Close()
+	}()
+
+	request := String("anyone?")
+	if _, err := client.Call(context.Background(), &request); err == nil {
+		t.Fatal("expected an error after the connection was lost")
+	}
+}
@@ -0,0 +1,48 @@
+package ch04
+
+import (
+	"errors"
+	"net"
+)
+
+// ## Half-Closing Any Connection
+// `*net.TCPConn` has CloseWrite, but the connections we actually hold often aren't one:
+//	- `*tls.Conn` has its own CloseWrite (it sends close_notify, then half-closes the TCP connection below).
+//	- Our wrappers (ageConn, goAwayConn, the chapter 3 hooked connections, ...) hide the method, but expose NetConn.
+//	- net.Pipe and most test doubles can't be half-closed at all.
+// closeWrite asks for the method, not for a type:
+//	- it looks for `interface{ CloseWrite() error }` on conn, then on the connections it wraps (through NetConn)
+//	- when nothing has it, it returns ErrCloseWriteUnsupported and leaves conn open.
+//	  The caller decides what to do instead; usually that's a full Close.
+
+// ErrCloseWriteUnsupported is returned by closeWrite for a connection that can't be half-closed.
+var ErrCloseWriteUnsupported = errors.New("connection does not support CloseWrite")
+
+// closeWrite half-closes conn, looking through wrappers for a CloseWrite method.
+//   - It returns ErrCloseWriteUnsupported, without touching conn, when there is none.
+func closeWrite(conn net.Conn) error {
+	if cw, ok := findCloseWriter(conn); ok {
+		return cw.CloseWrite()
+	}
+
+	return ErrCloseWriteUnsupported
+}
+
+// closeWriter is implemented by connections that can be half-closed, like `*net.TCPConn` and `*tls.Conn`.
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// findCloseWriter looks for a CloseWrite method on conn or the connections it wraps (through NetConn).
+func findCloseWriter(conn net.Conn) (closeWriter, bool) {
+	for {
+		switch c := conn.(type) {
+		case closeWriter:
+			return c, true
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil, false
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package ch04
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCloseWrite(t *testing.T) {
+	t.Run("TCP", func(t *testing.T) {
+		client, server := tcpPair(t)
+
+		if err := closeWrite(client); err != nil {
+			t.Fatal(err)
+		}
+		// The server reads EOF but can still answer: only one direction is closed.
+		if _, err := server.Read(make([]byte, 1)); err != io.EOF {
+			t.Fatalf("expected io.EOF; actual: %v", err)
+		}
+		if _, err := server.Write([]byte("still open")); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len("still open"))
+		if _, err := io.ReadFull(client, buf); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("wrapped TCP", func(t *testing.T) {
+		client, server := tcpPair(t)
+
+		if err := closeWrite(&goAwayConn{Conn: client}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := server.Read(make([]byte, 1)); err != io.EOF {
+			t.Fatalf("expected io.EOF; actual: %v", err)
+		}
+	})
+
+	t.Run("pipe", func(t *testing.T) {
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		if err := closeWrite(client); !errors.Is(err, ErrCloseWriteUnsupported) {
+			t.Fatalf("expected ErrCloseWriteUnsupported; actual: %v", err)
+		}
+
+		// The connection was left alone: it still works in both directions.
+		go func() { _, _ = client.Write([]byte("x")) }()
+		_ = server.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := server.Read(make([]byte, 1)); err != nil {
+			t.Fatalf("expected the pipe to be open; actual: %v", err)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package ch04
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ## Swapping the Wire Format with a Codec
+// So far the wire format is hard-wired into the call sites: p.WriteTo(w) on one side, Decode(r) on the other.
+// Switching to another format (the versioned frames, or something new) would mean touching every one of them.
+//	- A Codec bundles "how to write a payload" and "how to read one" behind a single interface.
+//	- Code that talks through an Encoder and a Decoder only needs a different Codec to change the format.
+//	- TLVCodec is the format we've used all along: [Type][Length][Value].
+//	- VersionedCodec is the versioned format from versioned.go: [Version][Type][Length][Value].
+//
+// ### Intercepting outgoing frames
+// An Encoder can show every frame to a WriteInterceptor before writing it, for debugging or to enforce a policy
+// ("no String frames on this connection", "no Binary over 1MB").
+//	- The interceptor gets the frame's type byte and value length.
+//	- Returning an error aborts the Encode. The interceptor runs before the codec writes anything,
+//	  so a rejected frame never leaves a partial frame in the stream.
+//	- Type and length come from the payload's own TLV header, whatever the Codec is.
+
+// Codec writes and reads payloads in one wire format.
+type Codec interface {
+	Encode(w io.Writer, p Payload) error
+	Decode(r io.Reader) (Payload, error)
+}
+
+// TLVCodec is the plain TLV format.
+//   - Registry is used to decode frames; nil means DefaultRegistry.
+//   - ByteOrder is the byte order of the length; nil means big-endian (see byte_order.go).
+type TLVCodec struct {
+	Registry  *Registry
+	ByteOrder binary.ByteOrder
+}
+
+// Encode writes the TLV frame of p.
+func (c TLVCodec) Encode(w io.Writer, p Payload) error {
+	return writeOrdered(w, p, c.ByteOrder)
+}
+
+// Decode reads one TLV frame.
+func (c TLVCodec) Decode(r io.Reader) (Payload, error) {
+	reg := c.Registry
+	if reg == nil {
+		reg = DefaultRegistry
+	}
+
+	return readOrdered(r, reg, c.ByteOrder)
+}
+
+// VersionedCodec is the TLV format with a leading ProtocolVersion byte.
+type VersionedCodec struct{}
+
+// Encode writes p with WriteVersioned.
+func (VersionedCodec) Encode(w io.Writer, p Payload) error {
+	_, err := WriteVersioned(w, p)
+	return err
+}
+
+// Decode reads a frame with ReadVersioned.
+func (VersionedCodec) Decode(r io.Reader) (Payload, error) { return ReadVersioned(r) }
+
+// Encoder writes payloads to a writer using a Codec.
+type Encoder struct {
+	w     io.Writer
+	codec Codec
+
+	// WriteInterceptor, if set, is called with every frame's type and value length before it is written.
+	// An error aborts the write; nothing of the frame is sent.
+	WriteInterceptor func(typ uint8, length uint32) error
+}
+
+// NewEncoder returns an Encoder writing to w in the format of codec.
+//   - A nil codec means TLVCodec.
+func NewEncoder(w io.Writer, codec Codec) *Encoder {
+	if codec == nil {
+		codec = TLVCodec{}
+	}
+
+	return &Encoder{w: w, codec: codec}
+}
+
+// Encode writes p.
+func (e *Encoder) Encode(p Payload) error {
+	if e.WriteInterceptor != nil {
+		typ, length, err := frameHeader(p)
+		if err != nil {
+			return err
+		}
+		if err = e.WriteInterceptor(typ, length); err != nil {
+			return err
+		}
+	}
+
+	return e.codec.Encode(e.w, p)
+}
+
+// errHeaderCaptured stops a WriteTo as soon as the header has been captured.
+var errHeaderCaptured = errors.New("header captured")
+
+// headerCapture keeps the first tlvHeaderSize bytes written to it, then fails.
+type headerCapture struct {
+	header [tlvHeaderSize]byte
+	n      int
+}
+
+func (h *headerCapture) Write(p []byte) (int, error) {
+	n := copy(h.header[h.n:], p)
+	h.n += n
+	if h.n == tlvHeaderSize {
+		return n, errHeaderCaptured
+	}
+
+	return n, nil
+}
+
+// frameHeader returns the type and value length of p's TLV frame, without encoding the value.
+func frameHeader(p Payload) (uint8, uint32, error) {
+	var h headerCapture
+	if _, err := p.WriteTo(&h); err != nil && !errors.Is(err, errHeaderCaptured) {
+		return 0, 0, err
+	}
+	if h.n < tlvHeaderSize {
+		return 0, 0, io.ErrShortWrite
+	}
+
+	return h.header[0], binary.BigEndian.Uint32(h.header[1:]), nil
+}
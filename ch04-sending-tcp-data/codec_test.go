@@ -0,0 +1,101 @@
+package ch04
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testCodecRoundTrip encodes a mix of payloads with codec and decodes them back through the same interface.
+func testCodecRoundTrip(t *testing.T, codec Codec) {
+	t.Helper()
+
+	b := Binary("Clear is better than clever.")
+	s := String("Errors are values.")
+	ping := Ping("1")
+	empty := String("")
+	payloads := []Payload{&b, &s, &ping, &empty}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, codec)
+	for _, p := range payloads {
+		if err := enc.Encode(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := NewDecoder(&buf, DecoderOptions{Codec: codec})
+	for i, expected := range payloads {
+		actual, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("payload %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("payload %d: expected %#v; actual: %#v", i, expected, actual)
+		}
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("%d bytes left after decoding", buf.Len())
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	codecs := map[string]Codec{
+		"TLV":          TLVCodec{},
+		"TLV registry": TLVCodec{Registry: DefaultRegistry},
+		"versioned":    VersionedCodec{},
+	}
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) { testCodecRoundTrip(t, codec) })
+	}
+}
+
+// The codecs really are different formats: a versioned frame can't be read as plain TLV.
+func TestCodecsDiffer(t *testing.T) {
+	var buf bytes.Buffer
+	s := String("hello")
+	if err := (VersionedCodec{}).Encode(&buf, &s); err != nil {
+		t.Fatal(err)
+	}
+
+	if p, err := (TLVCodec{}).Decode(&buf); err == nil && reflect.DeepEqual(p, &s) {
+		t.Fatal("expected the TLV codec to misread a versioned frame")
+	}
+}
+
+func TestEncoderWriteInterceptor(t *testing.T) {
+	errTooLarge := errors.New("binary frame too large")
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, nil)
+	enc.WriteInterceptor = func(typ uint8, length uint32) error {
+		if typ == BinaryType && length > 8 {
+			return errTooLarge
+		}
+		return nil
+	}
+
+	large := Binary("more than eight bytes")
+	if err := enc.Encode(&large); !errors.Is(err, errTooLarge) {
+		t.Fatalf("expected the interceptor's error; actual: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("a rejected frame left %d bytes in the buffer", buf.Len())
+	}
+
+	// Small Binary frames and other types go through.
+	small := Binary("small")
+	text := String("a String may be longer")
+	for _, p := range []Payload{&small, &text} {
+		if err := enc.Encode(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := tlvHeaderSize + len(small) + tlvHeaderSize + len(text)
+	if buf.Len() != expected {
+		t.Errorf("expected %d bytes written; actual: %d", expected, buf.Len())
+	}
+}
@@ -0,0 +1,184 @@
+package ch04
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ## Capping a Connection's Lifetime
+// Long-lived connections stick to the server they first reached. After a deploy or a scale-up,
+// the new servers get no traffic until the old connections go away. Rotating connections fixes that:
+//	- After MaxConnAge, the Server half-closes the connection (CloseWrite):
+//		- the client reads io.EOF, knows no more responses are coming, and reconnects (probably elsewhere)
+//		- the client can still finish sending what it was sending; the handler can still read it
+//	- The connection is fully closed when the handler returns, or at the latest connAgeGrace after the CloseWrite.
+//	  Whichever comes first closes it; Close stops the grace timer, and the wrapped connection is closed only once.
+//
+// ### Never cutting a frame in half
+// A half-closed connection must not end in the middle of a frame: the client would read a truncated payload.
+// And a payload's WriteTo makes several Write calls (type, length, value), so "between two Writes" is not good enough.
+//	- ageConn watches the TLV frames it writes: it reads each frame's header as it goes by and counts the value bytes.
+//	- When the age is reached mid-frame, the CloseWrite waits until the frame's last byte has been written.
+//	- Writes after the CloseWrite fail with ErrConnExpired.
+
+// ErrConnExpired is returned by writes on a connection that reached the Server's MaxConnAge.
+var ErrConnExpired = errors.New("connection reached its maximum age")
+
+// connAgeGrace is how long an expired connection stays half-open before it is closed.
+const connAgeGrace = 5 * time.Second
+
+// ageConn is the net.Conn a Server hands to its Handler when MaxConnAge is set.
+type ageConn struct {
+	net.Conn
+
+	mu      sync.Mutex
+	frame   frameTracker
+	expired bool // MaxConnAge reached
+	closed  bool // write side closed
+
+	timer    *time.Timer
+	grace    atomic.Pointer[time.Timer] // the full close connAgeGrace after the half-close
+	onExpire func()                     // called when maxAge is reached, before the half-close
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// newAgeConn wraps conn and starts its age timer.
+//   - onExpire, if not nil, is called when maxAge is reached (the Server cancels the handler's context with it).
+func newAgeConn(conn net.Conn, maxAge time.Duration, onExpire func()) *ageConn {
+	c := &ageConn{Conn: conn, onExpire: onExpire}
+	c.timer = time.AfterFunc(maxAge, c.expire)
+	return c
+}
+
+// NetConn returns the wrapped connection.
+func (c *ageConn) NetConn() net.Conn { return c.Conn }
+
+// expire half-closes the connection now, or marks it so the frame in progress closes it when it's done.
+func (c *ageConn) expire() {
+	if c.onExpire != nil {
+		c.onExpire()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expired = true
+	if c.frame.atBoundary() {
+		c.closeWrite()
+	}
+}
+
+// closeWrite half-closes the connection and schedules the full close. c.mu must be held.
+func (c *ageConn) closeWrite() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+
+	if errors.Is(closeWrite(c.Conn), ErrCloseWriteUnsupported) {
+		_ = c.closeConn() // can't half-close: closing is the only way to say "no more responses"
+		return
+	}
+	c.grace.Store(time.AfterFunc(connAgeGrace, func() { _ = c.closeConn() }))
+}
+
+// Write writes p, but never past the end of the current frame once the connection expired.
+func (c *ageConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, ErrConnExpired
+	}
+
+	// After expiry, only the rest of the frame in progress may still go out.
+	allowed := len(p)
+	if c.expired {
+		allowed = c.frame.remaining(p)
+	}
+
+	n, err := c.Conn.Write(p[:allowed])
+	c.frame.advance(p[:n])
+	if err != nil {
+		return n, err
+	}
+
+	if c.expired && c.frame.atBoundary() {
+		c.closeWrite()
+	}
+	if n < len(p) {
+		return n, ErrConnExpired
+	}
+
+	return n, nil
+}
+
+// Close stops the age and grace timers and closes the connection.
+//   - It doesn't take c.mu, so it can unblock a Write in progress.
+func (c *ageConn) Close() error {
+	c.timer.Stop()
+	if grace := c.grace.Load(); grace != nil {
+		grace.Stop()
+	}
+	return c.closeConn()
+}
+
+// closeConn closes the wrapped connection once, for Close and the grace timer alike.
+func (c *ageConn) closeConn() error {
+	c.closeOnce.Do(func() { c.closeErr = c.Conn.Close() })
+	return c.closeErr
+}
+
+// frameTracker follows a stream of TLV frames as it is written.
+type frameTracker struct {
+	header [tlvHeaderSize]byte
+	got    int    // header bytes seen of the current frame
+	value  uint32 // value bytes still to come
+}
+
+// atBoundary reports whether the stream is between two frames.
+func (f *frameTracker) atBoundary() bool { return f.got == 0 && f.value == 0 }
+
+// advance consumes written bytes.
+func (f *frameTracker) advance(p []byte) {
+	for len(p) > 0 {
+		if f.got < tlvHeaderSize {
+			n := copy(f.header[f.got:], p)
+			f.got += n
+			p = p[n:]
+			if f.got == tlvHeaderSize {
+				f.value = binary.BigEndian.Uint32(f.header[1:])
+			}
+		}
+
+		if f.got == tlvHeaderSize {
+			n := min(uint32(len(p)), f.value)
+			f.value -= n
+			p = p[n:]
+			if f.value == 0 {
+				f.got = 0 // frame complete
+			}
+		}
+	}
+}
+
+// remaining returns how many bytes of p belong to the frame in progress (0 at a boundary).
+func (f *frameTracker) remaining(p []byte) int {
+	if f.atBoundary() {
+		return 0
+	}
+
+	probe := *f
+	n := 0
+	for n < len(p) && !probe.atBoundary() {
+		probe.advance(p[n : n+1])
+		n++
+	}
+	return n
+}
@@ -0,0 +1,128 @@
+package ch04
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServerMaxConnAge(t *testing.T) {
+	value := bytes.Repeat([]byte("x"), 1024)
+	written := make(chan error, 1)
+
+	s := &Server{
+		MaxConnAge: 200 * time.Millisecond,
+		Handler: func(conn net.Conn) {
+			// Start a frame before the age is reached and finish it after: the frame must arrive whole.
+			header := make([]byte, tlvHeaderSize)
+			header[0] = BinaryType
+			binary.BigEndian.PutUint32(header[1:], uint32(len(value)))
+			_, _ = conn.Write(header)
+			time.Sleep(300 * time.Millisecond)
+			_, _ = conn.Write(value[:10])
+			_, _ = conn.Write(value[10:])
+
+			// The next frame must be refused.
+			_, err := Binary("late").WriteTo(conn)
+			written <- err
+
+			// The read side is still open until the client goes away.
+			_, _ = io.Copy(io.Discard, conn)
+		},
+	}
+	addr := startServer(t, s)
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	start := time.Now()
+	payload, err := Decode(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(payload.Bytes(), value) {
+		t.Fatalf("frame was cut: got %d bytes", len(payload.Bytes()))
+	}
+
+	// Right after the frame, the server's write side is closed.
+	_, err = Decode(conn)
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF; actual: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("connection closed too late: %s", elapsed)
+	}
+
+	if err := <-written; !errors.Is(err, ErrConnExpired) {
+		t.Errorf("expected ErrConnExpired; actual: %v", err)
+	}
+}
+
+func TestFrameTracker(t *testing.T) {
+	var frames bytes.Buffer
+	_, _ = Binary("ab").WriteTo(&frames)
+	_, _ = String("cde").WriteTo(&frames)
+	stream := frames.Bytes()
+
+	var f frameTracker
+	if !f.atBoundary() {
+		t.Fatal("expected a boundary at the start")
+	}
+
+	f.advance(stream[:3])
+	if f.atBoundary() {
+		t.Fatal("expected to be inside the first frame")
+	}
+	if n := f.remaining(stream[3:]); n != 4 {
+		t.Fatalf("expected 4 bytes left in the first frame; actual: %d", n)
+	}
+
+	f.advance(stream[3:])
+	if !f.atBoundary() {
+		t.Fatal("expected a boundary after both frames")
+	}
+}
+
+// closeCounter counts the Close calls that reach the connection.
+type closeCounter struct {
+	net.Conn
+	closes atomic.Int32
+}
+
+func (c *closeCounter) NetConn() net.Conn { return c.Conn }
+
+func (c *closeCounter) Close() error {
+	c.closes.Add(1)
+	return c.Conn.Close()
+}
+
+// Closing an expired connection during the grace period stops the grace timer; the connection is closed once.
+func TestAgeConnCloseDuringGrace(t *testing.T) {
+	server, _ := tcpPair(t)
+	counter := &closeCounter{Conn: server}
+
+	c := newAgeConn(counter, time.Millisecond, nil)
+	waitFor(t, func() bool { return c.grace.Load() != nil }) // expired and half-closed
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if c.grace.Load().Stop() {
+		t.Error("expected Close to stop the grace timer")
+	}
+	if err := c.closeConn(); err != nil { // what the grace timer would do
+		t.Fatal(err)
+	}
+	if n := counter.closes.Load(); n != 1 {
+		t.Errorf("expected the connection to be closed once; actual: %d", n)
+	}
+}
@@ -0,0 +1,49 @@
+package ch04
+
+import (
+	"bytes"
+	"testing"
+)
+
+// FuzzDecode feeds arbitrary bytes to Decode. Whatever the input, Decode must return either a payload or an error,
+// never panic, and a payload it returns must encode back to the bytes it was decoded from.
+func FuzzDecode(f *testing.F) {
+	seeds := [][]byte{
+		{},                                      // nothing at all
+		{BinaryType},                            // type only
+		{BinaryType, 0, 0},                      // truncated length
+		{BinaryType, 0, 0, 0, 5, 'a', 'b'},      // value shorter than the length, then EOF
+		{BinaryType, 0, 0, 0, 0},                // empty value
+		{StringType, 0, 0, 0, 2, 'h', 'i'},      // a valid String
+		{StringType, 0, 0, 0, 1, 0xff},          // a String that isn't UTF-8
+		{BinaryType, 0xff, 0xff, 0xff, 0xff},    // length above MaxPayloadSize
+		{BinaryType, 0x00, 0xa0, 0x00, 0x01},    // length just above MaxPayloadSize
+		{StringType, 0x30, 0x30, 0x00, 0x01},    // String length above MaxPayloadSize (used to allocate it all)
+		{0x00, 0, 0, 0, 1, 'x'},                 // unknown type
+		{0xff, 0, 0, 0, 1, 'x'},                 // unknown type
+		{PingType, 0, 0, 0, 4, 'p', 'i', 'n'},   // truncated Ping
+		{PongType, 0, 0, 0, 1, 'x', BinaryType}, // a frame followed by the start of another
+		{ErrorType, 0, 0, 0, 3, 'b', 'a', 'd'},  // a ProtocolError
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			if p != nil {
+				t.Fatalf("Decode returned both a payload and an error: %v", err)
+			}
+			return
+		}
+
+		var buf bytes.Buffer
+		if _, err = p.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data, buf.Bytes()) {
+			t.Fatalf("re-encoded frame %x is not a prefix of the input %x", buf.Bytes(), data)
+		}
+	})
+}
@@ -0,0 +1,384 @@
+package ch04
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+	"unicode/utf8"
+)
+
+// ## A Decoder with Separate Header and Body Timeouts
+// A server that calls Decode on a fresh connection trusts the peer to send a frame eventually.
+// A peer that connects and then sends nothing (or a single byte) ties up that goroutine forever.
+//	- One read deadline for the whole frame doesn't fit well:
+//		- the 5-byte header should arrive almost immediately, so a short limit is right
+//		- the value may be up to MaxPayloadSize bytes, which can legitimately take much longer
+//	- The Decoder therefore uses two deadlines for every frame:
+//		1. HeaderTimeout while reading the 5-byte header
+//		2. BodyTimeout while reading the value
+//	- The header is read into a small array inside the Decoder, so a peer stalling in the header
+//	  makes us return a time-out error before anything is allocated for the value.
+//	- Deadlines only work on readers that support them (like `net.Conn`);
+//	  for other readers the timeouts are ignored.
+//
+// ### Reusing the Binary buffer
+// Binary.ReadFrom allocates a new slice for every frame. In a hot receive loop that is a lot of garbage.
+//	- With ReuseBuffer the Decoder keeps one growable buffer and reads every Binary value into it.
+//	- The returned *Binary is a view of that buffer (and the *Binary itself is reused too),
+//	  so steady-state traffic decodes Binary frames without allocating at all.
+//	- The price: the payload is only valid until the next call to Decode.
+//	  A caller that keeps the bytes longer must copy them first (for example with `bytes.Clone`).
+//	- Other payload types are decoded as usual.
+//	- The buffer grows to the largest value seen, one allocation per new maximum.
+//	  When the typical frame size is known (say ~1KB), InitialBufferSize allocates that much up front,
+//	  and a new connection's first frames don't grow the buffer step by step.
+//	  It's only a performance hint: larger frames still grow the buffer as before.
+//
+// ### Other wire formats
+// The header/body split and ReuseBuffer only make sense for the TLV format.
+//	- With a Codec set in the options, Decode hands the reader to Codec.Decode instead.
+//	- The Decoder can't see where the codec's header ends, so the whole frame is read under one deadline:
+//	  HeaderTimeout + BodyTimeout.
+//	- StrictUTF8 still applies to the String payloads the codec returns.
+//
+// ### Rejecting invalid text
+// String.ReadFrom accepts any bytes, so a buggy or hostile peer can send a String that isn't valid UTF-8,
+// and the mojibake travels on to logs, databases and other clients.
+//	- With StrictUTF8 the Decoder runs `utf8.Valid` on every String value and returns ErrInvalidUTF8 when it fails.
+//	- The default stays permissive, like String.ReadFrom itself.
+//
+// ### Knowing how much of a frame was read
+// When the body deadline fires in the middle of a value, a bare time-out error doesn't say where in the frame we stopped,
+// so the caller can't tell whether it could resume or how many bytes to discard.
+//	- In that case Decode returns a *PartialReadError with the number of value bytes already consumed
+//	  and the length announced by the header.
+//	- It unwraps to the original error, so `errors.Is(err, os.ErrDeadlineExceeded)` and
+//	  `errors.As(err, &netErr)` keep working.
+//
+// ### Surviving an oversized frame
+// A header announcing more than MaxPayloadSize makes Decode return ErrMaxPayloadSize before reading the value.
+// But the value bytes are still in the stream: the next Decode would read them as a header and get garbage.
+//	- By default (OversizedFail) that is left to the caller, as before; usually the only safe thing to do is close.
+//	- OversizedDiscard reads the announced length with `io.CopyN(io.Discard, ...)`, under BodyTimeout, without
+//	  allocating it. Decode still returns ErrMaxPayloadSize, but the stream is back at a frame boundary
+//	  and the next Decode reads the next frame.
+//	- OversizedClose closes the reader (if it is an `io.Closer`), so nobody reads a desynchronized stream by accident.
+//
+// ### Limits per payload type
+// One global limit is too generous for most types: a Ping never needs 10MB.
+//	- MaxSizes maps a type byte to its own limit. Types not in the map keep MaxPayloadSize.
+//	- MaxSize lowers the limit for every type at once, for example to the size both peers agreed on (see negotiate.go).
+//	- The limit is checked right after the header, before anything is allocated, and an oversized frame
+//	  goes through the Oversized policy like any other.
+//	- A limit above MaxPayloadSize has no effect: the payloads themselves still refuse such frames.
+//	- MaxSizes applies to the TLV format only; a Codec reads its own headers.
+//
+// ### Limiting the number of frames
+// Some protocols allow only a fixed number of messages per connection (one request, a handful of commands, ...).
+//	- With MaxFrames set, the Decoder counts the frames it decodes. Once MaxFrames were returned, the next Decode
+//	  returns ErrFrameLimit without reading anything, and closes the reader (if it is an `io.Closer`).
+//	- Every later Decode returns ErrFrameLimit too. Reset starts counting from zero.
+
+// DecoderOptions configures a Decoder. The zero value decodes without any deadlines.
+type DecoderOptions struct {
+	// HeaderTimeout limits the time spent reading a frame's 5-byte header. Zero means no limit.
+	HeaderTimeout time.Duration
+	// BodyTimeout limits the time spent reading a frame's value. Zero means no limit.
+	BodyTimeout time.Duration
+	// Codec, if set, reads the frames instead of the built-in TLV reader (see above).
+	Codec Codec
+	// StrictUTF8 rejects String values that aren't valid UTF-8 with ErrInvalidUTF8.
+	StrictUTF8 bool
+	// ReuseBuffer decodes Binary frames into a buffer owned by the Decoder.
+	// The returned *Binary is only valid until the next call to Decode.
+	ReuseBuffer bool
+	// InitialBufferSize pre-allocates the ReuseBuffer buffer (capped at MaxPayloadSize); zero starts empty.
+	// It has no effect without ReuseBuffer.
+	InitialBufferSize int
+	// Oversized chooses what happens to the stream after a frame larger than MaxPayloadSize.
+	Oversized OversizedPolicy
+	// MaxSizes limits the value size per payload type; types not listed use MaxSize.
+	MaxSizes map[uint8]uint32
+	// MaxSize limits the value size of every frame; zero means MaxPayloadSize (see negotiate.go).
+	MaxSize uint32
+	// ByteOrder is the byte order of the frames' length; nil means big-endian (see byte_order.go).
+	ByteOrder binary.ByteOrder
+	// RecentFrames is how many of the last decoded frames RecentFrames returns; zero keeps none (see recent_frames.go).
+	RecentFrames int
+	// MaxFrames is how many frames Decode returns before failing with ErrFrameLimit; zero means no limit.
+	MaxFrames int
+}
+
+// OversizedPolicy is what a Decoder does with a frame larger than MaxPayloadSize.
+type OversizedPolicy int
+
+const (
+	// OversizedFail returns ErrMaxPayloadSize and leaves the value bytes in the stream.
+	OversizedFail OversizedPolicy = iota
+	// OversizedDiscard skips the value bytes, then returns ErrMaxPayloadSize.
+	OversizedDiscard
+	// OversizedClose closes the reader, then returns ErrMaxPayloadSize.
+	OversizedClose
+)
+
+// ErrFrameLimit is returned by Decode after MaxFrames frames were decoded.
+var ErrFrameLimit = errors.New("frame limit reached")
+
+// ErrInvalidUTF8 is returned in StrictUTF8 mode for a String value that isn't valid UTF-8.
+var ErrInvalidUTF8 = errors.New("invalid UTF-8 in String")
+
+// PartialReadError reports a time-out while reading a frame's value.
+type PartialReadError struct {
+	BytesRead      int64  // value bytes consumed before the time-out
+	ExpectedLength uint32 // value length announced by the header
+	Err            error  // the underlying time-out error
+}
+
+func (e *PartialReadError) Error() string {
+	return fmt.Sprintf("read %d of %d value bytes: %v", e.BytesRead, e.ExpectedLength, e.Err)
+}
+
+func (e *PartialReadError) Unwrap() error { return e.Err }
+
+// isTimeout reports whether err is a network time-out.
+func isTimeout(err error) bool {
+	var nErr net.Error
+	return errors.As(err, &nErr) && nErr.Timeout()
+}
+
+// readDeadliner is implemented by readers that support read deadlines, like `net.Conn`.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// Decoder reads payloads from a reader, one frame at a time.
+//   - A Decoder is not safe for concurrent use.
+type Decoder struct {
+	r      io.Reader
+	opts   DecoderOptions
+	header [tlvHeaderSize]byte
+
+	buf    []byte // ReuseBuffer: grows to the largest Binary value seen
+	binary Binary // ReuseBuffer: the payload handed back, a view of buf
+
+	recent *frameRing // RecentFrames: the last frames decoded
+	frames int        // MaxFrames: frames decoded so far
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader, opts DecoderOptions) *Decoder {
+	d := &Decoder{r: r, opts: opts}
+	if opts.ReuseBuffer && opts.InitialBufferSize > 0 {
+		d.buf = make([]byte, min(opts.InitialBufferSize, int(MaxPayloadSize)))
+	}
+	if opts.RecentFrames > 0 {
+		d.recent = newFrameRing(opts.RecentFrames)
+	}
+
+	return d
+}
+
+// Reset makes the Decoder read from r, as if it were new, but keeps its options and the capacity of its buffer.
+//   - After an error the Decoder's state is unknown (a partial header, half a value in the buffer);
+//     Reset is the way to reuse it, for example from a `sync.Pool` for the next connection.
+//   - The buffer is zeroed, so no data from the previous reader can leak into the next one.
+//   - A *Binary returned with ReuseBuffer becomes invalid, just like after the next Decode.
+//   - The recent frames are forgotten too: they belong to the previous reader.
+func (d *Decoder) Reset(r io.Reader) {
+	d.r = r
+	d.header = [tlvHeaderSize]byte{}
+	clear(d.buf)
+	d.binary = nil
+	d.frames = 0
+	if d.recent != nil {
+		d.recent.reset()
+	}
+}
+
+// Decode reads the next frame and returns it as the registered payload type.
+//   - A deadline that expires returns the reader's time-out error (`os.ErrDeadlineExceeded` for a `net.Conn`).
+//   - After MaxFrames frames, it returns ErrFrameLimit and closes the reader.
+func (d *Decoder) Decode() (Payload, error) {
+	if d.opts.MaxFrames > 0 && d.frames >= d.opts.MaxFrames {
+		if c, ok := d.r.(io.Closer); ok {
+			_ = c.Close()
+		}
+		return nil, ErrFrameLimit
+	}
+
+	payload, err := d.decode()
+	if err == nil {
+		d.frames++
+	}
+
+	return payload, err
+}
+
+// decode reads the next frame, through the Codec or as TLV.
+func (d *Decoder) decode() (Payload, error) {
+	if d.opts.Codec != nil {
+		return d.decodeCodec()
+	}
+
+	// 1) The header, under HeaderTimeout.
+	if err := d.setDeadline(d.opts.HeaderTimeout); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(d.r, d.header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrTruncatedFrame // part of a header
+		}
+		return nil, err
+	}
+
+	if !isBigEndian(d.opts.ByteOrder) {
+		reorderLength(d.header[:], d.opts.ByteOrder, binary.BigEndian) // the payloads read big-endian
+	}
+	typ := d.header[0]
+	size := binary.BigEndian.Uint32(d.header[1:])
+	if size > d.maxSize(typ) {
+		return nil, d.oversized(size)
+	}
+
+	newPayload, ok := DefaultRegistry.lookup(typ)
+	if !ok {
+		return nil, ErrUnknownType
+	}
+
+	// 2) The value, under BodyTimeout.
+	//	- The payload reads its own frame, so the header goes back in front of the reader.
+	if err := d.setDeadline(d.opts.BodyTimeout); err != nil {
+		return nil, err
+	}
+	var payload Payload
+	var read int64 // value bytes read so far
+	var err error
+	var value []byte // RecentFrames: a copy of the value
+	if d.opts.ReuseBuffer && typ == BinaryType {
+		payload, read, err = d.readBinary(size)
+		if err == nil && d.recent != nil {
+			value = bytes.Clone(d.binary)
+		}
+	} else {
+		body := d.r
+		var tee *bytes.Buffer
+		if d.recent != nil {
+			tee = new(bytes.Buffer)
+			body = io.TeeReader(d.r, tee)
+		}
+		payload = newPayload()
+		read, err = payload.ReadFrom(io.MultiReader(bytes.NewReader(d.header[:]), body))
+		read = max(read-tlvHeaderSize, 0) // ReadFrom counts the header too
+		if tee != nil {
+			value = tee.Bytes()
+		}
+	}
+	if err != nil {
+		if isTimeout(err) {
+			return nil, &PartialReadError{BytesRead: read, ExpectedLength: size, Err: err}
+		}
+		return nil, truncated(err)
+	}
+	if d.recent != nil {
+		d.recent.add(RecentFrame{Type: typ, Length: size, Value: value})
+	}
+
+	return d.checkUTF8(payload)
+}
+
+// maxSize returns the value size limit for typ.
+func (d *Decoder) maxSize(typ uint8) uint32 {
+	limit := MaxPayloadSize
+	if d.opts.MaxSize > 0 {
+		limit = min(d.opts.MaxSize, limit)
+	}
+	if typeLimit, ok := d.opts.MaxSizes[typ]; ok {
+		return min(typeLimit, limit)
+	}
+
+	return limit
+}
+
+// SetMaxSize changes MaxSize, for example after NegotiateMaxSize. It applies from the next Decode on.
+func (d *Decoder) SetMaxSize(n uint32) { d.opts.MaxSize = n }
+
+// oversized applies the Oversized policy to a frame whose header announced size value bytes.
+func (d *Decoder) oversized(size uint32) error {
+	switch d.opts.Oversized {
+	case OversizedDiscard:
+		if err := d.setDeadline(d.opts.BodyTimeout); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(io.Discard, d.r, int64(size)); err != nil {
+			return truncated(err)
+		}
+	case OversizedClose:
+		if c, ok := d.r.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}
+
+	return ErrMaxPayloadSize
+}
+
+// decodeCodec reads one frame through the configured Codec.
+func (d *Decoder) decodeCodec() (Payload, error) {
+	if err := d.setDeadline(d.opts.HeaderTimeout + d.opts.BodyTimeout); err != nil {
+		return nil, err
+	}
+
+	payload, err := d.opts.Codec.Decode(d.r)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.checkUTF8(payload)
+}
+
+// checkUTF8 applies StrictUTF8 to a decoded payload.
+func (d *Decoder) checkUTF8(payload Payload) (Payload, error) {
+	if _, ok := payload.(*String); ok && d.opts.StrictUTF8 && !utf8.ValidString(payload.String()) {
+		return nil, ErrInvalidUTF8
+	}
+
+	return payload, nil
+}
+
+// readBinary reads a Binary value of size bytes into the reusable buffer.
+func (d *Decoder) readBinary(size uint32) (Payload, int64, error) {
+	if cap(d.buf) < int(size) {
+		d.buf = make([]byte, size)
+	}
+
+	d.binary = Binary(d.buf[:size])
+	n, err := io.ReadFull(d.r, d.binary)
+	if err != nil {
+		return nil, int64(n), err
+	}
+
+	return &d.binary, int64(n), nil
+}
+
+// setDeadline sets the read deadline for the next step, if the Decoder uses deadlines at all.
+//   - A zero timeout clears the deadline left over from the previous step.
+func (d *Decoder) setDeadline(timeout time.Duration) error {
+	if d.opts.HeaderTimeout <= 0 && d.opts.BodyTimeout <= 0 {
+		return nil
+	}
+
+	rd, ok := d.r.(readDeadliner)
+	if !ok {
+		return nil
+	}
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	return rd.SetReadDeadline(deadline)
+}
@@ -0,0 +1,446 @@
+package ch04
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// A client that sends a single byte of the header and stalls must hit the header timeout,
+// long before the (much longer) body timeout.
+func TestDecoderHeaderTimeout(t *testing.T) {
+	client, server := tcpPair(t)
+
+	if _, err := client.Write([]byte{BinaryType}); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := NewDecoder(server, DecoderOptions{
+		HeaderTimeout: 100 * time.Millisecond,
+		BodyTimeout:   10 * time.Second,
+	})
+
+	start := time.Now()
+	_, err := dec.Decode()
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected a time-out error; actual: %v", err)
+	}
+	if elapsed > time.Second {
+		t.Fatalf("header timeout fired after %s", elapsed)
+	}
+}
+
+// A complete header followed by a stalled value hits the body timeout instead.
+func TestDecoderBodyTimeout(t *testing.T) {
+	client, server := tcpPair(t)
+
+	// Header promises 10 bytes, only 3 are sent.
+	if _, err := client.Write([]byte{BinaryType, 0, 0, 0, 10, 'a', 'b', 'c'}); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := NewDecoder(server, DecoderOptions{
+		HeaderTimeout: 10 * time.Second,
+		BodyTimeout:   100 * time.Millisecond,
+	})
+
+	start := time.Now()
+	_, err := dec.Decode()
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected a time-out error; actual: %v", err)
+	}
+	if elapsed > time.Second {
+		t.Fatalf("body timeout fired after %s", elapsed)
+	}
+}
+
+// Frames that arrive in time decode normally, one after another.
+func TestDecoderDecode(t *testing.T) {
+	client, server := tcpPair(t)
+
+	b := Binary("first")
+	s := String("second")
+	go func() {
+		_, _ = b.WriteTo(client)
+		_, _ = s.WriteTo(client)
+	}()
+
+	dec := NewDecoder(server, DecoderOptions{
+		HeaderTimeout: time.Second,
+		BodyTimeout:   time.Second,
+	})
+
+	for _, expected := range []Payload{&b, &s} {
+		actual, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual.String() != expected.String() {
+			t.Errorf("expected %q; actual: %q", expected, actual)
+		}
+	}
+}
+
+// frameLoop returns the frames of payloads over and over again.
+type frameLoop struct {
+	frames []byte
+	r      bytes.Reader
+}
+
+func newFrameLoop(t testing.TB, payloads ...Payload) *frameLoop {
+	t.Helper()
+
+	var buf bytes.Buffer
+	for _, p := range payloads {
+		if _, err := p.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l := &frameLoop{frames: buf.Bytes()}
+	l.r.Reset(l.frames)
+	return l
+}
+
+func (l *frameLoop) Read(p []byte) (int, error) {
+	if l.r.Len() == 0 {
+		l.r.Reset(l.frames)
+	}
+	return l.r.Read(p)
+}
+
+func TestDecoderReuseBuffer(t *testing.T) {
+	small := Binary("small")
+	large := Binary(bytes.Repeat([]byte("large"), 100))
+	s := String("strings are decoded as usual")
+
+	dec := NewDecoder(newFrameLoop(t, &large, &small, &s), DecoderOptions{ReuseBuffer: true})
+
+	for _, expected := range []Payload{&large, &small, &s, &large} {
+		actual, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(actual.Bytes(), expected.Bytes()) {
+			t.Errorf("expected %q; actual: %q", expected, actual)
+		}
+	}
+
+	// Once the buffer has grown, decoding Binary frames doesn't allocate.
+	dec = NewDecoder(newFrameLoop(t, &large, &small), DecoderOptions{ReuseBuffer: true})
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := dec.Decode(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations per frame; actual: %.1f", allocs)
+	}
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	payload := Binary(bytes.Repeat([]byte{'x'}, 1024))
+
+	for _, reuse := range []bool{false, true} {
+		name := "allocate"
+		if reuse {
+			name = "reuse"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			dec := NewDecoder(newFrameLoop(b, &payload), DecoderOptions{ReuseBuffer: reuse})
+			b.ReportAllocs()
+			b.SetBytes(int64(tlvHeaderSize + len(payload)))
+
+			for b.Loop() {
+				if _, err := dec.Decode(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Every iteration is a new connection whose frames grow up to 1KB, as they might while a session warms up.
+//   - Without a hint, the reused buffer is re-allocated for every new maximum.
+//   - With InitialBufferSize at the typical frame size, it is allocated once.
+func BenchmarkDecoderInitialBufferSize(b *testing.B) {
+	var frames bytes.Buffer
+	for size := 64; size <= 1024; size *= 2 {
+		payload := Binary(bytes.Repeat([]byte{'x'}, size))
+		if _, err := payload.WriteTo(&frames); err != nil {
+			b.Fatal(err)
+		}
+	}
+	const framesPerConn = 5
+
+	for _, hint := range []int{0, 1024} {
+		b.Run(fmt.Sprintf("hint=%d", hint), func(b *testing.B) {
+			r := bytes.NewReader(frames.Bytes())
+			b.ReportAllocs()
+
+			for b.Loop() {
+				r.Reset(frames.Bytes())
+				dec := NewDecoder(r, DecoderOptions{ReuseBuffer: true, InitialBufferSize: hint})
+				for range framesPerConn {
+					if _, err := dec.Decode(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// A frame sent in two halves with a pause longer than the body timeout in between
+// reports exactly how much of the value was read.
+func TestDecoderPartialRead(t *testing.T) {
+	value := bytes.Repeat([]byte("x"), 1000)
+	half := tlvHeaderSize + len(value)/2
+
+	tests := []struct {
+		name    string
+		payload Payload
+		reuse   bool
+	}{
+		{"Binary", ptr(Binary(value)), false},
+		{"Binary, ReuseBuffer", ptr(Binary(value)), true},
+		{"String", ptr(String(value)), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var frame bytes.Buffer
+			if _, err := tc.payload.WriteTo(&frame); err != nil {
+				t.Fatal(err)
+			}
+
+			client, server := tcpPair(t)
+			go func() {
+				_, _ = client.Write(frame.Bytes()[:half])
+				time.Sleep(500 * time.Millisecond)
+				_, _ = client.Write(frame.Bytes()[half:])
+			}()
+
+			dec := NewDecoder(server, DecoderOptions{
+				BodyTimeout: 100 * time.Millisecond,
+				ReuseBuffer: tc.reuse,
+			})
+
+			_, err := dec.Decode()
+			var pErr *PartialReadError
+			if !errors.As(err, &pErr) {
+				t.Fatalf("expected a *PartialReadError; actual: %v", err)
+			}
+			if pErr.BytesRead != int64(len(value)/2) || pErr.ExpectedLength != uint32(len(value)) {
+				t.Errorf("expected %d of %d bytes read; actual: %d of %d",
+					len(value)/2, len(value), pErr.BytesRead, pErr.ExpectedLength)
+			}
+			if !errors.Is(err, os.ErrDeadlineExceeded) {
+				t.Errorf("expected the error to wrap os.ErrDeadlineExceeded; actual: %v", err)
+			}
+		})
+	}
+}
+
+func TestDecoderStrictUTF8(t *testing.T) {
+	valid := String("héllo, 世界")
+	invalid := String("bad \xff\xfe bytes")
+
+	tests := []struct {
+		name    string
+		payload String
+		strict  bool
+		err     error
+	}{
+		{"valid UTF-8, strict", valid, true, nil},
+		{"invalid UTF-8, strict", invalid, true, ErrInvalidUTF8},
+		{"invalid UTF-8, permissive", invalid, false, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var frame bytes.Buffer
+			if _, err := tc.payload.WriteTo(&frame); err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := NewDecoder(&frame, DecoderOptions{StrictUTF8: tc.strict}).Decode()
+			if err != tc.err {
+				t.Fatalf("expected error %v; actual: %v", tc.err, err)
+			}
+			if err == nil && actual.String() != tc.payload.String() {
+				t.Errorf("expected %q; actual: %q", tc.payload, actual)
+			}
+		})
+	}
+}
+
+// oversizedStream returns a frame one byte over MaxPayloadSize followed by the frame of next.
+func oversizedStream(t *testing.T, next Payload) *bytes.Buffer {
+	t.Helper()
+
+	var stream bytes.Buffer
+	header := make([]byte, tlvHeaderSize)
+	header[0] = BinaryType
+	binary.BigEndian.PutUint32(header[1:], MaxPayloadSize+1)
+	stream.Write(header)
+	stream.Write(make([]byte, MaxPayloadSize+1))
+
+	if _, err := next.WriteTo(&stream); err != nil {
+		t.Fatal(err)
+	}
+	return &stream
+}
+
+func TestDecoderOversizedDiscard(t *testing.T) {
+	next := String("still in sync")
+	dec := NewDecoder(oversizedStream(t, &next), DecoderOptions{Oversized: OversizedDiscard})
+
+	if _, err := dec.Decode(); !errors.Is(err, ErrMaxPayloadSize) {
+		t.Fatalf("expected ErrMaxPayloadSize; actual: %v", err)
+	}
+
+	actual, err := dec.Decode()
+	if err != nil {
+		t.Fatalf("the frame after the oversized one wasn't readable: %v", err)
+	}
+	if actual.String() != next.String() {
+		t.Errorf("expected %q; actual: %q", next, actual)
+	}
+}
+
+func TestDecoderOversizedClose(t *testing.T) {
+	client, server := tcpPair(t)
+
+	header := make([]byte, tlvHeaderSize)
+	header[0] = BinaryType
+	binary.BigEndian.PutUint32(header[1:], MaxPayloadSize+1)
+	if _, err := client.Write(header); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := NewDecoder(server, DecoderOptions{Oversized: OversizedClose})
+	if _, err := dec.Decode(); !errors.Is(err, ErrMaxPayloadSize) {
+		t.Fatalf("expected ErrMaxPayloadSize; actual: %v", err)
+	}
+	if _, err := server.Read(make([]byte, 1)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected the connection to be closed; actual: %v", err)
+	}
+}
+
+// With MaxFrames=3, the peer's 4th frame is refused and the connection is closed.
+func TestDecoderMaxFrames(t *testing.T) {
+	client, server := tcpPair(t)
+
+	for i := range 4 {
+		frame := String(fmt.Sprintf("frame %d", i+1))
+		if _, err := frame.WriteTo(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := NewDecoder(server, DecoderOptions{MaxFrames: 3})
+	for i := range 3 {
+		p, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i+1, err)
+		}
+		if expected := fmt.Sprintf("frame %d", i+1); p.String() != expected {
+			t.Errorf("expected %q; actual: %q", expected, p)
+		}
+	}
+
+	if _, err := dec.Decode(); !errors.Is(err, ErrFrameLimit) {
+		t.Fatalf("expected ErrFrameLimit; actual: %v", err)
+	}
+	if _, err := server.Read(make([]byte, 1)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected the connection to be closed; actual: %v", err)
+	}
+
+	// Reset starts a new count.
+	frame, err := AppendFrame(nil, ptr(String("after reset")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec.Reset(bytes.NewReader(frame))
+	if _, err := dec.Decode(); err != nil {
+		t.Errorf("expected a fresh count after Reset; actual: %v", err)
+	}
+}
+
+// ptr returns a pointer to a copy of v, for payloads whose ReadFrom has a pointer receiver.
+func ptr[T any](v T) *T { return &v }
+
+func TestDecoderMaxSizes(t *testing.T) {
+	opts := DecoderOptions{
+		MaxSizes: map[uint8]uint32{
+			PingType:   16,
+			StringType: 1 << 20,
+		},
+	}
+
+	tests := []struct {
+		name    string
+		payload Payload
+		err     error
+	}{
+		{"Ping at its limit", ptr(Ping(bytes.Repeat([]byte("p"), 16))), nil},
+		{"Ping over its limit", ptr(Ping(bytes.Repeat([]byte("p"), 17))), ErrMaxPayloadSize},
+		{"String at its limit", ptr(String(bytes.Repeat([]byte("s"), 1<<20))), nil},
+		{"String over its limit", ptr(String(bytes.Repeat([]byte("s"), 1<<20+1))), ErrMaxPayloadSize},
+		// Not in the map: only MaxPayloadSize applies.
+		{"Binary larger than both limits", ptr(Binary(bytes.Repeat([]byte("b"), 2<<20))), nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var frame bytes.Buffer
+			if _, err := tc.payload.WriteTo(&frame); err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := NewDecoder(&frame, opts).Decode()
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected error %v; actual: %v", tc.err, err)
+			}
+			if err == nil && !bytes.Equal(actual.Bytes(), tc.payload.Bytes()) {
+				t.Errorf("payload changed: %d bytes instead of %d", len(actual.Bytes()), len(tc.payload.Bytes()))
+			}
+		})
+	}
+}
+
+func TestDecoderReset(t *testing.T) {
+	dec := NewDecoder(nil, DecoderOptions{ReuseBuffer: true})
+
+	for _, conn := range []string{"first", "second"} {
+		var stream bytes.Buffer
+		good := Binary(conn + " connection")
+		_, _ = good.WriteTo(&stream)
+		_, _ = Binary("cut short").WriteTo(&stream)
+		stream.Truncate(stream.Len() - 3) // the second frame is truncated
+
+		dec.Reset(&stream)
+		p, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("%s connection: %v", conn, err)
+		}
+		if p.String() != good.String() {
+			t.Errorf("%s connection: expected %q; actual: %q", conn, good, p)
+		}
+
+		if _, err = dec.Decode(); !errors.Is(err, ErrTruncatedFrame) {
+			t.Fatalf("%s connection: expected ErrTruncatedFrame; actual: %v", conn, err)
+		}
+	}
+}
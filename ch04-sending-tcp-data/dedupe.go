@@ -0,0 +1,80 @@
+package ch04
+
+import (
+	"crypto/sha256"
+	"sync"
+)
+
+// ## Dropping Duplicate Payloads
+// A pub/sub relay fed by several publishers (or by one that retries after a reconnect, see reconnect_client.go)
+// sees the same message more than once. Subscribers should get it only once.
+//	- Deduper remembers the SHA-256 hash of the last N payloads' frames in a ring buffer. The frame includes
+//	  the type byte, so a String and a Binary with the same bytes are different messages, just like for Equal.
+//	- A payload whose hash is still in the ring is a duplicate; any other payload is new and its hash
+//	  replaces the oldest one once the ring is full.
+//	- A set holds the same hashes as the ring, so a lookup costs one hash and no scan of the ring.
+//	  A duplicate is never added, so every hash is in the ring at most once.
+//	- Memory stays bounded: 32 bytes (plus the map entry) per remembered payload, however large the payloads are.
+//	- Filter has the signature of a Proxy transform. A Deduper has ONE window, so give it one direction:
+//	  ProxyTransforms(ctx, src, dst, deduper.Filter, nil) forwards only the first copy of every payload from src,
+//	  and lets every response through. Passed to Proxy, the window would be shared by both directions,
+//	  and a response that repeats its request (an echo) would be dropped as a duplicate.
+//
+// NOTE:
+//	- The window is counted in payloads, not time: the same message after N others is delivered again.
+//	- A payload that can't be encoded (see AppendFrame) is never a duplicate and isn't remembered.
+//	- A Deduper is safe for concurrent use.
+
+// Deduper detects payloads seen within the last N payloads.
+type Deduper struct {
+	mu     sync.Mutex
+	ring   [][sha256.Size]byte            // len(ring) is the window; filled slots are the first count ones in ring order
+	next   int                            // slot for the next hash
+	count  int                            // filled slots
+	hashes map[[sha256.Size]byte]struct{} // the hashes in the ring
+}
+
+// NewDeduper returns a Deduper that remembers the last window payloads; a window below 1 is raised to 1.
+func NewDeduper(window int) *Deduper {
+	window = max(window, 1)
+
+	return &Deduper{
+		ring:   make([][sha256.Size]byte, window),
+		hashes: make(map[[sha256.Size]byte]struct{}, window),
+	}
+}
+
+// Seen reports whether p was seen within the window; if not, it remembers p.
+func (d *Deduper) Seen(p Payload) bool {
+	frame, err := AppendFrame(nil, p)
+	if err != nil {
+		return false
+	}
+	sum := sha256.Sum256(frame)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.hashes[sum]; ok {
+		return true
+	}
+
+	if d.count == len(d.ring) {
+		delete(d.hashes, d.ring[d.next]) // the oldest hash leaves the window
+	} else {
+		d.count++
+	}
+	d.ring[d.next] = sum
+	d.next = (d.next + 1) % len(d.ring)
+	d.hashes[sum] = struct{}{}
+
+	return false
+}
+
+// Filter returns p, or nil for a duplicate, so it can be passed to ProxyTransforms as a transform.
+func (d *Deduper) Filter(p Payload) (Payload, error) {
+	if d.Seen(p) {
+		return nil, nil
+	}
+	return p, nil
+}
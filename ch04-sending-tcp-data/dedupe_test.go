@@ -0,0 +1,110 @@
+package ch04
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestDeduperProxy(t *testing.T) {
+	client, proxyIn := tcpPair(t)
+	proxyOut, server := tcpPair(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = ProxyTransforms(ctx, proxyIn, proxyOut, NewDeduper(16).Filter, nil) }()
+
+	for _, value := range []string{"news", "news", "weather"} {
+		p := Binary(value)
+		if _, err := p.WriteTo(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := closeWrite(client); err != nil {
+		t.Fatal(err)
+	}
+
+	var delivered []string
+	for {
+		p, err := Decode(server)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		delivered = append(delivered, p.String())
+	}
+
+	if len(delivered) != 2 || delivered[0] != "news" || delivered[1] != "weather" {
+		t.Fatalf("expected [news weather]; actual: %q", delivered)
+	}
+}
+
+// A response that repeats its request goes the other way, so it isn't a duplicate of it.
+func TestDeduperEchoThroughProxy(t *testing.T) {
+	client, proxyIn := tcpPair(t)
+	proxyOut, server := tcpPair(t)
+	go func() { _ = echo(server) }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = ProxyTransforms(ctx, proxyIn, proxyOut, NewDeduper(16).Filter, nil) }()
+
+	for _, value := range []string{"hello", "hello", "bye"} {
+		p := String(value)
+		if _, err := p.WriteTo(client); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	for _, want := range []string{"hello", "bye"} { // the second request was a duplicate
+		p, err := Decode(client)
+		if err != nil {
+			t.Fatalf("expected the echo %q; actual: %v", want, err)
+		}
+		if p.String() != want {
+			t.Fatalf("expected the echo %q; actual: %q", want, p)
+		}
+	}
+}
+
+// The type is part of a message: a String isn't a duplicate of a Binary with the same bytes.
+func TestDeduperType(t *testing.T) {
+	d := NewDeduper(16)
+	b, s := Binary("x"), String("x")
+
+	if d.Seen(&b) {
+		t.Fatal("expected the first Binary to be new")
+	}
+	if d.Seen(&s) {
+		t.Error("expected the String to be new after a Binary with the same bytes")
+	}
+	if !d.Seen(&b) {
+		t.Error("expected the second Binary to be a duplicate")
+	}
+}
+
+func TestDeduperWindow(t *testing.T) {
+	d := NewDeduper(2)
+	a, b, c := Binary("a"), Binary("b"), Binary("c")
+
+	for i, tc := range []struct {
+		p    Payload
+		seen bool
+	}{
+		{&a, false},
+		{&a, true},
+		{&b, false},
+		{&c, false}, // pushes a out of the window
+		{&a, false},
+		{&c, true},
+	} {
+		if seen := d.Seen(tc.p); seen != tc.seen {
+			t.Errorf("%d: expected seen %t for %q; actual: %t", i, tc.seen, tc.p, seen)
+		}
+	}
+}
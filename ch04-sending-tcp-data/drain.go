@@ -0,0 +1,45 @@
+package ch04
+
+import (
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// ## Closing a Connection Without Losing Data
+// Calling Close on a TCP connection while unread data sits in its receive buffer makes the kernel send an RST
+// instead of a FIN. The RST can arrive before the peer has read our last response, and the peer then sees
+// "connection reset by peer" and loses that response.
+//	- The fix is a graceful, two-step close:
+//		1. CloseWrite: send a FIN so the peer knows we're done writing (we can still read).
+//		2. Read and throw away whatever the peer still sends until it closes its side (`io.EOF`).
+//		3. Close: now the receive buffer is empty, so the kernel sends a normal FIN, not an RST.
+//	- A peer that never closes could keep us reading forever, so step 2 has a deadline.
+
+// DrainClose half-closes conn, discards incoming data until EOF or timeout, then closes conn.
+//   - Connections that can't be half-closed (see closeWrite) skip the half-close and are drained and closed.
+//   - It returns the first error other than `io.EOF`, including a time-out if the peer never closed its side.
+func DrainClose(conn net.Conn, timeout time.Duration) error {
+	var err error
+
+	// 1) Tell the peer we're done writing.
+	if err = closeWrite(conn); errors.Is(err, ErrCloseWriteUnsupported) {
+		err = nil
+	}
+
+	// 2) Drain whatever is still coming, but not longer than timeout.
+	if err == nil {
+		err = conn.SetReadDeadline(time.Now().Add(timeout))
+	}
+	if err == nil {
+		_, err = io.Copy(io.Discard, conn) // returns nil on io.EOF
+	}
+
+	// 3) Close for real.
+	if cErr := conn.Close(); err == nil {
+		err = cErr
+	}
+
+	return err
+}
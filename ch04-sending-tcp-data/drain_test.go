@@ -0,0 +1,100 @@
+package ch04
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// In this test the server writes its response and calls DrainClose,
+// while the client keeps sending trailing data AFTER the server has finished writing.
+//   - Thanks to CloseWrite the client still reads the response followed by a clean `io.EOF`.
+//   - DrainClose reads the trailing data and waits for the client to close before closing itself,
+//     so it returns nil and no RST is sent.
+func TestDrainClose(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	done := make(chan error)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+
+		if _, err = conn.Write([]byte("response")); err != nil {
+			done <- err
+			return
+		}
+
+		done <- DrainClose(conn, 5*time.Second)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	response, err := io.ReadAll(conn) // ends with the server's FIN
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(response) != "response" {
+		t.Errorf("expected %q; actual: %q", "response", response)
+	}
+
+	// Trailing data the server never asked for.
+	for i := 0; i < 10; i++ {
+		if _, err = conn.Write([]byte("trailing data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case err := <-done:
+		t.Fatalf("DrainClose returned before the client closed: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	_ = conn.Close()
+
+	if err := <-done; err != nil {
+		t.Errorf("expected a clean drain; actual: %v", err)
+	}
+}
+
+// A peer that never closes its side must not block DrainClose forever.
+func TestDrainCloseTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	err = DrainClose(server, 100*time.Millisecond)
+	nErr, ok := err.(net.Error)
+	if !ok || !nErr.Timeout() {
+		t.Errorf("expected timeout error; actual: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("DrainClose took %s", elapsed)
+	}
+}
@@ -0,0 +1,71 @@
+package ch04
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// ## A Reference Echo Server for the TLV Protocol
+// When you build something on top of the TLV types, you need a peer to test against.
+//	- EchoServer is the simplest useful peer: every payload it receives is written straight back.
+//	- Each connection is handled in its own goroutine (see Listing 3-2), so many clients can be served at once.
+//	- For each connection it loops:
+//		1. Decode one payload (through the registry, so any registered type works)
+//		2. WriteTo the same payload back on the same connection
+//		3. Stop at `io.EOF` (the client closed its side) or at any error
+//	- The server shuts down when ctx is canceled:
+//		- the listener is closed, which unblocks Accept
+//		- every open connection is closed, which unblocks its Decode
+//		- EchoServer waits for all connection goroutines before returning, so nothing leaks
+
+// EchoServer serves the echo protocol on listener until ctx is canceled.
+//   - It always closes the listener before returning.
+//   - A shutdown through ctx returns nil; any other Accept error is returned as is.
+func EchoServer(ctx context.Context, listener net.Listener) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// Closing the listener is the only way to unblock Accept.
+	stop := context.AfterFunc(ctx, func() { _ = listener.Close() })
+	defer stop()
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		wg.Go(func() {
+			// Closing the connection on shutdown unblocks a pending Decode.
+			stopConn := context.AfterFunc(ctx, func() { _ = conn.Close() })
+			defer stopConn()
+			defer conn.Close()
+
+			_ = echo(conn)
+		})
+	}
+}
+
+// echo decodes payloads from conn and writes each one back until EOF.
+func echo(conn net.Conn) error {
+	for {
+		payload, err := Decode(conn)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if _, err = payload.WriteTo(conn); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package ch04
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// This test starts an EchoServer, connects several clients at the same time,
+// sends a Binary and a String from each one and checks that both come back unchanged.
+func TestEchoServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- EchoServer(ctx, listener) }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Go(func() {
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+
+			b := Binary("Don't panic.")
+			s := String("Errors are values.")
+
+			for _, expected := range []Payload{&b, &s} {
+				if _, err := expected.WriteTo(conn); err != nil {
+					t.Error(err)
+					return
+				}
+
+				actual, err := Decode(conn)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				if !reflect.DeepEqual(expected, actual) {
+					t.Errorf("value mismatch: %v != %v", expected, actual)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	// A client that stays connected must not keep the server alive after shutdown.
+	idle, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected nil error on shutdown; actual: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("EchoServer did not shut down")
+	}
+}
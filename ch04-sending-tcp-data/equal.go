@@ -0,0 +1,31 @@
+package ch04
+
+import "bytes"
+
+// ## Comparing Payloads
+// `reflect.DeepEqual` compares Go values: two payloads may differ in fields that never reach the wire
+// (a nil and an empty slice, a cached value, ...) while they send the exact same frame.
+// What the peer sees is the frame, so Equal compares frames:
+//	- the type byte: a Binary and a String holding the same bytes are different messages
+//	- the value, which is Bytes() for every payload type
+// Every payload type works the same way, registered or not, without type-specific code.
+// Useful in tests, and for dropping a message that was already seen (deduplication).
+
+// Equal reports whether a and b encode to the same frame: same type byte and same Bytes().
+//   - Two nil payloads are equal. A payload that can't be encoded (too large) is equal to nothing.
+func Equal(a, b Payload) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	frameA, err := AppendFrame(nil, a)
+	if err != nil {
+		return false
+	}
+	frameB, err := AppendFrame(nil, b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(frameA, frameB)
+}
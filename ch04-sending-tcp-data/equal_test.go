@@ -0,0 +1,33 @@
+package ch04
+
+import "testing"
+
+func TestEqual(t *testing.T) {
+	bin := Binary("same bytes")
+	str := String("same bytes")
+
+	tests := []struct {
+		name     string
+		a, b     Payload
+		expected bool
+	}{
+		{"equal Binaries", &bin, ptr(Binary("same bytes")), true},
+		{"Binary and String", &bin, &str, false},
+		{"different bytes", &bin, ptr(Binary("other bytes")), false},
+		{"registered type", &Chunk{Seq: 1, Data: []byte("x")}, &Chunk{Seq: 1, Data: []byte("x")}, true},
+		{"registered type, different field", &Chunk{Seq: 1}, &Chunk{Seq: 2}, false},
+		{"nil and nil", nil, nil, true},
+		{"nil and payload", nil, &bin, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := Equal(tt.a, tt.b); actual != tt.expected {
+				t.Errorf("expected %t; actual: %t", tt.expected, actual)
+			}
+			if actual := Equal(tt.b, tt.a); actual != tt.expected {
+				t.Errorf("not symmetric: expected %t; actual: %t", tt.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package ch04
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+// ## One Deadline for a Whole Exchange
+// A deadline set with SetDeadline applies to every Read and Write until you change it,
+// but we usually reason about time per request: "send this and get the answer within 2 seconds".
+//	- An exchange is several operations: write the request frame, read the response header, read the response value.
+//	- If each step got its own fresh deadline, a slow peer could stretch the whole exchange far beyond our budget.
+//	- ExchangeContext therefore sets ONE deadline (taken from the context) before the first write,
+//	  and every step of the exchange shares it.
+//
+// Three details matter:
+//	1. Context cancellation: a deadline covers the time budget, but a canceled context (without a deadline)
+//	   must also stop the exchange. We use `context.AfterFunc` to set a deadline in the past when ctx is done,
+//	   which makes any blocked Read/Write return immediately.
+//	2. Error translation: a deadline fires as a `net.Error` time-out. Callers gave us a context, so they expect
+//	   `context.DeadlineExceeded` (or `context.Canceled`), and that is what we return.
+//	3. Cleanup: the deadline is reset to the zero value when we're done so the connection can be reused
+//	   for the next exchange without inheriting an old deadline.
+
+// ExchangeContext writes request to conn and reads one response payload, all within ctx.
+func ExchangeContext(ctx context.Context, conn net.Conn, request Payload) (Payload, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// 1) The whole exchange shares the context's deadline (if it has one).
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2) Cancellation unblocks pending operations by moving the deadline into the past.
+	watcherDone := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(watcherDone)
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+
+	// 3) When we return, stop the watcher and make the connection reusable again.
+	// 	- If the watcher already started, wait for it so it can't overwrite our reset.
+	defer func() {
+		if !stop() {
+			<-watcherDone
+		}
+		_ = conn.SetDeadline(time.Time{})
+	}()
+
+	_, err := request.WriteTo(conn)
+	if err != nil {
+		return nil, exchangeErr(ctx, err)
+	}
+
+	response, err := Decode(conn)
+	if err != nil {
+		return nil, exchangeErr(ctx, err)
+	}
+
+	return response, nil
+}
+
+// exchangeErr turns a deadline error into the context's error.
+func exchangeErr(ctx context.Context, err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		// The deadline fired just before the context noticed it.
+		return context.DeadlineExceeded
+	}
+
+	return err
+}
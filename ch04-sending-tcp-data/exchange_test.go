@@ -0,0 +1,79 @@
+package ch04
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// This test uses a responder that answers the first request immediately and the second one too late.
+//   - The first exchange succeeds.
+//   - The second exchange exceeds its budget and must return `context.DeadlineExceeded`.
+//   - The connection must be usable afterward: the late response is still read with no deadline left behind.
+func TestExchangeContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		for i := 0; ; i++ {
+			p, err := Decode(conn)
+			if err != nil {
+				return
+			}
+			if i == 1 {
+				time.Sleep(500 * time.Millisecond) // slow responder
+			}
+			if _, err = p.WriteTo(conn); err != nil {
+				return
+			}
+		}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	request := String("fast")
+	response, err := ExchangeContext(ctx, conn, &request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.String() != "fast" {
+		t.Errorf("expected %q; actual: %q", "fast", response)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	request = String("slow")
+	_, err = ExchangeContext(ctx, conn, &request)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded; actual: %v", err)
+	}
+
+	// The deadline was reset, so this read waits for the late response instead of timing out.
+	response, err = Decode(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.String() != "slow" {
+		t.Errorf("expected %q; actual: %q", "slow", response)
+	}
+}
@@ -0,0 +1,38 @@
+package ch04
+
+import "net"
+
+// ## Dropping Connections Before the Handler Sees Them
+// Basic abuse mitigation: connections from some addresses (a blocklist, or everything outside an allowlist)
+// should never reach the Handler.
+//	- FilteredListener wraps a `net.Listener` and asks Allow about every accepted connection's remote address.
+//	- A rejected connection is closed right away and Accept waits for the next one,
+//	  so Server.Serve (or any other accept loop) never sees it.
+//	- The TCP handshake has already happened at that point: the peer sees a connection that closes immediately.
+//	  Blocking before the handshake needs a firewall.
+
+// FilteredListener is a net.Listener that only returns connections whose remote address is allowed.
+type FilteredListener struct {
+	net.Listener
+	Allow func(remote net.Addr) bool
+}
+
+// NewFilteredListener wraps l; only connections for which allow returns true are accepted.
+func NewFilteredListener(l net.Listener, allow func(remote net.Addr) bool) *FilteredListener {
+	return &FilteredListener{Listener: l, Allow: allow}
+}
+
+// Accept returns the next allowed connection, closing rejected ones along the way.
+func (l *FilteredListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if l.Allow == nil || l.Allow(conn.RemoteAddr()) {
+			return conn, nil
+		}
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,63 @@
+package ch04
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFilteredListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// On Linux the whole 127.0.0.0/8 block is loopback, so 127.0.0.2 can stand in for an abusive client.
+	blocked := net.ParseIP("127.0.0.2")
+	filtered := NewFilteredListener(listener, func(remote net.Addr) bool {
+		return !remote.(*net.TCPAddr).IP.Equal(blocked)
+	})
+	defer filtered.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := filtered.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	dialFrom := func(ip string) net.Conn {
+		d := net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(ip)}}
+		conn, err := d.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Skipf("can't dial from %s: %v", ip, err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn
+	}
+
+	// The blocked client is disconnected without ever being returned by Accept.
+	bad := dialFrom("127.0.0.2")
+	_ = bad.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err = bad.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Errorf("expected the blocked connection to be closed; actual: %v", err)
+	}
+
+	good := dialFrom("127.0.0.1")
+	select {
+	case conn := <-accepted:
+		defer conn.Close()
+		if conn.RemoteAddr().String() != good.LocalAddr().String() {
+			t.Errorf("expected the allowed connection from %s; actual: %s", good.LocalAddr(), conn.RemoteAddr())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the allowed connection wasn't accepted")
+	}
+}
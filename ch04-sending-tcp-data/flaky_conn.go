@@ -0,0 +1,89 @@
+package ch04
+
+import (
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+// ## A Connection That Misbehaves on Purpose
+// Heartbeats, timeouts and reconnects exist for bad networks, but tests usually run over a perfect loopback.
+// FlakyConn brings the bad network into the test:
+//	- Latency (plus up to Jitter more) is added before every Read and Write, like a slow link.
+//	- DropRate is the probability that a Write silently vanishes: it reports success, but nothing is sent.
+//	  A dropped write in the middle of a frame desynchronizes the stream, exactly like a broken middlebox would.
+//	  DropRate 1 is a peer that is fully dead while its socket still looks fine.
+//	- The randomness comes from a generator seeded with Seed, so a failing test fails the same way every run.
+//
+// NOTE:
+//	- This is a testing tool: the sleeps don't look at deadlines, and the Writes it drops are really gone.
+//	- Only Writes are dropped. To lose data in both directions, wrap both ends of the connection.
+
+// FlakyOptions configures a FlakyConn. The zero value passes everything through unchanged.
+type FlakyOptions struct {
+	Latency  time.Duration // added before every Read and Write
+	Jitter   time.Duration // up to this much more, chosen at random per call
+	DropRate float64       // probability, between 0 and 1, that a Write is discarded
+	Seed     uint64        // seeds the random choices
+}
+
+// FlakyConn is a net.Conn that adds latency and drops writes, reproducibly.
+type FlakyConn struct {
+	net.Conn
+	opts FlakyOptions
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewFlakyConn wraps conn.
+func NewFlakyConn(conn net.Conn, opts FlakyOptions) *FlakyConn {
+	return &FlakyConn{
+		Conn: conn,
+		opts: opts,
+		rand: rand.New(rand.NewPCG(opts.Seed, opts.Seed)),
+	}
+}
+
+// NetConn returns the wrapped connection.
+func (c *FlakyConn) NetConn() net.Conn { return c.Conn }
+
+// Read waits for the latency, then reads.
+func (c *FlakyConn) Read(p []byte) (int, error) {
+	time.Sleep(c.delay())
+	return c.Conn.Read(p)
+}
+
+// Write waits for the latency, then writes p, or pretends to.
+func (c *FlakyConn) Write(p []byte) (int, error) {
+	time.Sleep(c.delay())
+	if c.drop() {
+		return len(p), nil
+	}
+
+	return c.Conn.Write(p)
+}
+
+// delay returns the latency of the next call.
+func (c *FlakyConn) delay() time.Duration {
+	d := c.opts.Latency
+	if c.opts.Jitter > 0 {
+		c.mu.Lock()
+		d += time.Duration(c.rand.Int64N(int64(c.opts.Jitter)))
+		c.mu.Unlock()
+	}
+
+	return d
+}
+
+// drop decides whether the next Write is discarded.
+func (c *FlakyConn) drop() bool {
+	if c.opts.DropRate <= 0 {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rand.Float64() < c.opts.DropRate
+}
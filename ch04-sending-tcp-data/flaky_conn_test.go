@@ -0,0 +1,108 @@
+package ch04
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+// The same seed drops the same writes.
+func TestFlakyConnDeterministic(t *testing.T) {
+	dropped := func(seed uint64) []int {
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		received := make(chan byte, 100)
+		go func() {
+			buf := make([]byte, 1)
+			for {
+				if _, err := server.Read(buf); err != nil {
+					close(received)
+					return
+				}
+				received <- buf[0]
+			}
+		}()
+
+		flaky := NewFlakyConn(client, FlakyOptions{DropRate: 0.3, Seed: seed})
+		for i := range 100 {
+			if _, err := flaky.Write([]byte{byte(i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_ = client.Close()
+
+		var drops []int
+		next := 0
+		for b := range received {
+			for ; next < int(b); next++ {
+				drops = append(drops, next)
+			}
+			next++
+		}
+		for ; next < 100; next++ {
+			drops = append(drops, next)
+		}
+		return drops
+	}
+
+	first, second := dropped(42), dropped(42)
+	if len(first) == 0 || len(first) == 100 {
+		t.Fatalf("expected some writes dropped; actual: %d of 100", len(first))
+	}
+	if !slices.Equal(first, second) {
+		t.Errorf("same seed, different drops:\n%v\n%v", first, second)
+	}
+	if slices.Equal(first, dropped(7)) {
+		t.Error("different seeds dropped the same writes")
+	}
+}
+
+// The peer looks fine at the socket level, but every ping we send is lost on the way: the peer is dead.
+// The Monitor must still notice within MaxMissed × Interval + PongTimeout, latency or not.
+func TestFlakyConnMonitorDetectsDeadPeer(t *testing.T) {
+	client, server := tcpPair(t)
+
+	// The peer would answer, if anything ever arrived.
+	go func() {
+		for {
+			p, err := Decode(server)
+			if err != nil {
+				return
+			}
+			pong := Pong(p.Bytes())
+			_, _ = pong.WriteTo(server)
+		}
+	}()
+
+	const (
+		interval    = 50 * time.Millisecond
+		pongTimeout = 40 * time.Millisecond
+		maxMissed   = 3
+	)
+	flaky := NewFlakyConn(client, FlakyOptions{Latency: 5 * time.Millisecond, Jitter: 5 * time.Millisecond, DropRate: 1, Seed: 1})
+
+	dead := make(chan int, 1)
+	start := time.Now()
+	m := NewMonitor(flaky, MonitorOptions{
+		Interval:    interval,
+		PongTimeout: pongTimeout,
+		MaxMissed:   maxMissed,
+		OnDead:      func(missed int) { dead <- missed },
+	})
+	defer m.Close()
+
+	expected := maxMissed*(interval+10*time.Millisecond) + pongTimeout // with the worst-case latency
+	select {
+	case <-dead:
+		elapsed := time.Since(start)
+		t.Logf("peer declared dead after %s (expected within %s)", elapsed, expected)
+		if elapsed > expected+200*time.Millisecond {
+			t.Errorf("took %s to detect the dead peer; expected about %s", elapsed, expected)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the dead peer was never detected")
+	}
+}
@@ -0,0 +1,96 @@
+package ch04
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ## Shared Frame Helpers for New Payload Types
+// Binary and String spell out every step of writing and reading a TLV frame, which is great for learning.
+// The payload types added later (Ping, Pong, ...) are all "a type byte plus some bytes",
+// so instead of copying those steps again they share two small helpers:
+//	- writeTLV writes [Type][Length][Value] exactly like Binary.WriteTo (including its fast path for small values).
+//	- readTLV reads the frame back, checks the type and enforces MaxPayloadSize.
+//	  Like Decode, it returns io.EOF only when the stream ends before the type byte (see below).
+//	- Unlike Binary.ReadFrom, readTLV uses `io.ReadFull` for the value,
+//	  because a single Read is not guaranteed to return all `size` bytes (see the note in String.ReadFrom).
+
+// ### Where did the stream end?
+// Decode works on any `io.Reader`: a connection, a `bytes.Reader` holding a captured session, a decompressing reader, ...
+// When such a reader runs out, it matters WHERE it ran out:
+//	- before the first byte of a frame: the stream simply ended. Decode returns io.EOF, as always.
+//	- anywhere inside a frame (header or value): data is missing. Decode returns ErrTruncatedFrame.
+// Binary.ReadFrom, String.ReadFrom and readTLV (so every payload type built on it) make the same distinction.
+// `io.ReadFull` alone doesn't make that distinction: it reports io.EOF when it read nothing at all,
+// even if that happens right after a frame's header. truncated fixes up the errors from inside a frame.
+
+// ErrTruncatedFrame is returned when the stream ends in the middle of a frame.
+//   - It wraps io.ErrUnexpectedEOF, so existing checks for that error keep working.
+var ErrTruncatedFrame = fmt.Errorf("truncated frame: %w", io.ErrUnexpectedEOF)
+
+// truncated turns an end of stream inside a frame into ErrTruncatedFrame and returns other errors as is.
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrTruncatedFrame
+	}
+
+	return err
+}
+
+// writeTLV writes a complete TLV frame of the given type and value.
+func writeTLV(w io.Writer, typ uint8, value []byte) (int64, error) {
+	if len(value) <= smallFrameMax {
+		return writeSmallFrame(w, typ, value)
+	}
+
+	err := binary.Write(w, binary.BigEndian, typ) // 1-byte type
+	if err != nil {
+		return 0, err
+	}
+	var n int64 = 1
+
+	err = binary.Write(w, binary.BigEndian, uint32(len(value))) // 4-byte size
+	if err != nil {
+		return n, err
+	}
+	n += 4
+
+	o, err := w.Write(value) // payload
+	return n + int64(o), err
+}
+
+// readTLV reads a complete TLV frame and returns its value.
+//   - name is used in the error when the type byte is not typ (for example "invalid Ping").
+func readTLV(r io.Reader, typ uint8, name string) ([]byte, int64, error) {
+	var actual uint8
+	err := binary.Read(r, binary.BigEndian, &actual) // 1-byte type
+	if err != nil {
+		return nil, 0, err
+	}
+	var n int64 = 1
+
+	if actual != typ {
+		return nil, n, errors.New("invalid " + name)
+	}
+
+	var size uint32
+	err = binary.Read(r, binary.BigEndian, &size) // 4-byte size
+	if err != nil {
+		return nil, n, truncated(err) // the type byte was read: we are inside a frame
+	}
+	n += 4
+
+	if size > MaxPayloadSize {
+		return nil, n, ErrMaxPayloadSize
+	}
+
+	value := make([]byte, size)
+	o, err := io.ReadFull(r, value) // payload
+	if err != nil {
+		return nil, n + int64(o), truncated(err)
+	}
+
+	return value, n + int64(o), nil
+}
@@ -0,0 +1,104 @@
+package ch04
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// ## A Frame-Aware Proxy
+// proxyConn (Listing 4-14) copies raw bytes: it can't look at what it forwards.
+// Proxy decodes every frame instead, so it can inspect, rewrite or drop payloads on the way.
+//	- Both directions run at the same time, each in its own goroutine:
+//		1. Decode a frame from one connection
+//		2. pass it through transform (nil means forward as is; a nil result drops the frame)
+//		3. WriteTo the other connection
+//	- When one side finishes cleanly (io.EOF), the proxy half-closes the other side with CloseWrite,
+//	  so a peer that waits for the end of the request still gets its response back.
+//	  Connections that can't be half-closed stop the whole proxy instead.
+//	- Any other error, a failing transform, or ctx being done stops both directions:
+//	  a deadline in the past unblocks the pending Decode and WriteTo calls.
+//
+// ### A transform per direction
+// Proxy applies the same transform both ways. A stateful transform (like Deduper) often belongs to one direction only:
+//	- ProxyTransforms takes one for each: toDst for the frames from src, toSrc for the frames from dst.
+//	- Proxy is ProxyTransforms with the same transform twice.
+//
+// NOTE:
+//	- Proxy doesn't close the connections; that is up to the caller.
+//	  After a stop, their deadlines are expired, so they aren't usable for anything but Close.
+
+// Proxy forwards frames between src and dst in both directions until both are done, an error occurs or ctx is done.
+//   - It returns nil when the proxy ended cleanly (io.EOF or ctx), and otherwise the first error.
+func Proxy(ctx context.Context, src, dst net.Conn, transform func(Payload) (Payload, error)) error {
+	return ProxyTransforms(ctx, src, dst, transform, transform)
+}
+
+// ProxyTransforms is Proxy with a transform per direction: toDst for frames from src, toSrc for frames from dst.
+func ProxyTransforms(ctx context.Context, src, dst net.Conn, toDst, toSrc func(Payload) (Payload, error)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stop := context.AfterFunc(ctx, func() {
+		past := time.Unix(1, 0)
+		_ = src.SetDeadline(past)
+		_ = dst.SetDeadline(past)
+	})
+	defer stop()
+
+	errs := make(chan error, 2)
+	direction := func(from, to net.Conn, transform func(Payload) (Payload, error)) {
+		err := forward(from, to, transform)
+		switch {
+		case ctx.Err() != nil && isTimeout(err):
+			errs <- nil // the deadline set by the stop, not a failure
+			return
+		case errors.Is(err, io.EOF):
+			if cw, ok := findCloseWriter(to); ok {
+				_ = cw.CloseWrite()
+				errs <- nil
+				return // the other direction carries on
+			}
+			err = nil
+		}
+
+		cancel()
+		errs <- err
+	}
+	go direction(src, dst, toDst)
+	go direction(dst, src, toSrc)
+
+	var first error
+	for range 2 {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// forward copies frames from one connection to the other until an error.
+func forward(from, to net.Conn, transform func(Payload) (Payload, error)) error {
+	for {
+		p, err := Decode(from)
+		if err != nil {
+			return err
+		}
+
+		if transform != nil {
+			if p, err = transform(p); err != nil {
+				return err
+			}
+			if p == nil {
+				continue // dropped
+			}
+		}
+
+		if _, err = p.WriteTo(to); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package ch04
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyIdentity(t *testing.T) {
+	client, proxyIn := tcpPair(t)
+	proxyOut, server := tcpPair(t)
+	go func() { _ = echo(server) }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Proxy(ctx, proxyIn, proxyOut, nil) }()
+
+	for _, value := range []string{"first", "second", strings.Repeat("x", 64<<10)} {
+		sent := Binary(value)
+		if _, err := sent.WriteTo(client); err != nil {
+			t.Fatal(err)
+		}
+
+		received, err := Decode(client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if received.String() != value {
+			t.Fatalf("expected %d bytes back; actual: %d", len(value), len(received.Bytes()))
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected a clean stop; actual: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Proxy didn't stop after cancel")
+	}
+}
+
+func TestProxyTransformAndHalfClose(t *testing.T) {
+	client, proxyIn := tcpPair(t)
+	proxyOut, server := tcpPair(t)
+
+	upper := func(p Payload) (Payload, error) {
+		if _, ok := p.(*Ping); ok {
+			return nil, nil // dropped
+		}
+		s := String(strings.ToUpper(p.String()))
+		return &s, nil
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- Proxy(context.Background(), proxyIn, proxyOut, upper) }()
+
+	// The client sends a request and closes its write side; the response must still come back.
+	ping := Ping("dropped")
+	request := String("hello")
+	_, _ = ping.WriteTo(client)
+	_, _ = request.WriteTo(client)
+	if err := closeWrite(client); err != nil {
+		t.Fatal(err)
+	}
+
+	received, err := Decode(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.String() != "HELLO" {
+		t.Fatalf("expected the transformed request; actual: %q", received)
+	}
+	if _, err = Decode(server); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected the half-close to reach the server; actual: %v", err)
+	}
+
+	response := String("world")
+	_, _ = response.WriteTo(server)
+	_ = server.Close()
+
+	received, err = Decode(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.String() != "WORLD" {
+		t.Errorf("expected the transformed response; actual: %q", received)
+	}
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Errorf("expected a clean end; actual: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Proxy didn't return after both sides finished")
+	}
+}
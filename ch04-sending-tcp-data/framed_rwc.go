@@ -0,0 +1,66 @@
+package ch04
+
+import (
+	"fmt"
+	"io"
+	"net"
+)
+
+// ## Messages Through an io.ReadWriteCloser
+// Plenty of code only knows io.Reader and io.Writer. FramedRWC lets such code use our framing without knowing it:
+//	- Every Write sends its bytes as ONE Binary frame. Writes are never merged or split.
+//	- Every Read returns bytes of ONE frame only, never the end of one message and the start of the next.
+//	  So the message boundaries the writer chose survive the stream, as with a datagram socket.
+//	- A message larger than the Read buffer is returned over several Reads, in order, before the next message starts.
+//	  With a buffer at least as large as the biggest message, each Read returns exactly one message.
+//	- An empty Write sends an empty frame, and the matching Read returns 0, nil.
+//	- A frame that isn't Binary makes Read fail with ErrUnexpectedPayload: the peer isn't speaking this protocol.
+//	- Close closes the connection.
+//
+// It is not safe for concurrent Reads (or concurrent Writes), just like a bufio.Reader.
+
+// FramedRWC returns an io.ReadWriteCloser that sends every Write as a Binary frame on conn
+// and reads back one frame per message.
+func FramedRWC(conn net.Conn) io.ReadWriteCloser {
+	return &framedRWC{conn: conn}
+}
+
+type framedRWC struct {
+	conn net.Conn
+	rest []byte // the part of the current message not yet returned by Read
+	mid  bool   // a message is being returned over several Reads
+}
+
+func (f *framedRWC) Read(p []byte) (int, error) {
+	if !f.mid {
+		payload, err := Decode(f.conn)
+		if err != nil {
+			return 0, err
+		}
+		b, ok := payload.(*Binary)
+		if !ok {
+			return 0, fmt.Errorf("%w: expected *Binary, got %T", ErrUnexpectedPayload, payload)
+		}
+		f.rest, f.mid = *b, true
+	}
+
+	n := copy(p, f.rest)
+	f.rest = f.rest[n:]
+	f.mid = len(f.rest) > 0
+
+	return n, nil
+}
+
+func (f *framedRWC) Write(p []byte) (int, error) {
+	if len(p) > int(MaxPayloadSize) {
+		return 0, ErrMaxPayloadSize
+	}
+
+	if _, err := Binary(p).WriteTo(f.conn); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (f *framedRWC) Close() error { return f.conn.Close() }
@@ -0,0 +1,65 @@
+package ch04
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestFramedRWC(t *testing.T) {
+	client, server := tcpPair(t)
+	w, r := FramedRWC(client), FramedRWC(server)
+
+	messages := []string{"one", "two, a little longer", "three"}
+	for _, m := range messages {
+		if n, err := w.Write([]byte(m)); err != nil || n != len(m) {
+			t.Fatalf("Write(%q) = %d, %v", m, n, err)
+		}
+	}
+
+	// All three frames are in the socket by now, but every Read returns one message.
+	buf := make([]byte, 64)
+	for _, expected := range messages {
+		n, err := r.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != expected {
+			t.Errorf("expected %q; actual: %q", expected, buf[:n])
+		}
+	}
+
+	// A small buffer gets the message in pieces, without crossing into the next one.
+	for _, m := range []string{"abcdefg", "next"} {
+		if _, err := w.Write([]byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	small := make([]byte, 3)
+	var pieces []string
+	for range 5 {
+		n, err := r.Read(small)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pieces = append(pieces, string(small[:n]))
+	}
+	expected := []string{"abc", "def", "g", "nex", "t"}
+	for i := range expected {
+		if pieces[i] != expected[i] {
+			t.Errorf("read %d: expected %q; actual: %q", i, expected[i], pieces[i])
+		}
+	}
+
+	// A frame of another type is an error; after Close the reader sees io.EOF.
+	if _, err := String("not binary").WriteTo(client); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(buf); !errors.Is(err, ErrUnexpectedPayload) {
+		t.Errorf("expected ErrUnexpectedPayload; actual: %v", err)
+	}
+	_ = w.Close()
+	if _, err := r.Read(buf); err != io.EOF {
+		t.Errorf("expected io.EOF; actual: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package ch04
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+)
+
+// ## Telling Clients the Server Is Going Away
+// When Shutdown closes a connection, the client only sees io.EOF (or a reset) and can't tell a crash from a planned restart.
+// A GoAway frame says it explicitly: "this server is shutting down, reconnect elsewhere".
+//	- [GoAwayType][Length] [Reason]: the reason is free text for logs, like ProtocolError's message.
+//	- With Server.SendGoAway set, Shutdown writes a GoAway on every open connection before it waits for the handlers.
+//	  The client decodes it like any other payload and can start reconnecting while the server finishes its work.
+//	- The handler may keep writing after the GoAway (to finish requests in progress); the connection closes as usual
+//	  when the handler returns, or when Shutdown's context is done.
+//
+// ### Not cutting into a frame
+// The handler may be in the middle of writing a frame when Shutdown comes, and a GoAway written right then would
+// land inside that frame. goAwayConn tracks the frames the handler writes (with frameTracker, like ageConn):
+//	- At a frame boundary the GoAway is written immediately.
+//	- Otherwise it is written by the handler's Write that completes the frame, right after the frame's last byte.
+
+// GoAwayType is the type byte of a GoAway frame.
+const GoAwayType uint8 = 9
+
+// goAwayReason is the reason the Server sends on Shutdown.
+const goAwayReason = "server shutting down"
+
+func init() {
+	Register(GoAwayType, func() Payload { return new(GoAway) })
+}
+
+// GoAway tells the peer that the sender is shutting down; the value is the reason.
+type GoAway string
+
+func (m GoAway) Bytes() []byte  { return []byte(m) }
+func (m GoAway) String() string { return string(m) }
+
+func (m GoAway) WriteTo(w io.Writer) (int64, error) { return writeTLV(w, GoAwayType, []byte(m)) }
+
+func (m *GoAway) ReadFrom(r io.Reader) (int64, error) {
+	value, n, err := readTLV(r, GoAwayType, "GoAway")
+	if err != nil {
+		return n, err
+	}
+
+	*m = GoAway(value)
+	return n, nil
+}
+
+// goAwayConn is the net.Conn a Server hands to its Handler when SendGoAway is set.
+type goAwayConn struct {
+	net.Conn
+
+	mu      sync.Mutex
+	frame   frameTracker
+	pending []byte // the GoAway frame, waiting for the end of the frame in progress
+}
+
+// NetConn returns the wrapped connection.
+func (c *goAwayConn) NetConn() net.Conn { return c.Conn }
+
+// goAway writes a GoAway frame now, or after the frame in progress.
+func (c *goAwayConn) goAway(reason string) error {
+	var buf bytes.Buffer
+	_, _ = GoAway(reason).WriteTo(&buf)
+	frame := buf.Bytes()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.frame.atBoundary() {
+		c.pending = frame
+		return nil
+	}
+
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+// Write writes p; a pending GoAway goes out as soon as the frame in progress is complete.
+func (c *goAwayConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending == nil {
+		n, err := c.Conn.Write(p)
+		c.frame.advance(p[:n])
+		return n, err
+	}
+
+	// The rest of the frame in progress, then the GoAway, then whatever follows.
+	head := c.frame.remaining(p)
+	n, err := c.Conn.Write(p[:head])
+	c.frame.advance(p[:n])
+	if err != nil {
+		return n, err
+	}
+
+	if c.frame.atBoundary() {
+		if _, err = c.Conn.Write(c.pending); err != nil {
+			return n, err
+		}
+		c.pending = nil
+	}
+
+	if head < len(p) {
+		m, err := c.Conn.Write(p[head:])
+		c.frame.advance(p[head : head+m])
+		return n + m, err
+	}
+
+	return n, nil
+}
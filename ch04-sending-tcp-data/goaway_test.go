@@ -0,0 +1,130 @@
+package ch04
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServerShutdownGoAway(t *testing.T) {
+	s := &Server{
+		SendGoAway: true,
+		Handler: func(conn net.Conn) { // echo frames until the client hangs up
+			for {
+				p, err := Decode(conn)
+				if err != nil {
+					return
+				}
+				if _, err = p.WriteTo(conn); err != nil {
+					return
+				}
+			}
+		},
+	}
+	addr := startServer(t, s)
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// One round trip, so the handler is surely running.
+	if _, err = Binary("hello").WriteTo(conn); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Decode(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown <- s.Shutdown(ctx)
+	}()
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	p, err := Decode(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ga, ok := p.(*GoAway); !ok || *ga != goAwayReason {
+		t.Fatalf("expected GoAway %q; actual: %T %v", goAwayReason, p, p)
+	}
+
+	// The connection is still open: Shutdown waits for the handler, which still answers.
+	select {
+	case err = <-shutdown:
+		t.Fatalf("Shutdown returned before the client hung up: %v", err)
+	default:
+	}
+	if _, err = String("last").WriteTo(conn); err != nil {
+		t.Fatal(err)
+	}
+	if p, err = Decode(conn); err != nil || p.String() != "last" {
+		t.Fatalf("expected the last echo; actual: %v, %v", p, err)
+	}
+
+	// The client hangs up, the handler returns and Shutdown completes.
+	_ = conn.Close()
+	select {
+	case err = <-shutdown:
+		if err != nil {
+			t.Errorf("expected a clean shutdown; actual: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Shutdown did not return after the client hung up")
+	}
+}
+
+// A GoAway requested in the middle of a frame goes out right after that frame, not inside it.
+func TestGoAwayConnMidFrame(t *testing.T) {
+	client, server := tcpPair(t)
+	ga := &goAwayConn{Conn: server}
+
+	var frames bytes.Buffer
+	for _, p := range []Payload{ptr(Binary("first")), ptr(String("second"))} {
+		if _, err := p.WriteTo(&frames); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream := frames.Bytes()
+	split := tlvHeaderSize + 2 // inside the first frame's value
+
+	if _, err := ga.Write(stream[:split]); err != nil {
+		t.Fatal(err)
+	}
+	if err := ga.goAway("bye"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ga.Write(stream[split:]); err != nil { // the end of the first frame and all of the second
+		t.Fatal(err)
+	}
+	_ = ga.Close()
+
+	var received []string
+	for {
+		p, err := Decode(client)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, p.String())
+	}
+
+	expected := []string{"first", "bye", "second"}
+	if len(received) != len(expected) {
+		t.Fatalf("expected %q; actual: %q", expected, received)
+	}
+	for i := range expected {
+		if received[i] != expected[i] {
+			t.Errorf("frame %d: expected %q; actual: %q", i, expected[i], received[i])
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package ch04
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// ## Detecting a Half-Open Connection
+// When the peer disappears without a FIN or RST (cable unplugged, host powered off, NAT entry dropped),
+// our side of the connection still looks open: Reads just block, and Writes succeed until the kernel gives up
+// retransmitting, which can take many minutes.
+//	- The only way to find out sooner is to ask the peer and expect an answer in time.
+//	- IsHalfOpen does that with Probe: it sends a Ping and waits up to probeTimeout for the Pong.
+//	- No Pong in time (or a failed Write/Read) means the connection is most likely half-open.
+//	- An answer of another type still proves the peer is there, so it doesn't count as half-open.
+//
+// NOTE:
+//	- The peer must answer Pings with Pongs (like Monitor or a Router with a Ping handler);
+//	  a healthy peer that doesn't pong looks exactly like a dead one.
+//	- The probe reads the next frame from conn: don't call it while another goroutine is reading.
+
+// IsHalfOpen reports whether conn's peer failed to answer a Ping within probeTimeout.
+func IsHalfOpen(conn net.Conn, probeTimeout time.Duration) bool {
+	err := Probe(context.Background(), conn, probeTimeout)
+	return err != nil && !errors.Is(err, ErrUnexpectedPayload)
+}
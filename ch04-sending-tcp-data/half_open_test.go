@@ -0,0 +1,45 @@
+package ch04
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestIsHalfOpen(t *testing.T) {
+	t.Run("responder", func(t *testing.T) {
+		router := NewRouter()
+		router.Handle(PingType, func(p Payload) (Payload, error) {
+			pong := Pong(p.Bytes())
+			return &pong, nil
+		})
+
+		server, client := net.Pipe()
+		defer client.Close()
+		go func() {
+			_ = router.ServeConn(server)
+			_ = server.Close()
+		}()
+
+		if IsHalfOpen(client, time.Second) {
+			t.Fatal("expected a responding peer not to be half-open")
+		}
+	})
+
+	t.Run("silent peer", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		// Read the Ping, never answer: like a peer that is gone.
+		go func() { _, _ = Decode(server) }()
+
+		start := time.Now()
+		if !IsHalfOpen(client, 100*time.Millisecond) {
+			t.Fatal("expected a silent peer to be half-open")
+		}
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > time.Second {
+			t.Fatalf("IsHalfOpen returned after %s; expected about the probe timeout", elapsed)
+		}
+	})
+}
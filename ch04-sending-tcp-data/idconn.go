@@ -0,0 +1,61 @@
+package ch04
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+// ## Giving Every Connection an ID
+// Log lines from many concurrent connections are hard to follow: "read failed" — but on which connection?
+// Remote addresses are not unique over time (ports get reused), so we number the connections instead.
+//	- IDListener wraps a `net.Listener`. Every connection it accepts gets the next number: 1, 2, 3, ...
+//	- The connection comes back as an *IDConn, which is still a `net.Conn` with one extra method, ID.
+//	- A handler that only sees a `net.Conn` (for example a Server Handler) can get the ID back with ConnID.
+
+// IDConn is a net.Conn with a unique, increasing ID.
+type IDConn struct {
+	net.Conn
+	id uint64
+}
+
+// ID returns the connection's ID.
+func (c *IDConn) ID() uint64 { return c.id }
+
+// NetConn returns the wrapped connection.
+func (c *IDConn) NetConn() net.Conn { return c.Conn }
+
+// IDListener is a net.Listener whose connections are *IDConn, numbered in accept order starting at 1.
+type IDListener struct {
+	net.Listener
+	last atomic.Uint64
+}
+
+// NewIDListener wraps l so that every accepted connection gets an ID.
+func NewIDListener(l net.Listener) *IDListener {
+	return &IDListener{Listener: l}
+}
+
+// Accept waits for the next connection and assigns it the next ID.
+func (l *IDListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &IDConn{Conn: conn, id: l.last.Add(1)}, nil
+}
+
+// ConnID returns the ID of a connection accepted by an IDListener.
+//   - It looks through wrappers that expose the connection they wrap with a NetConn method (like *tls.Conn).
+func ConnID(conn net.Conn) (uint64, bool) {
+	for {
+		switch c := conn.(type) {
+		case interface{ ID() uint64 }:
+			return c.ID(), true
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return 0, false
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package ch04
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIDListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := NewIDListener(listener)
+	defer ids.Close()
+
+	for expected := uint64(1); expected <= 3; expected++ {
+		client, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+
+		conn, err := ids.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		if id := conn.(*IDConn).ID(); id != expected {
+			t.Errorf("expected ID %d; actual: %d", expected, id)
+		}
+		if id, ok := ConnID(conn); !ok || id != expected {
+			t.Errorf("expected ConnID %d; actual: %d, %t", expected, id, ok)
+		}
+	}
+
+	if _, ok := ConnID(&net.TCPConn{}); ok {
+		t.Error("expected no ID for a plain connection")
+	}
+}
+
+// The ID is available to a Server Handler through ConnID.
+func TestIDListenerServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(chan uint64)
+	s := &Server{Handler: func(conn net.Conn) {
+		id, _ := ConnID(conn)
+		seen <- id
+	}}
+	go func() { _ = s.Serve(NewIDListener(listener)) }()
+	defer s.Shutdown(t.Context())
+
+	for expected := uint64(1); expected <= 3; expected++ {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		if id := <-seen; id != expected {
+			t.Errorf("expected ID %d; actual: %d", expected, id)
+		}
+	}
+}
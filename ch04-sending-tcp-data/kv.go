@@ -0,0 +1,134 @@
+package ch04
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// ## A Key/Value Payload
+// Many messages are just a few named fields ("user": "reza", "action": "login"). JSON would do, but costs
+// a parser and quoting for what is a handful of strings. KV is a map[string]string in a compact binary form:
+//	- [KVType][Length] [Count: 4 bytes] then Count times [KeyLen: 4 bytes][Key][ValueLen: 4 bytes][Value]
+//	- Keys are written in sorted order, so the same map always encodes to the same bytes (see Equal in equal.go).
+//	- WriteTo refuses a map whose encoding exceeds MaxPayloadSize with ErrMaxPayloadSize.
+//
+// ### Never trusting the lengths
+// Count and every length come from the peer. A malformed (or hostile) frame could announce 4 billion entries,
+// or a key longer than the frame, and a naive decoder would allocate gigabytes or slice out of range and panic.
+//	- Every length is checked against the bytes actually left in the value before it is used.
+//	- Count can't exceed what the value could hold (each entry takes at least 8 bytes of lengths).
+//	- Bytes left over after the last entry, and a key that appears twice, are errors too.
+//	- All of them fail with ErrInvalidKV.
+
+// KVType is the type byte of a KV frame.
+const KVType uint8 = 11
+
+// kvLengthSize is the size of Count and of every key and value length.
+const kvLengthSize = 4
+
+// ErrInvalidKV is returned for a malformed KV frame.
+var ErrInvalidKV = errors.New("invalid KV")
+
+func init() {
+	Register(KVType, func() Payload { return new(KV) })
+}
+
+// KV is a map of string keys to string values.
+type KV map[string]string
+
+// Bytes returns the encoded value, with the keys in sorted order.
+func (m KV) Bytes() []byte {
+	size := kvLengthSize
+	for k, v := range m {
+		size += 2*kvLengthSize + len(k) + len(v)
+	}
+
+	value := make([]byte, 0, size)
+	value = binary.BigEndian.AppendUint32(value, uint32(len(m)))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		value = binary.BigEndian.AppendUint32(value, uint32(len(k)))
+		value = append(value, k...)
+		value = binary.BigEndian.AppendUint32(value, uint32(len(m[k])))
+		value = append(value, m[k]...)
+	}
+	return value
+}
+
+func (m KV) String() string { return fmt.Sprint(map[string]string(m)) }
+
+// WriteTo writes the KV frame, unless its value would exceed MaxPayloadSize.
+func (m KV) WriteTo(w io.Writer) (int64, error) {
+	value := m.Bytes()
+	if len(value) > int(MaxPayloadSize) {
+		return 0, ErrMaxPayloadSize
+	}
+
+	return writeTLV(w, KVType, value)
+}
+
+// ReadFrom reads a KV frame, validating every count and length (see above).
+func (m *KV) ReadFrom(r io.Reader) (int64, error) {
+	value, n, err := readTLV(r, KVType, "KV")
+	if err != nil {
+		return n, err
+	}
+
+	kv, err := parseKV(value)
+	if err != nil {
+		return n, err
+	}
+	*m = kv
+	return n, nil
+}
+
+// parseKV decodes a KV value.
+func parseKV(value []byte) (KV, error) {
+	if len(value) < kvLengthSize {
+		return nil, fmt.Errorf("%w: missing count", ErrInvalidKV)
+	}
+	count := binary.BigEndian.Uint32(value)
+	value = value[kvLengthSize:]
+	if uint64(count) > uint64(len(value)/(2*kvLengthSize)) {
+		return nil, fmt.Errorf("%w: %d entries can't fit in %d bytes", ErrInvalidKV, count, len(value))
+	}
+
+	// next cuts one length-prefixed string off the front of value.
+	next := func() (string, bool) {
+		if len(value) < kvLengthSize {
+			return "", false
+		}
+		size := binary.BigEndian.Uint32(value)
+		value = value[kvLengthSize:]
+		if uint64(size) > uint64(len(value)) {
+			return "", false
+		}
+		s := string(value[:size])
+		value = value[size:]
+		return s, true
+	}
+
+	kv := make(KV, count)
+	for i := range count {
+		k, ok := next()
+		if !ok {
+			return nil, fmt.Errorf("%w: entry %d: bad key length", ErrInvalidKV, i)
+		}
+		v, ok := next()
+		if !ok {
+			return nil, fmt.Errorf("%w: entry %d: bad value length", ErrInvalidKV, i)
+		}
+		if _, dup := kv[k]; dup {
+			return nil, fmt.Errorf("%w: duplicate key %q", ErrInvalidKV, k)
+		}
+		kv[k] = v
+	}
+	if len(value) > 0 {
+		return nil, fmt.Errorf("%w: %d bytes after the last entry", ErrInvalidKV, len(value))
+	}
+
+	return kv, nil
+}
@@ -0,0 +1,86 @@
+package ch04
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestKVRoundTrip(t *testing.T) {
+	for _, expected := range []KV{
+		{"user": "reza", "action": "login", "empty": "", "": "empty key", "utf8": "سلام"},
+		{},
+	} {
+		var buf bytes.Buffer
+		if _, err := expected.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, ok := p.(*KV)
+		if !ok {
+			t.Fatalf("expected *KV; actual: %T", p)
+		}
+		if !maps.Equal(*actual, expected) {
+			t.Errorf("expected %v; actual: %v", expected, *actual)
+		}
+	}
+}
+
+// The same map always encodes to the same bytes, whatever the map's iteration order.
+func TestKVDeterministic(t *testing.T) {
+	m := KV{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5"}
+	first := m.Bytes()
+	for range 20 {
+		if !bytes.Equal(first, m.Bytes()) {
+			t.Fatal("encoding changed between calls")
+		}
+	}
+}
+
+// Malformed values fail with ErrInvalidKV instead of panicking or allocating what they announce.
+func TestKVMalformed(t *testing.T) {
+	u32 := func(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	tests := map[string][]byte{
+		"empty value":         {},
+		"short count":         {0, 1},
+		"huge count":          u32(0xFFFFFFFF),
+		"key longer than all": join(u32(1), u32(1000), []byte("k"), u32(0)),
+		"cut value length":    join(u32(1), u32(2), []byte("ke"), []byte{0, 0}),
+		"value too long":      join(u32(1), u32(1), []byte("k"), u32(50), []byte("v")),
+		"trailing bytes":      join(u32(1), u32(1), []byte("k"), u32(1), []byte("v"), []byte("extra")),
+		"duplicate key":       join(u32(2), u32(1), []byte("k"), u32(1), []byte("v"), u32(1), []byte("k"), u32(1), []byte("w")),
+	}
+
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			var frame bytes.Buffer
+			if _, err := writeTLV(&frame, KVType, value); err != nil {
+				t.Fatal(err)
+			}
+
+			var kv KV
+			if _, err := kv.ReadFrom(&frame); !errors.Is(err, ErrInvalidKV) {
+				t.Errorf("expected ErrInvalidKV; actual: %v", err)
+			}
+		})
+	}
+}
+
+func TestKVMaxPayloadSize(t *testing.T) {
+	kv := KV{"big": string(make([]byte, MaxPayloadSize))}
+	var buf bytes.Buffer
+	if _, err := kv.WriteTo(&buf); !errors.Is(err, ErrMaxPayloadSize) {
+		t.Errorf("expected ErrMaxPayloadSize; actual: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing written; actual: %d bytes", buf.Len())
+	}
+}
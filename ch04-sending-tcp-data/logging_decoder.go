@@ -0,0 +1,85 @@
+package ch04
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"log/slog"
+)
+
+// ## Logging Every Received Payload
+// An audit trail needs to know what came in over a connection, frame by frame.
+// LoggingDecoder wraps a Decoder and emits one structured `log/slog` record per decoded payload, before returning it:
+//	- msg "frame", with the attributes:
+//		- type: the type byte
+//		- size: the value length in bytes
+//		- preview: the first PreviewBytes bytes of the value, hex-encoded
+//		- truncated: true when the preview is shorter than the value
+//	- Decode errors are logged at Warn level ("decode failed"), except io.EOF: the normal end of a stream.
+//
+// ### Keeping secrets out of the log
+// A value may be a password, a token or personal data. Its type and size are usually fine to log; its bytes are not.
+//	- With PreviewTypes set, only the listed types get a preview; for all others it is left out.
+//	- PreviewTypes nil means every type is previewed; to log no bytes at all, use an empty (non-nil) map.
+
+// defaultPreviewBytes is the preview length when LoggingOptions.PreviewBytes is zero.
+const defaultPreviewBytes = 16
+
+// LoggingOptions configures a LoggingDecoder.
+type LoggingOptions struct {
+	// PreviewBytes caps the value bytes included in each record; zero means 16.
+	PreviewBytes int
+	// PreviewTypes, if not nil, lists the types whose value may be previewed.
+	PreviewTypes map[uint8]bool
+}
+
+// LoggingDecoder logs every payload a Decoder returns.
+type LoggingDecoder struct {
+	dec    *Decoder
+	logger *slog.Logger
+	opts   LoggingOptions
+}
+
+// NewLoggingDecoder returns a LoggingDecoder logging the payloads of dec to logger (slog.Default() if nil).
+func NewLoggingDecoder(dec *Decoder, logger *slog.Logger, opts LoggingOptions) *LoggingDecoder {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if opts.PreviewBytes <= 0 {
+		opts.PreviewBytes = defaultPreviewBytes
+	}
+
+	return &LoggingDecoder{dec: dec, logger: logger, opts: opts}
+}
+
+// Decode decodes the next payload, logs it and returns it.
+func (d *LoggingDecoder) Decode() (Payload, error) {
+	p, err := d.dec.Decode()
+	if err != nil {
+		if err != io.EOF {
+			d.logger.Warn("decode failed", slog.Any("error", err))
+		}
+		return nil, err
+	}
+
+	typ, size, err := frameHeader(p)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := []slog.Attr{
+		slog.Int("type", int(typ)),
+		slog.Int("size", int(size)),
+	}
+	if d.opts.PreviewTypes == nil || d.opts.PreviewTypes[typ] {
+		value := p.Bytes()
+		n := min(len(value), d.opts.PreviewBytes)
+		attrs = append(attrs,
+			slog.String("preview", hex.EncodeToString(value[:n])),
+			slog.Bool("truncated", n < len(value)),
+		)
+	}
+	d.logger.LogAttrs(context.Background(), slog.LevelInfo, "frame", attrs...)
+
+	return p, nil
+}
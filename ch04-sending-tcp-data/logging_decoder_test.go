@@ -0,0 +1,79 @@
+package ch04
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestLoggingDecoder(t *testing.T) {
+	secret := String("hunter2")
+	long := Binary(bytes.Repeat([]byte{0xab}, 100))
+	short := Binary("hi")
+
+	var stream bytes.Buffer
+	for _, p := range []Payload{&long, &short, &secret} {
+		if _, err := p.WriteTo(&stream); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	dec := NewLoggingDecoder(
+		NewDecoder(&stream, DecoderOptions{}),
+		slog.New(slog.NewJSONHandler(&out, nil)),
+		LoggingOptions{PreviewBytes: 8, PreviewTypes: map[uint8]bool{BinaryType: true}},
+	)
+	for {
+		if _, err := dec.Decode(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type record struct {
+		Msg       string
+		Type      int
+		Size      int
+		Preview   *string
+		Truncated *bool
+	}
+	var records []record
+	for _, line := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		var r record
+		if err := json.Unmarshal(line, &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records (and nothing for io.EOF); actual: %d\n%s", len(records), out.String())
+	}
+
+	check := func(r record, typ, size int, preview string, truncated bool) {
+		t.Helper()
+		if r.Msg != "frame" || r.Type != typ || r.Size != size {
+			t.Errorf("expected frame type %d size %d; actual: %+v", typ, size, r)
+		}
+		if r.Preview == nil || *r.Preview != preview {
+			t.Errorf("expected preview %q; actual: %v", preview, r.Preview)
+		}
+		if r.Truncated == nil || *r.Truncated != truncated {
+			t.Errorf("expected truncated %t; actual: %v", truncated, r.Truncated)
+		}
+	}
+	check(records[0], int(BinaryType), 100, hex.EncodeToString(long[:8]), true)
+	check(records[1], int(BinaryType), 2, hex.EncodeToString(short), false)
+
+	// String isn't in PreviewTypes: type and size only.
+	if r := records[2]; r.Type != int(StringType) || r.Size != len(secret) || r.Preview != nil || r.Truncated != nil {
+		t.Errorf("expected no preview for the String; actual: %+v", r)
+	}
+	if bytes.Contains(out.Bytes(), []byte(hex.EncodeToString([]byte(secret)))) {
+		t.Error("the secret value was logged")
+	}
+}
@@ -0,0 +1,65 @@
+package ch04
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ## A Timestamp and Sequence Number Payload
+// To diagnose ordering problems (reordered, duplicated or lost messages) a receiver needs two facts per message:
+// when it was sent and where it belongs in the sequence. Meta carries exactly that:
+//	- [MetaType][Length = 16] [Timestamp: 8 bytes][Seq: 8 bytes]
+//	- Timestamp is sent as Unix seconds (a signed 64-bit integer), so it arrives with second precision,
+//	  in the receiver's local time zone.
+//	- Seq is sent as is.
+//	- Both are big-endian, like the length in every header.
+//	- The value must be exactly 16 bytes; anything else is ErrInvalidMeta.
+
+// MetaType is the type byte of a Meta frame.
+const MetaType uint8 = 7
+
+// metaSize is the length of a Meta value.
+const metaSize = 16
+
+// ErrInvalidMeta is returned for a Meta frame whose value isn't exactly 16 bytes.
+var ErrInvalidMeta = fmt.Errorf("invalid Meta: value must be %d bytes", metaSize)
+
+func init() {
+	Register(MetaType, func() Payload { return new(Meta) })
+}
+
+// Meta is a send timestamp and a sequence number.
+type Meta struct {
+	Timestamp time.Time
+	Seq       uint64
+}
+
+// Bytes returns the encoded 16-byte value.
+func (m Meta) Bytes() []byte {
+	value := make([]byte, 0, metaSize)
+	value = binary.BigEndian.AppendUint64(value, uint64(m.Timestamp.Unix()))
+	return binary.BigEndian.AppendUint64(value, m.Seq)
+}
+
+func (m Meta) String() string {
+	return fmt.Sprintf("seq %d at %s", m.Seq, m.Timestamp.Format(time.RFC3339))
+}
+
+func (m Meta) WriteTo(w io.Writer) (int64, error) { return writeTLV(w, MetaType, m.Bytes()) }
+
+// ReadFrom reads a Meta frame; its value must be exactly 16 bytes.
+func (m *Meta) ReadFrom(r io.Reader) (int64, error) {
+	value, n, err := readTLV(r, MetaType, "Meta")
+	if err != nil {
+		return n, err
+	}
+	if len(value) != metaSize {
+		return n, ErrInvalidMeta
+	}
+
+	m.Timestamp = time.Unix(int64(binary.BigEndian.Uint64(value[:8])), 0)
+	m.Seq = binary.BigEndian.Uint64(value[8:])
+	return n, nil
+}
@@ -0,0 +1,55 @@
+package ch04
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMetaRoundTrip(t *testing.T) {
+	sent := Meta{
+		Timestamp: time.Date(2024, 5, 17, 13, 45, 12, 987654321, time.UTC),
+		Seq:       math.MaxUint64 - 1,
+	}
+
+	var buf bytes.Buffer
+	n, err := sent.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != tlvHeaderSize+metaSize {
+		t.Fatalf("expected a %d-byte frame; actual: %d", tlvHeaderSize+metaSize, n)
+	}
+
+	p, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, ok := p.(*Meta)
+	if !ok {
+		t.Fatalf("expected *Meta; actual: %T", p)
+	}
+
+	if !received.Timestamp.Equal(sent.Timestamp.Truncate(time.Second)) {
+		t.Errorf("expected timestamp %s; actual: %s", sent.Timestamp.Truncate(time.Second), received.Timestamp)
+	}
+	if received.Seq != sent.Seq {
+		t.Errorf("expected seq %d; actual: %d", sent.Seq, received.Seq)
+	}
+}
+
+func TestMetaInvalidLength(t *testing.T) {
+	for _, size := range []int{0, metaSize - 1, metaSize + 1} {
+		var buf bytes.Buffer
+		if _, err := writeTLV(&buf, MetaType, make([]byte, size)); err != nil {
+			t.Fatal(err)
+		}
+
+		var m Meta
+		if _, err := m.ReadFrom(&buf); !errors.Is(err, ErrInvalidMeta) {
+			t.Errorf("%d-byte value: expected ErrInvalidMeta; actual: %v", size, err)
+		}
+	}
+}
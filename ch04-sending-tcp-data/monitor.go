@@ -0,0 +1,341 @@
+package ch04
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Reza-1988/network-programming-with-go/ch03-tcp-conn-go-stdlib"
+)
+
+// ## A Heartbeat Monitor That Expects Pongs
+// The Pinger from chapter 3 (Listing 3-10) sends pings, but nothing checks whether they are answered.
+// A dead peer whose kernel still accepts our writes (or a middlebox that swallows them) looks perfectly healthy.
+// Monitor adds the other half of the heartbeat:
+//	- Pinger writes a Ping frame every Interval (the monitor frames Pinger's "ping" bytes as a Ping payload).
+//	- Every Ping sent is recorded with its timestamp as "outstanding".
+//	- The read loop owns the connection's read side:
+//		- a Pong clears the oldest outstanding ping and resets the missed counter
+//		- a Ping from the peer is answered with a Pong (so two Monitors can watch each other)
+//		- any other payload is handed to Receive
+//		- any frame at all resets Pinger's timer: we just heard from the peer, no need to challenge it (Listing 3-12)
+//	- A ping still outstanding after PongTimeout counts as missed.
+//	  MaxMissed consecutive misses mean the peer is dead: OnDead is called and the connection is closed.
+//
+// Telemetry: PingBody makes every Ping carry a small status (a sequence number, the load, ...),
+// and OnPing shows the peer's Ping bodies, so liveness and status travel in one message.
+//
+// Idle connections: with IdleTimeout set, the read loop pushes the read deadline forward before every frame
+// (Listing 3-12): a connection that stays silent for IdleTimeout fails with a time-out, pings or not.
+//
+// Closing is not failing: when the connection is closed under the Monitor (by Close, by a Server shutting down, ...),
+// the read loop's next SetReadDeadline or Decode fails with "use of closed network connection".
+// That's a shutdown signal, not an error: the Monitor stops with a plain net.ErrClosed and doesn't call OnError.
+//
+// Sharing the write side: Pings and Pongs are written from the Monitor's own goroutines while the application
+// writes its frames. Without a common lock, a Ping could land between the header and the value of an application frame.
+//	- The Monitor wraps conn in a SyncConn (see sync_conn.go) before Pinger starts, and writes every Ping and Pong
+//	  with its WritePayload, one complete frame at a time.
+//	- The application writes through the same SyncConn: Send, or Conn().WritePayload.
+//	- Passing a *SyncConn to NewMonitor reuses it, so code that already writes through it needs no change.
+//
+// A slow application: Receive hands over payloads from a small buffer (monitorBacklog). Once it is full,
+// the read loop waits for the application, and while it waits, it reads nothing, Pongs included.
+// The peer is still answering; its Pongs just sit unread behind the application's frames.
+//	- While the read loop waits for the application, missed pongs aren't counted.
+//	- When it can read again, the outstanding pings start their PongTimeout over, so the Pongs in the socket
+//	  have time to be read before anyone counts them as missed.
+//	- So an application that stops calling Receive never gets a healthy peer declared dead.
+//	  It does stop the heartbeat's reading, and TCP flow control eventually slows the peer down.
+//
+// Timing: with pings every Interval, the peer is declared dead roughly MaxMissed × Interval + PongTimeout
+// after the last pong it sent.
+
+// monitorBacklog is how many payloads wait for Receive before the read loop waits for the application.
+const monitorBacklog = 16
+
+// ErrPeerDead is returned by Receive after the peer missed too many pongs.
+var ErrPeerDead = errors.New("peer missed too many pongs")
+
+// MonitorOptions configures a Monitor. Zero values use the defaults.
+type MonitorOptions struct {
+	Interval    time.Duration // time between pings; default 30s
+	PongTimeout time.Duration // how long to wait for each pong; default Interval
+	MaxMissed   int           // consecutive missed pongs before the peer is dead; default 3
+	// OnDead, if set, is called once when the peer is declared dead, before the connection is closed.
+	OnDead func(missed int)
+	// PingBody, if set, produces the body of each Ping we send; the default is "ping".
+	PingBody func() []byte
+	// OnPing, if set, is called from the read loop with the body of every Ping the peer sends.
+	OnPing func(body []byte)
+	// IdleTimeout, if set, is the longest the connection may stay silent; see above.
+	IdleTimeout time.Duration
+	// OnError, if set, is called once when the Monitor stops because of a read or write error.
+	// It isn't called when the connection was closed (see above) or for a dead peer (OnDead).
+	OnError func(err error)
+}
+
+// Monitor runs a heartbeat on a connection and detects a peer that stops answering.
+type Monitor struct {
+	conn *SyncConn // every frame, ours and the application's, is written through it
+	opts MonitorOptions
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	reset    chan time.Duration
+	incoming chan Payload
+	wg       sync.WaitGroup
+
+	mu          sync.Mutex
+	outstanding []time.Time // send times of unanswered pings, oldest first
+	missed      int
+	stalled     bool // the read loop waits for Receive: missed pongs aren't counted
+	err         error
+}
+
+// NewMonitor starts pinging conn and reading from it.
+//   - The Monitor owns conn's read side; use Receive for the payloads that aren't part of the heartbeat.
+//   - Don't write to conn directly afterwards: use Send or Conn, so frames never interleave.
+func NewMonitor(conn net.Conn, opts MonitorOptions) *Monitor {
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+	if opts.PongTimeout <= 0 {
+		opts.PongTimeout = opts.Interval
+	}
+	if opts.MaxMissed <= 0 {
+		opts.MaxMissed = 3
+	}
+
+	// Wrap the connection before Pinger starts: its first Ping must already go through the lock.
+	sc, ok := conn.(*SyncConn)
+	if !ok {
+		sc = NewSyncConn(conn)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Monitor{
+		conn:     sc,
+		opts:     opts,
+		ctx:      ctx,
+		cancel:   cancel,
+		reset:    make(chan time.Duration, 1),
+		incoming: make(chan Payload, monitorBacklog),
+	}
+
+	m.reset <- opts.Interval
+	m.wg.Add(2)
+	go func() {
+		defer m.wg.Done()
+		var pingerOpts []ch03.PingerOption
+		if opts.PingBody != nil {
+			pingerOpts = append(pingerOpts, ch03.WithBody(opts.PingBody))
+		}
+		ch03.Pinger(ctx, pingWriter{m}, m.reset, pingerOpts...)
+	}()
+	go func() {
+		defer m.wg.Done()
+		m.readLoop()
+	}()
+
+	return m
+}
+
+// Receive returns the next payload that isn't a Ping or Pong.
+//   - After the peer is declared dead it returns ErrPeerDead.
+func (m *Monitor) Receive(ctx context.Context) (Payload, error) {
+	select {
+	case p, ok := <-m.incoming:
+		if !ok {
+			return nil, m.Err()
+		}
+		return p, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Send writes p to the connection.
+//   - Use Send instead of writing to the connection directly, so p can't interleave with a Ping or Pong frame.
+func (m *Monitor) Send(p Payload) error {
+	_, err := m.conn.WritePayload(p)
+	return err
+}
+
+// Conn returns the serialized connection the Monitor writes to.
+//   - Its writes never interleave with the Monitor's Pings and Pongs. Don't read from it: the Monitor does.
+func (m *Monitor) Conn() *SyncConn { return m.conn }
+
+// Err returns why the Monitor stopped, or nil while it is running.
+func (m *Monitor) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// Close stops the heartbeat and closes the connection.
+func (m *Monitor) Close() error {
+	m.stop(net.ErrClosed)
+	err := m.conn.Close()
+	m.wg.Wait()
+
+	return err
+}
+
+// stop records the first reason for stopping and cancels the heartbeat.
+//   - Any "use of closed network connection" error is recorded as net.ErrClosed, a shutdown and not a failure.
+func (m *Monitor) stop(err error) {
+	if errors.Is(err, net.ErrClosed) {
+		err = net.ErrClosed
+	}
+
+	m.mu.Lock()
+	first := m.err == nil
+	if first {
+		m.err = err
+	}
+	m.mu.Unlock()
+	m.cancel()
+
+	if first && err != net.ErrClosed && m.opts.OnError != nil {
+		m.opts.OnError(err)
+	}
+}
+
+// pingWriter turns every write from Pinger into a Ping frame.
+type pingWriter struct{ m *Monitor }
+
+func (w pingWriter) Write(p []byte) (int, error) {
+	m := w.m
+
+	// Record the ping before writing it: the pong may be read before Write returns.
+	m.mu.Lock()
+	m.outstanding = append(m.outstanding, time.Now())
+	m.mu.Unlock()
+	time.AfterFunc(m.opts.PongTimeout, m.checkPongs)
+
+	ping := Ping(p)
+	if err := m.Send(&ping); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// checkPongs counts the pings that outlived PongTimeout and declares the peer dead after MaxMissed in a row.
+func (m *Monitor) checkPongs() {
+	m.mu.Lock()
+	if m.err != nil || m.stalled {
+		m.mu.Unlock()
+		return
+	}
+
+	cutoff := time.Now().Add(-m.opts.PongTimeout)
+	for len(m.outstanding) > 0 && !m.outstanding[0].After(cutoff) {
+		m.outstanding = m.outstanding[1:]
+		m.missed++
+	}
+	missed := m.missed
+	dead := missed >= m.opts.MaxMissed
+	if dead {
+		m.err = ErrPeerDead
+	}
+	m.mu.Unlock()
+
+	if !dead {
+		return
+	}
+	if m.opts.OnDead != nil {
+		m.opts.OnDead(missed)
+	}
+	m.cancel()
+	_ = m.conn.Close()
+}
+
+// readLoop handles heartbeat frames and forwards everything else to Receive.
+func (m *Monitor) readLoop() {
+	defer close(m.incoming)
+
+	for {
+		if m.opts.IdleTimeout > 0 {
+			// Fails with net.ErrClosed if the connection was closed meanwhile; stop treats that as a shutdown.
+			if err := m.conn.SetReadDeadline(time.Now().Add(m.opts.IdleTimeout)); err != nil {
+				m.stop(err)
+				return
+			}
+		}
+
+		p, err := Decode(m.conn)
+		if err != nil {
+			m.stop(err)
+			return
+		}
+
+		// We heard from the peer: postpone the next ping.
+		select {
+		case m.reset <- 0:
+		default:
+		}
+
+		switch p := p.(type) {
+		case *Pong:
+			m.mu.Lock()
+			if len(m.outstanding) > 0 {
+				m.outstanding = m.outstanding[1:]
+			}
+			m.missed = 0
+			m.mu.Unlock()
+		case *Ping:
+			if m.opts.OnPing != nil {
+				m.opts.OnPing(*p)
+			}
+			pong := Pong(*p)
+			if err = m.Send(&pong); err != nil {
+				m.stop(err)
+				return
+			}
+		default:
+			if !m.deliver(p) {
+				return
+			}
+		}
+	}
+}
+
+// deliver hands p to Receive, waiting for the application if the backlog is full.
+//   - It returns false if the Monitor stopped meanwhile.
+func (m *Monitor) deliver(p Payload) bool {
+	select {
+	case m.incoming <- p:
+		return true
+	default:
+	}
+
+	m.setStalled(true)
+	defer m.setStalled(false)
+
+	select {
+	case m.incoming <- p:
+		return true
+	case <-m.ctx.Done():
+		return false
+	}
+}
+
+// setStalled marks the wait for the application. At its end, the outstanding pings start their PongTimeout over.
+func (m *Monitor) setStalled(stalled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stalled = stalled
+	if stalled || len(m.outstanding) == 0 {
+		return
+	}
+	now := time.Now()
+	for i := range m.outstanding {
+		m.outstanding[i] = now
+	}
+	time.AfterFunc(m.opts.PongTimeout, m.checkPongs)
+}
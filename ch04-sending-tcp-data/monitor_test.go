@@ -0,0 +1,341 @@
+package ch04
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// The peer answers the first two pings and then goes quiet while still reading.
+// The monitor must call OnDead after MaxMissed missed pongs and close the connection.
+func TestMonitorMissedPongs(t *testing.T) {
+	local, peer := net.Pipe()
+	defer peer.Close()
+
+	const interval = 50 * time.Millisecond
+	var lastPong atomic.Int64
+	peerDone := make(chan struct{})
+	go func() {
+		defer close(peerDone)
+		for pings := 1; ; pings++ {
+			p, err := Decode(peer)
+			if err != nil {
+				return // the monitor closed the connection
+			}
+			if _, ok := p.(*Ping); ok && pings <= 2 {
+				pong := Pong(p.Bytes())
+				if _, err = pong.WriteTo(peer); err != nil {
+					return
+				}
+				lastPong.Store(time.Now().UnixNano())
+			}
+		}
+	}()
+
+	dead := make(chan int, 1)
+	m := NewMonitor(local, MonitorOptions{
+		Interval:    interval,
+		PongTimeout: 40 * time.Millisecond,
+		MaxMissed:   3,
+		OnDead:      func(missed int) { dead <- missed },
+	})
+	defer m.Close()
+
+	var missed int
+	select {
+	case missed = <-dead:
+	case <-time.After(2 * time.Second):
+		t.Fatal("peer was never declared dead")
+	}
+	if missed != 3 {
+		t.Errorf("expected 3 missed pongs; actual: %d", missed)
+	}
+
+	// Dead after about 3 intervals + the pong timeout since the last pong.
+	elapsed := time.Since(time.Unix(0, lastPong.Load()))
+	if elapsed < 3*interval || elapsed > 3*interval+500*time.Millisecond {
+		t.Errorf("declared dead %s after the last pong", elapsed)
+	}
+
+	select {
+	case <-peerDone:
+	case <-time.After(time.Second):
+		t.Fatal("connection was not closed")
+	}
+
+	if _, err := m.Receive(context.Background()); !errors.Is(err, ErrPeerDead) {
+		t.Errorf("expected ErrPeerDead from Receive; actual: %v", err)
+	}
+}
+
+// Two monitors answer each other's pings; application payloads still reach Receive.
+//   - This needs a real TCP connection: over net.Pipe (no buffering) each side's read loop
+//     would wait to write its Pong while the other side is blocked writing a Ping.
+func TestMonitorPair(t *testing.T) {
+	a, b := tcpPair(t)
+
+	var deaths atomic.Int32
+	opts := MonitorOptions{
+		Interval:    20 * time.Millisecond,
+		PongTimeout: 50 * time.Millisecond,
+		MaxMissed:   2,
+		OnDead:      func(int) { deaths.Add(1) },
+	}
+	ma := NewMonitor(a, opts)
+	defer ma.Close()
+	mb := NewMonitor(b, opts)
+	defer mb.Close()
+
+	time.Sleep(200 * time.Millisecond) // ~10 ping/pong rounds
+
+	s := String("still here")
+	go func() { _ = ma.Send(&s) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	p, err := mb.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.String() != s.String() {
+		t.Errorf("expected %q; actual: %q", s, p)
+	}
+	if n := deaths.Load(); n != 0 {
+		t.Errorf("expected no dead peers; actual: %d", n)
+	}
+}
+
+func TestMonitorPingBody(t *testing.T) {
+	a, b := tcpPair(t)
+
+	var seq uint64 // only used by a's Pinger goroutine
+	ma := NewMonitor(a, MonitorOptions{
+		Interval: 20 * time.Millisecond,
+		PingBody: func() []byte {
+			seq++
+			return binary.BigEndian.AppendUint64(nil, seq)
+		},
+	})
+	defer ma.Close()
+
+	bodies := make(chan []byte, 16)
+	mb := NewMonitor(b, MonitorOptions{
+		Interval: time.Minute, // only a pings
+		OnPing: func(body []byte) {
+			select {
+			case bodies <- body:
+			default:
+			}
+		},
+	})
+	defer mb.Close()
+
+	var last uint64
+	for i := 0; i < 5; i++ {
+		select {
+		case body := <-bodies:
+			n := binary.BigEndian.Uint64(body)
+			if n <= last {
+				t.Fatalf("expected increasing sequence numbers; got %d after %d", n, last)
+			}
+			last = n
+		case <-time.After(time.Second):
+			t.Fatalf("ping %d didn't arrive", i+1)
+		}
+	}
+}
+
+// Closing the connection while the read loop pushes its deadline is a shutdown, not an error.
+func TestMonitorClosedConnIsBenign(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		a, b := tcpPair(t)
+
+		var failures atomic.Int32
+		opts := MonitorOptions{
+			Interval:    time.Millisecond,
+			IdleTimeout: time.Second,
+			OnError:     func(error) { failures.Add(1) },
+		}
+		ma := NewMonitor(a, opts)
+		mb := NewMonitor(b, MonitorOptions{Interval: time.Millisecond})
+
+		// Frames flow in both directions, so ma's read loop keeps pushing its deadline; close the conn in the middle of that.
+		time.Sleep(time.Duration(i%5) * time.Millisecond)
+		_ = a.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		if _, err := ma.Receive(ctx); !errors.Is(err, net.ErrClosed) {
+			t.Errorf("run %d: expected net.ErrClosed from Receive; actual: %v", i, err)
+		}
+		cancel()
+
+		if err := ma.Err(); err != net.ErrClosed {
+			t.Errorf("run %d: expected exactly net.ErrClosed; actual: %#v", i, err)
+		}
+		if n := failures.Load(); n != 0 {
+			t.Errorf("run %d: OnError called %d times for a closed connection", i, n)
+		}
+
+		_ = ma.Close()
+		_ = mb.Close()
+	}
+}
+
+func TestMonitorOnError(t *testing.T) {
+	a, _ := tcpPair(t) // the peer stays silent
+
+	reported := make(chan error, 1)
+	ma := NewMonitor(a, MonitorOptions{
+		Interval:    time.Minute,
+		IdleTimeout: 50 * time.Millisecond,
+		OnError:     func(err error) { reported <- err },
+	})
+	defer ma.Close()
+
+	select {
+	case err := <-reported:
+		if !isTimeout(err) {
+			t.Errorf("expected the idle time-out; actual: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnError wasn't called for an idle connection")
+	}
+}
+
+// Heavy application writes and pings every millisecond share one connection.
+//   - The application writes through the Monitor's SyncConn from several goroutines, the Pinger writes Pings.
+//   - The peer must decode every frame intact: no Ping ever lands inside an application frame.
+func TestMonitorPingsDontInterleave(t *testing.T) {
+	local, peer := tcpPair(t)
+
+	const (
+		writers   = 4
+		perWriter = 50
+		size      = 64 << 10
+	)
+
+	type result struct {
+		frames, pings int
+		err           error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		for r.frames < writers*perWriter {
+			p, err := Decode(peer)
+			if err != nil {
+				r.err = err
+				break
+			}
+			switch p := p.(type) {
+			case *Ping:
+				r.pings++
+				pong := Pong(*p)
+				if _, err = pong.WriteTo(peer); err != nil {
+					r.err = err
+				}
+			case *Binary:
+				if len(*p) != size || bytes.Count(*p, (*p)[:1]) != size {
+					r.err = errors.New("corrupted application frame")
+				}
+				r.frames++
+			default:
+				r.err = errors.New("unexpected payload " + p.String())
+			}
+			if r.err != nil {
+				break
+			}
+		}
+		done <- r
+	}()
+
+	m := NewMonitor(local, MonitorOptions{Interval: time.Millisecond, PongTimeout: time.Second, MaxMissed: 1000})
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Go(func() {
+			for i := range perWriter {
+				frame := Binary(bytes.Repeat([]byte{byte(w*perWriter + i)}, size))
+				if _, err := m.Conn().WritePayload(&frame); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			t.Fatalf("after %d frames and %d pings: %v", r.frames, r.pings, r.err)
+		}
+		if r.pings == 0 {
+			t.Error("expected pings between the application frames")
+		}
+		t.Logf("%d application frames and %d pings decoded intact", r.frames, r.pings)
+	case <-time.After(10 * time.Second):
+		t.Fatal("peer did not receive every frame")
+	}
+}
+
+// An application that doesn't call Receive for a while must not get a healthy peer declared dead,
+// even though the Pongs wait behind its frames.
+func TestMonitorSlowReceiver(t *testing.T) {
+	local, remote := tcpPair(t)
+
+	// The peer answers pings (its own heartbeat is too slow to matter) and sends more frames than the backlog holds.
+	peer := NewMonitor(remote, MonitorOptions{Interval: time.Minute})
+	defer peer.Close()
+	const frames = 2 * monitorBacklog
+	for i := range frames {
+		if err := peer.Send(ptr(Binary{byte(i)})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dead := make(chan int, 1)
+	m := NewMonitor(local, MonitorOptions{
+		Interval:    20 * time.Millisecond,
+		PongTimeout: 20 * time.Millisecond,
+		MaxMissed:   2,
+		OnDead:      func(missed int) { dead <- missed },
+	})
+	defer m.Close()
+
+	// Many pong timeouts pass while nobody calls Receive.
+	select {
+	case missed := <-dead:
+		t.Fatalf("a healthy peer was declared dead after %d missed pongs", missed)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := range frames {
+		p, err := m.Receive(ctx)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if p.Bytes()[0] != byte(i) {
+			t.Fatalf("frame %d: unexpected value %v", i, p.Bytes())
+		}
+	}
+
+	// The heartbeat carries on after the application caught up.
+	select {
+	case missed := <-dead:
+		t.Fatalf("a healthy peer was declared dead after %d missed pongs", missed)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if err := m.Err(); err != nil {
+		t.Fatalf("expected the Monitor to be running; actual: %v", err)
+	}
+}
@@ -0,0 +1,411 @@
+package ch04
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// ## Multiplexing Streams over One Connection
+// A Mux carries several independent byte streams over a single `net.Conn`.
+//	- Each chunk of stream data travels in its own mux frame:
+//		- [Kind:1 byte][Stream ID:4 bytes][Length:4 bytes][Data:Length bytes]
+//	- The receiving Mux looks at the stream ID and appends the data to that stream's buffer.
+//	- A frame for a stream ID the receiver has never seen creates the stream and hands it to Accept.
+//
+// ### Fair scheduling
+// If the writer sent streams in arrival order, one Write of 100MB would occupy the connection until it finished,
+// and a tiny control message on another stream would wait behind it.
+//	- The Mux therefore never writes a Write call in one go. A single writer goroutine acts as a scheduler:
+//		1. take the first stream from the ready queue (streams with pending data)
+//		2. send at most maxChunk bytes of its data in one frame
+//		3. if the stream still has data, put it at the BACK of the queue (round-robin)
+//	- A small message waits for at most one chunk of every other busy stream, no matter how large their writes are.
+//	- Stream.Write blocks until all of its data has been sent, so the Mux never copies (or buffers) the caller's data.
+//	- Even when the Mux stops, Write returns only after the chunk being written (if any) is done with its bytes:
+//	  io.Writer forbids keeping p after Write returns. A Mux that stops closes the connection, which ends that write.
+//
+// ### Flow control
+// The reading side is different: readLoop buffers whatever arrives until the application reads it.
+// A fast sender and a stream nobody reads would grow that buffer without limit. Credits prevent it:
+//	- Every stream starts with a receive window (window bytes). The sender keeps the same number as its credit.
+//	- Each data frame costs credit. A stream without credit is left out of the ready queue, so its Write blocks
+//	  while the other streams keep going.
+//	- As the application reads, the receiver gives the credit back in a WindowUpdate frame:
+//		- [muxWindowUpdate][Stream ID][Length: 4][Increment: 4 bytes]
+//		- it waits until half the window was read, so there is one update per half window rather than one per Read
+//	- A stream never has more than window bytes buffered on the receiving side.
+//	  A peer sending beyond its credit breaks the protocol, and the Mux stops with ErrFlowControl.
+//	- Both peers must use the same window: the sender's initial credit IS the receiver's window.
+
+const (
+	muxData         uint8 = iota // a chunk of stream data
+	muxWindowUpdate              // credit given back to the sender
+
+	muxHeaderSize = 9 // 1-byte kind + 4-byte stream ID + 4-byte length
+
+	defaultMaxChunk = 16 << 10 // 16KB
+	defaultWindow   = 1 << 20  // 1MB
+)
+
+var (
+	// ErrMuxClosed is returned by streams after their Mux was closed.
+	ErrMuxClosed = errors.New("mux closed")
+	// ErrFlowControl stops a Mux whose peer sent more data than the stream's window allows.
+	ErrFlowControl = errors.New("mux peer exceeded the stream window")
+)
+
+// Mux multiplexes streams over a connection.
+type Mux struct {
+	conn     net.Conn
+	maxChunk int
+	window   int // every stream's receive window, and its initial send credit
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	streams map[uint32]*Stream
+	ready   []*Stream // streams with pending data and credit, in round-robin order
+	updates []*Stream // streams with a window update to send; sent before any data
+	accept  []*Stream // streams opened by the peer, waiting for Accept
+	err     error     // set once the mux stops; returned by every blocked call
+
+	wg sync.WaitGroup
+}
+
+// NewMux starts multiplexing over conn. Every frame carries at most maxChunk bytes of data.
+//   - maxChunk <= 0 uses 16KB.
+//   - Every stream has a 1MB window (see NewMuxWindow).
+func NewMux(conn net.Conn, maxChunk int) *Mux {
+	return NewMuxWindow(conn, maxChunk, defaultWindow)
+}
+
+// NewMuxWindow is NewMux with a receive window of window bytes per stream.
+//   - The peer's Mux must use the same window. window <= 0 uses 1MB.
+func NewMuxWindow(conn net.Conn, maxChunk, window int) *Mux {
+	if maxChunk <= 0 {
+		maxChunk = defaultMaxChunk
+	}
+	if window <= 0 {
+		window = defaultWindow
+	}
+
+	m := &Mux{
+		conn:     conn,
+		maxChunk: maxChunk,
+		window:   window,
+		streams:  make(map[uint32]*Stream),
+	}
+	m.cond = sync.NewCond(&m.mu)
+
+	m.wg.Add(2)
+	go m.writeLoop()
+	go m.readLoop()
+
+	return m
+}
+
+// Open returns the stream with the given ID, creating it if necessary.
+//   - Both peers must agree on IDs; a simple rule is odd IDs for the client and even IDs for the server.
+func (m *Mux) Open(id uint32) *Stream {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.stream(id)
+}
+
+// stream returns the stream for id, creating it if needed. m.mu must be held.
+func (m *Mux) stream(id uint32) *Stream {
+	s, ok := m.streams[id]
+	if !ok {
+		s = &Stream{id: id, m: m, credit: m.window, recvWindow: m.window}
+		m.streams[id] = s
+	}
+
+	return s
+}
+
+// Accept waits for the next stream opened by the peer.
+func (m *Mux) Accept() (*Stream, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for len(m.accept) == 0 && m.err == nil {
+		m.cond.Wait()
+	}
+	if len(m.accept) == 0 {
+		return nil, m.err
+	}
+
+	s := m.accept[0]
+	m.accept = m.accept[1:]
+	return s, nil
+}
+
+// Close closes the connection and unblocks every pending Read, Write and Accept.
+func (m *Mux) Close() error {
+	err := m.conn.Close()
+	m.stop(ErrMuxClosed)
+	m.wg.Wait()
+
+	return err
+}
+
+// stop records the first error, wakes everybody up and closes the connection.
+//   - Closing unblocks a chunk still being written, so the Write waiting for it can return.
+func (m *Mux) stop(err error) {
+	m.mu.Lock()
+	first := m.err == nil
+	if first {
+		m.err = err
+	}
+	m.cond.Broadcast()
+	m.mu.Unlock()
+
+	if first {
+		_ = m.conn.Close()
+	}
+}
+
+// schedule puts s at the back of the ready queue if it has data to send, credit to send it, and isn't queued yet.
+// m.mu must be held.
+func (m *Mux) schedule(s *Stream) {
+	if s.queued || len(s.out) == 0 || s.credit == 0 {
+		return
+	}
+
+	s.queued = true
+	m.ready = append(m.ready, s)
+	m.cond.Broadcast()
+}
+
+// writeLoop is the scheduler: window updates first, then one chunk per ready stream, round-robin.
+func (m *Mux) writeLoop() {
+	defer m.wg.Done()
+
+	header := make([]byte, muxHeaderSize)
+	for {
+		m.mu.Lock()
+		for len(m.ready) == 0 && len(m.updates) == 0 && m.err == nil {
+			m.cond.Wait()
+		}
+		if m.err != nil {
+			m.mu.Unlock()
+			return
+		}
+
+		// 0) Window updates are tiny and unblock the peer's writers: they jump the queue.
+		if len(m.updates) > 0 {
+			s := m.updates[0]
+			m.updates = m.updates[1:]
+			increment := s.update
+			s.update, s.updateQueued = 0, false
+			m.mu.Unlock()
+
+			header[0] = muxWindowUpdate
+			binary.BigEndian.PutUint32(header[1:5], s.id)
+			binary.BigEndian.PutUint32(header[5:9], 4)
+			if _, err := m.conn.Write(binary.BigEndian.AppendUint32(header, uint32(increment))); err != nil {
+				m.stop(err)
+				return
+			}
+			continue
+		}
+
+		// 1) Take the first ready stream and cut one chunk from its pending data, within its credit.
+		//    s.out only moves past the chunk once it was written, so Write never counts a chunk that failed.
+		s := m.ready[0]
+		m.ready = m.ready[1:]
+		s.queued = false
+		chunk := s.out[:min(len(s.out), m.maxChunk, s.credit)]
+		if len(chunk) == 0 {
+			m.mu.Unlock()
+			continue // queued again while its last chunk was being written: nothing left
+		}
+		s.credit -= len(chunk)
+		s.sending = true // Write waits for it: chunk points into the caller's p
+		m.mu.Unlock()
+
+		// 2) Send the chunk. Only this goroutine writes to conn, so no lock is needed.
+		header[0] = muxData
+		binary.BigEndian.PutUint32(header[1:5], s.id)
+		binary.BigEndian.PutUint32(header[5:9], uint32(len(chunk)))
+		_, err := (&net.Buffers{header, chunk}).WriteTo(m.conn)
+		m.mu.Lock()
+		s.sending = false
+		if err != nil {
+			m.mu.Unlock()
+			m.stop(err)
+			return
+		}
+
+		// 3) Round-robin: a stream with data (and credit) left goes to the back of the queue.
+		//    Without credit it waits for a window update instead.
+		//    s.out still starts with chunk: Write doesn't touch it while a chunk is being sent.
+		s.out = s.out[len(chunk):]
+		m.schedule(s)
+		m.cond.Broadcast() // wakes the Write waiting for its data to drain
+		m.mu.Unlock()
+	}
+}
+
+// readLoop delivers incoming chunks to their streams.
+func (m *Mux) readLoop() {
+	defer m.wg.Done()
+
+	header := make([]byte, muxHeaderSize)
+	for {
+		_, err := io.ReadFull(m.conn, header)
+		if err != nil {
+			m.stop(err)
+			return
+		}
+
+		id := binary.BigEndian.Uint32(header[1:5])
+		size := binary.BigEndian.Uint32(header[5:9])
+		if header[0] == muxWindowUpdate {
+			if err := m.readWindowUpdate(id, size); err != nil {
+				m.stop(err)
+				return
+			}
+			continue
+		}
+		if header[0] != muxData {
+			m.stop(errors.New("unknown mux frame"))
+			return
+		}
+		if size > MaxPayloadSize {
+			m.stop(ErrMaxPayloadSize)
+			return
+		}
+
+		data := make([]byte, size)
+		if _, err = io.ReadFull(m.conn, data); err != nil {
+			m.stop(err)
+			return
+		}
+
+		m.mu.Lock()
+		s, ok := m.streams[id]
+		if !ok {
+			s = m.stream(id)
+			m.accept = append(m.accept, s)
+		}
+		if int(size) > s.recvWindow {
+			m.mu.Unlock()
+			m.stop(ErrFlowControl)
+			return
+		}
+		s.recvWindow -= int(size)
+		s.in = append(s.in, data...)
+		m.cond.Broadcast()
+		m.mu.Unlock()
+	}
+}
+
+// readWindowUpdate reads the increment of a window update for stream id and adds it to the stream's credit.
+func (m *Mux) readWindowUpdate(id, size uint32) error {
+	if size != 4 {
+		return errors.New("invalid mux window update")
+	}
+	var value [4]byte
+	if _, err := io.ReadFull(m.conn, value[:]); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.stream(id)
+	s.credit += int(binary.BigEndian.Uint32(value[:]))
+	if s.credit > m.window {
+		return ErrFlowControl // more credit back than was ever spent
+	}
+	m.schedule(s)
+	return nil
+}
+
+// Stream is one logical byte stream inside a Mux.
+//   - Read and Write may be used from different goroutines; concurrent Writes are serialized.
+type Stream struct {
+	id uint32
+	m  *Mux
+
+	writeMu sync.Mutex // one Write at a time per stream
+
+	// guarded by m.mu
+	out          []byte // data of the current Write not yet sent
+	sending      bool   // the first chunk of out is being written
+	queued       bool   // s is in m.ready
+	credit       int    // bytes we may still send before the peer's next window update
+	in           []byte // received data not yet read
+	recvWindow   int    // bytes the peer may still send before our next window update
+	update       int    // bytes read but not given back yet
+	updateQueued bool   // s is in m.updates (once update reaches half the window)
+}
+
+// ID returns the stream's ID.
+func (s *Stream) ID() uint32 { return s.id }
+
+// Write queues p for the scheduler and blocks until all of it has been sent.
+func (s *Stream) Write(p []byte) (int, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	m := s.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return 0, m.err
+	}
+
+	s.out = p
+	m.schedule(s)
+
+	for s.sending || (len(s.out) > 0 && m.err == nil) {
+		m.cond.Wait()
+	}
+
+	n := len(p) - len(s.out)
+	if len(s.out) > 0 {
+		s.out = nil
+		return n, m.err
+	}
+
+	return n, nil
+}
+
+// Read reads data received on this stream.
+//   - After the Mux stops, buffered data is still returned first; then Read returns the Mux's error.
+func (s *Stream) Read(p []byte) (int, error) {
+	m := s.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for len(s.in) == 0 && m.err == nil {
+		m.cond.Wait()
+	}
+	if len(s.in) == 0 {
+		return 0, m.err
+	}
+
+	n := copy(p, s.in)
+	s.in = s.in[n:]
+
+	// Give the read bytes back to the sender, in batches of half a window.
+	s.update += n
+	if !s.updateQueued && s.update >= m.window/2 {
+		s.updateQueued = true
+		m.updates = append(m.updates, s)
+		m.cond.Broadcast()
+	}
+	s.recvWindow += n
+
+	return n, nil
+}
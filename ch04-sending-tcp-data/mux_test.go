@@ -0,0 +1,253 @@
+package ch04
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// muxPair returns two Muxes connected over a real TCP connection.
+//   - window <= 0 uses the default window.
+func muxPair(t *testing.T, maxChunk, window int) (*Mux, *Mux) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := NewMuxWindow(client, maxChunk, window), NewMuxWindow(server, maxChunk, window)
+	t.Cleanup(func() {
+		_ = a.Close()
+		_ = b.Close()
+	})
+
+	return a, b
+}
+
+// This test sends one huge write on stream 1 and, while it is in progress, a tiny message on stream 2.
+//   - With round-robin scheduling the tiny message only waits for a chunk, not for the whole huge write.
+//   - So when the receiver gets the tiny message, most of the huge stream must still be on its way.
+func TestMuxFairness(t *testing.T) {
+	const hugeSize = 64 << 20 // 64MB
+	sender, receiver := muxPair(t, 16<<10, 0)
+
+	huge := bytes.Repeat([]byte("h"), hugeSize)
+	hugeDone := make(chan error, 1)
+	go func() {
+		_, err := sender.Open(1).Write(huge)
+		hugeDone <- err
+	}()
+
+	// The receiver drains stream 1 and counts the bytes so far.
+	s1, err := receiver.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s1.ID() != 1 {
+		t.Fatalf("expected stream 1; actual: %d", s1.ID())
+	}
+
+	var hugeReceived atomic.Int64
+	firstChunk := make(chan struct{})
+	hugeRead := make(chan struct{})
+	go func() {
+		defer close(hugeRead)
+		buf := make([]byte, 32<<10)
+		for hugeReceived.Load() < hugeSize {
+			n, err := s1.Read(buf)
+			if hugeReceived.Add(int64(n)) == int64(n) && n > 0 {
+				close(firstChunk)
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	<-firstChunk // the huge write is in progress
+
+	// The tiny control message.
+	go func() {
+		if _, err := sender.Open(2).Write([]byte("stop")); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	s2, err := receiver.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := make([]byte, 4)
+	if _, err = io.ReadFull(s2, msg); err != nil {
+		t.Fatal(err)
+	}
+	atTiny := hugeReceived.Load()
+
+	if string(msg) != "stop" {
+		t.Errorf("expected %q; actual: %q", "stop", msg)
+	}
+	if atTiny >= hugeSize/2 {
+		t.Errorf("tiny message waited for the huge one: %d of %d bytes already received", atTiny, hugeSize)
+	}
+	t.Logf("tiny message arrived after %d of %d bytes of the huge stream", atTiny, hugeSize)
+
+	if err := <-hugeDone; err != nil {
+		t.Fatal(err)
+	}
+	<-hugeRead
+	if actual := hugeReceived.Load(); actual != hugeSize {
+		t.Errorf("expected %d bytes; actual: %d", hugeSize, actual)
+	}
+}
+
+// Closing a Mux unblocks streams waiting in Read.
+func TestMuxClose(t *testing.T) {
+	a, _ := muxPair(t, 0, 0)
+	s := a.Open(1)
+
+	done := make(chan error)
+	go func() {
+		_, err := s.Read(make([]byte, 1))
+		done <- err
+	}()
+
+	_ = a.Close()
+	if err := <-done; err == nil {
+		t.Error("expected an error after Close")
+	}
+}
+
+// A receiver that doesn't read must not make the Mux buffer everything the sender writes.
+//   - The sender's Write blocks once the stream's window is used up, with at most window bytes on the receiving side.
+//   - Other streams are not affected.
+//   - Reading gives credit back (WindowUpdate frames), and the Write completes.
+func TestMuxFlowControl(t *testing.T) {
+	const (
+		window = 64 << 10
+		size   = 1 << 20
+	)
+	sender, receiver := muxPair(t, 16<<10, window)
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), size/16)
+	written := make(chan error, 1)
+	go func() {
+		_, err := sender.Open(1).Write(data)
+		written <- err
+	}()
+
+	slow, err := receiver.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffered := func() int {
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		return len(slow.in)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for buffered() < window && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The window is full: nothing more arrives, and the Write is still blocked.
+	time.Sleep(100 * time.Millisecond)
+	if n := buffered(); n != window {
+		t.Fatalf("expected exactly %d buffered bytes; actual: %d", window, n)
+	}
+	select {
+	case err := <-written:
+		t.Fatalf("Write returned before the receiver read anything: %v", err)
+	default:
+	}
+
+	// Another stream still gets through.
+	if _, err := sender.Open(3).Write([]byte("other")); err != nil {
+		t.Fatal(err)
+	}
+	other, err := receiver.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := make([]byte, 5)
+	if _, err := io.ReadFull(other, msg); err != nil || string(msg) != "other" {
+		t.Fatalf("expected %q on stream 3; actual: %q, %v", "other", msg, err)
+	}
+
+	// Reading sends the credit back, and the rest follows.
+	received := make([]byte, size)
+	if _, err := io.ReadFull(slow, received); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(received, data) {
+		t.Error("received data differs from the data written")
+	}
+}
+
+// When the connection fails in the middle of a Write, n counts only the chunks that were written.
+func TestMuxWriteCountsWrittenChunks(t *testing.T) {
+	client, server := tcpPair(t)
+	go func() { _, _ = io.Copy(io.Discard, server) }()
+
+	// Room for the first chunk's frame and a few bytes of the second one.
+	const chunk = 4
+	conn := NewBudgetConn(client, Budget{Write: muxHeaderSize + chunk + 5})
+	m := NewMux(conn, chunk)
+	defer m.Close()
+
+	n, err := m.Open(1).Write([]byte("0123456789ab"))
+	if err == nil {
+		t.Fatal("expected the Write to fail")
+	}
+	if n != chunk {
+		t.Errorf("expected %d bytes written; actual: %d", chunk, n)
+	}
+}
+
+// When the read loop stops the Mux while a chunk is being written, Write returns only after that write
+// has ended, and the write loop doesn't touch the stream's data afterwards.
+func TestMuxStopDuringChunkWrite(t *testing.T) {
+	client, peer := net.Pipe()
+	defer peer.Close()
+
+	m := NewMux(client, 1024)
+	defer m.Close()
+
+	written := make(chan error, 1)
+	go func() {
+		// Nobody reads from peer yet, so the first chunk's write blocks.
+		_, err := m.Open(1).Write(make([]byte, 4096))
+		written <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// An unknown frame kind stops the Mux from the read loop.
+	if _, err := peer.Write([]byte{7, 0, 0, 0, 1, 0, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-written:
+		if err == nil {
+			t.Fatal("expected the Write to fail")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Write didn't return after the Mux stopped")
+	}
+}
@@ -0,0 +1,97 @@
+package ch04
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ## Agreeing on the Largest Frame
+// MaxPayloadSize is a per-process setting. When two peers are configured differently (10MB here, 2MB there),
+// the one with the larger limit happily sends frames the other one rejects, and the connection breaks mid-stream.
+// A short handshake right after connecting avoids that:
+//	1. Both peers send a Limits frame with their own maximum value size:
+//		- [LimitsType][Length = 4] [MaxSize: 4 bytes, big-endian]
+//	2. Both read the peer's Limits frame.
+//	3. Both take the smaller of the two: that is the effective limit, and it is the same number on both ends.
+//	4. Apply it with Decoder.SetMaxSize and BufferedEncoder.SetMaxSize:
+//		- the encoder refuses a larger frame before sending it, so the peer never sees it
+//		- the decoder refuses a larger frame from a peer that ignores the agreement
+// Both peers write first and read second. NegotiateMaxSize writes from its own goroutine,
+// so it works over unbuffered connections (net.Pipe) too.
+
+// LimitsType is the type byte of a Limits frame.
+const LimitsType uint8 = 10
+
+// limitsSize is the length of a Limits value.
+const limitsSize = 4
+
+// ErrInvalidLimits is returned for a Limits frame whose value isn't exactly 4 bytes, or announces a zero size.
+var ErrInvalidLimits = errors.New("invalid Limits")
+
+func init() {
+	Register(LimitsType, func() Payload { return new(Limits) })
+}
+
+// Limits announces the largest value size a peer accepts.
+type Limits struct {
+	MaxSize uint32
+}
+
+// Bytes returns the encoded 4-byte value.
+func (m Limits) Bytes() []byte { return binary.BigEndian.AppendUint32(nil, m.MaxSize) }
+
+func (m Limits) String() string { return fmt.Sprintf("max size %d", m.MaxSize) }
+
+func (m Limits) WriteTo(w io.Writer) (int64, error) { return writeTLV(w, LimitsType, m.Bytes()) }
+
+// ReadFrom reads a Limits frame.
+func (m *Limits) ReadFrom(r io.Reader) (int64, error) {
+	value, n, err := readTLV(r, LimitsType, "Limits")
+	if err != nil {
+		return n, err
+	}
+	if len(value) != limitsSize {
+		return n, ErrInvalidLimits
+	}
+
+	m.MaxSize = binary.BigEndian.Uint32(value)
+	if m.MaxSize == 0 {
+		return n, ErrInvalidLimits
+	}
+	return n, nil
+}
+
+// NegotiateMaxSize sends local as this side's maximum value size, reads the peer's, and returns the smaller one.
+//   - local is capped at MaxPayloadSize; zero means MaxPayloadSize.
+//   - The first frame from the peer must be a Limits frame; anything else is ErrUnexpectedPayload.
+//   - When reading the peer's frame fails, it returns right away, without waiting for its own Limits frame
+//     to be written: that write may block for good. Close rw after an error; that ends the write too.
+func NegotiateMaxSize(rw io.ReadWriter, local uint32) (uint32, error) {
+	if local == 0 || local > MaxPayloadSize {
+		local = MaxPayloadSize
+	}
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := Limits{MaxSize: local}.WriteTo(rw)
+		written <- err
+	}()
+
+	peer, err := Decode(rw)
+	if err != nil {
+		return 0, err
+	}
+	limits, ok := peer.(*Limits)
+	if !ok {
+		return 0, fmt.Errorf("%w: expected *Limits, got %T", ErrUnexpectedPayload, peer)
+	}
+
+	// The peer follows the protocol, so it reads our frame too.
+	if err = <-written; err != nil {
+		return 0, err
+	}
+
+	return min(local, limits.MaxSize), nil
+}
@@ -0,0 +1,111 @@
+package ch04
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// One side allows 10MB, the other 2MB: both settle on 2MB, and a 5MB frame is refused on both ends.
+func TestNegotiateMaxSize(t *testing.T) {
+	client, server := tcpPair(t)
+
+	type result struct {
+		size uint32
+		err  error
+	}
+	serverSide := make(chan result, 1)
+	go func() {
+		size, err := NegotiateMaxSize(server, 2<<20)
+		serverSide <- result{size, err}
+	}()
+
+	clientSize, err := NegotiateMaxSize(client, 10<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := <-serverSide
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if clientSize != 2<<20 || r.size != 2<<20 {
+		t.Fatalf("expected both sides at 2MB; actual: client %d, server %d", clientSize, r.size)
+	}
+
+	// The encoders refuse the 5MB frame before it reaches the wire.
+	large := Binary(bytes.Repeat([]byte("x"), 5<<20))
+	for name, size := range map[string]uint32{"client": clientSize, "server": r.size} {
+		var out bytes.Buffer
+		enc := NewBufferedEncoder(&out, 0)
+		enc.SetMaxSize(size)
+		if err := enc.Encode(&large); !errors.Is(err, ErrMaxPayloadSize) {
+			t.Errorf("%s encoder: expected ErrMaxPayloadSize; actual: %v", name, err)
+		}
+		if enc.Buffered() != 0 || out.Len() != 0 {
+			t.Errorf("%s encoder: the refused frame was buffered", name)
+		}
+	}
+
+	// A peer that sends it anyway is refused by the decoders, on both ends.
+	frame, err := AppendFrame(nil, &large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, size := range map[string]uint32{"client": clientSize, "server": r.size} {
+		dec := NewDecoder(bytes.NewReader(frame), DecoderOptions{})
+		dec.SetMaxSize(size)
+		if _, err := dec.Decode(); !errors.Is(err, ErrMaxPayloadSize) {
+			t.Errorf("%s decoder: expected ErrMaxPayloadSize; actual: %v", name, err)
+		}
+	}
+
+	// A frame within the limit still goes through.
+	small := Binary("fits")
+	if _, err := small.WriteTo(client); err != nil {
+		t.Fatal(err)
+	}
+	dec := NewDecoder(server, DecoderOptions{MaxSize: r.size})
+	if p, err := dec.Decode(); err != nil || p.String() != "fits" {
+		t.Errorf("expected %q; actual: %v, %v", "fits", p, err)
+	}
+}
+
+// The first frame of the handshake must be a Limits frame.
+func TestNegotiateMaxSizeUnexpectedPayload(t *testing.T) {
+	client, server := tcpPair(t)
+
+	go func() {
+		s := String("hello")
+		_, _ = s.WriteTo(server)
+	}()
+
+	if _, err := NegotiateMaxSize(client, 0); !errors.Is(err, ErrUnexpectedPayload) {
+		t.Errorf("expected ErrUnexpectedPayload; actual: %v", err)
+	}
+}
+
+// A peer that sends a broken Limits frame and never reads doesn't leave NegotiateMaxSize waiting for its own write.
+func TestNegotiateMaxSizeInvalidPeer(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	defer server.Close()
+	go func() { _, _ = server.Write([]byte{LimitsType, 0, 0, 0, 1, 0}) }() // a 1-byte value
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := NegotiateMaxSize(client, 0)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrInvalidLimits) {
+			t.Errorf("expected ErrInvalidLimits; actual: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("NegotiateMaxSize waited for a write nobody reads")
+	}
+}
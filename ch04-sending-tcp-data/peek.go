@@ -0,0 +1,23 @@
+package ch04
+
+import "bufio"
+
+// ## Looking at the Type Before Decoding
+// A router or proxy often decides where a frame goes by its type alone, and may not want to decode it at all
+// (for example to forward the raw bytes). Reading the type byte from the connection would consume it,
+// and the frame could no longer be decoded or forwarded as a whole.
+//	- PeekType uses `bufio.Reader.Peek`: the byte is looked at in the reader's buffer, not consumed.
+//	- Decode (or a payload's ReadFrom, or a byte copy) then starts at the same frame as if nothing happened.
+//	- The caller must wrap the connection in a *bufio.Reader ONCE and read everything through it from then on:
+//	  the buffer may already hold bytes beyond the peeked one, and reading the connection directly would skip them.
+
+// PeekType returns the type byte of the next frame in br without consuming it.
+//   - At the end of the stream it returns io.EOF.
+func PeekType(br *bufio.Reader) (uint8, error) {
+	b, err := br.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return b[0], nil
+}
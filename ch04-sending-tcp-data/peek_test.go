@@ -0,0 +1,51 @@
+package ch04
+
+import (
+	"bufio"
+	"io"
+	"testing"
+)
+
+func TestPeekType(t *testing.T) {
+	client, server := tcpPair(t)
+
+	go func() {
+		for _, p := range []Payload{ptr(String("first")), ptr(Ping("second"))} {
+			if _, err := p.WriteTo(server); err != nil {
+				return
+			}
+		}
+		_ = server.Close()
+	}()
+
+	br := bufio.NewReader(client)
+	for _, expected := range []struct {
+		typ   uint8
+		value string
+	}{{StringType, "first"}, {PingType, "second"}} {
+		typ, err := PeekType(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != expected.typ {
+			t.Errorf("expected type %d; actual: %d", expected.typ, typ)
+		}
+
+		// Peeking again returns the same byte: nothing was consumed.
+		if again, _ := PeekType(br); again != typ {
+			t.Errorf("expected the same type on a second peek; actual: %d", again)
+		}
+
+		p, err := Decode(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.String() != expected.value {
+			t.Errorf("expected %q; actual: %q", expected.value, p)
+		}
+	}
+
+	if _, err := PeekType(br); err != io.EOF {
+		t.Errorf("expected io.EOF at the end; actual: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package ch04
+
+import "io"
+
+// ## Ping and Pong Payloads
+// The heartbeat from chapter 3 writes the raw string "ping" on the connection.
+// Inside a TLV stream that would break the framing, so the challenge and the response become payload types:
+//	- Ping is the challenge: a peer that receives it should answer with a Pong.
+//	- Pong is the response. By convention it echoes the Ping's bytes so the sender can match them.
+//	- Both carry arbitrary bytes (often empty), just like Binary.
+
+const (
+	PingType uint8 = 3
+	PongType uint8 = 4
+)
+
+func init() {
+	Register(PingType, func() Payload { return new(Ping) })
+	Register(PongType, func() Payload { return new(Pong) })
+}
+
+// Ping is a heartbeat challenge.
+type Ping []byte
+
+func (m Ping) Bytes() []byte  { return m }
+func (m Ping) String() string { return string(m) }
+
+func (m Ping) WriteTo(w io.Writer) (int64, error) { return writeTLV(w, PingType, m) }
+
+func (m *Ping) ReadFrom(r io.Reader) (int64, error) {
+	value, n, err := readTLV(r, PingType, "Ping")
+	if err != nil {
+		return n, err
+	}
+
+	*m = value
+	return n, nil
+}
+
+// Pong is the response to a Ping.
+type Pong []byte
+
+func (m Pong) Bytes() []byte  { return m }
+func (m Pong) String() string { return string(m) }
+
+func (m Pong) WriteTo(w io.Writer) (int64, error) { return writeTLV(w, PongType, m) }
+
+func (m *Pong) ReadFrom(r io.Reader) (int64, error) {
+	value, n, err := readTLV(r, PongType, "Pong")
+	if err != nil {
+		return n, err
+	}
+
+	*m = value
+	return n, nil
+}
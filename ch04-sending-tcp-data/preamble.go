@@ -0,0 +1,49 @@
+package ch04
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ## Announcing the Protocol with a Preamble
+// When one port serves several protocols (HTTP, SSH, ours), the server has to recognize a connection
+// before it can hand it to the right code. A TLV frame starts with an arbitrary type byte, which is hard to tell apart.
+//	- The client therefore writes a fixed 4-byte magic value once, right after connecting: the preamble.
+//	- The server reads exactly 4 bytes before any frame and compares them:
+//		- the magic value: the connection speaks our protocol, frames follow
+//		- anything else, or fewer than 4 bytes: ErrBadPreamble, and the connection should be closed
+//	- Preamble is "TLV" followed by a version byte, so a future incompatible format can get its own preamble.
+//	- A Server with RequirePreamble set reads it for every connection before calling the Handler.
+
+// Preamble is the magic value written at the start of a connection.
+var Preamble = [4]byte{'T', 'L', 'V', 1}
+
+// ErrBadPreamble is returned by ReadPreamble when the connection doesn't start with Preamble.
+var ErrBadPreamble = errors.New("bad preamble")
+
+// WritePreamble writes Preamble to w.
+func WritePreamble(w io.Writer) error {
+	_, err := w.Write(Preamble[:])
+	return err
+}
+
+// ReadPreamble reads 4 bytes from r and checks that they are Preamble.
+//   - A stream that ends before the first byte returns io.EOF.
+//   - A stream that ends within the preamble returns ErrBadPreamble (wrapping io.ErrUnexpectedEOF).
+func ReadPreamble(r io.Reader) error {
+	var actual [len(Preamble)]byte
+	if _, err := io.ReadFull(r, actual[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: %w", ErrBadPreamble, err)
+		}
+		return err
+	}
+
+	if !bytes.Equal(actual[:], Preamble[:]) {
+		return fmt.Errorf("%w: %q", ErrBadPreamble, actual[:])
+	}
+
+	return nil
+}
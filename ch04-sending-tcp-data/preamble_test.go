@@ -0,0 +1,77 @@
+package ch04
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Reza-1988/network-programming-with-go/ch03-tcp-conn-go-stdlib"
+)
+
+func TestReadPreamble(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream []byte
+		err    error
+	}{
+		{"matching", Preamble[:], nil},
+		{"not matching", []byte("GET / HTTP/1.1\r\n"), ErrBadPreamble},
+		{"short", Preamble[:2], ErrBadPreamble},
+		{"empty", nil, io.EOF},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ReadPreamble(bytes.NewReader(tc.stream))
+			if !errors.Is(err, tc.err) {
+				t.Errorf("expected %v; actual: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestServerRequirePreamble(t *testing.T) {
+	s := &Server{
+		RequirePreamble: true,
+		Handler:         func(conn net.Conn) { _ = echo(conn) },
+	}
+	addr := startServer(t, s)
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		return conn
+	}
+
+	// With the preamble, frames flow.
+	good := dial()
+	if err := WritePreamble(good); err != nil {
+		t.Fatal(err)
+	}
+	sent := String("hello")
+	if _, err := sent.WriteTo(good); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := Decode(good); err != nil || p.String() != sent.String() {
+		t.Fatalf("expected the echo of %q; actual: %v, %v", sent, p, err)
+	}
+
+	// Without it, the connection is closed before any frame is handled.
+	for _, start := range [][]byte{[]byte("SSH-2.0-"), Preamble[:3]} {
+		bad := dial()
+		_, _ = bad.Write(start)
+		if len(start) == 3 {
+			_ = bad.(*net.TCPConn).CloseWrite() // a short preamble
+		}
+		if _, err := Decode(bad); !errors.Is(err, io.EOF) && !ch03.IsConnReset(err) {
+			t.Errorf("preamble %q: expected the server to close the connection; actual: %v", start, err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package ch04
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ## Probing a Fresh Connection
+// A successful dial only proves that the peer's kernel completed the handshake.
+// The application behind it may be stuck, overloaded or speaking a different protocol.
+//	- Before routing traffic over a new connection, Probe asks the application itself:
+//		1. write a Ping
+//		2. read the next frame, which must be a Pong
+//	- Both steps share one deadline (through ExchangeContext), so a silent peer fails after timeout.
+//	- Anything other than a Pong means the peer doesn't speak our heartbeat; Probe reports ErrUnexpectedPayload.
+
+// ErrUnexpectedPayload is returned when a peer answers with the wrong payload type.
+var ErrUnexpectedPayload = errors.New("unexpected payload")
+
+// Probe sends a Ping on conn and waits up to timeout (or until ctx is done) for a Pong.
+func Probe(ctx context.Context, conn net.Conn, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ping := Ping("probe")
+	response, err := ExchangeContext(ctx, conn, &ping)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := response.(*Pong); !ok {
+		return fmt.Errorf("%w: expected *Pong, got %T", ErrUnexpectedPayload, response)
+	}
+
+	return nil
+}
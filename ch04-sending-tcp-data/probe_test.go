@@ -0,0 +1,67 @@
+package ch04
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestProbe(t *testing.T) {
+	t.Run("pong", func(t *testing.T) {
+		router := NewRouter()
+		router.Handle(PingType, func(p Payload) (Payload, error) {
+			pong := Pong(p.Bytes())
+			return &pong, nil
+		})
+
+		server, client := net.Pipe()
+		defer client.Close()
+		go func() {
+			_ = router.ServeConn(server)
+			_ = server.Close()
+		}()
+
+		if err := Probe(context.Background(), client, time.Second); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("silent", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		// Read the Ping, never answer.
+		go func() { _, _ = Decode(server) }()
+
+		start := time.Now()
+		err := Probe(context.Background(), client, 100*time.Millisecond)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected context.DeadlineExceeded; actual: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("Probe returned after %s", elapsed)
+		}
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		go func() {
+			if _, err := Decode(server); err != nil {
+				return
+			}
+			s := String("pong?")
+			_, _ = s.WriteTo(server)
+		}()
+
+		err := Probe(context.Background(), client, time.Second)
+		if !errors.Is(err, ErrUnexpectedPayload) {
+			t.Fatalf("expected ErrUnexpectedPayload; actual: %v", err)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package ch04
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ## Reporting Progress While Receiving a Binary
+// Binary.ReadFrom reads the whole value with one `io.ReadFull`, so the caller learns nothing until all of it is there.
+// For a payload of several megabytes a UI (or a log) wants to show how far along we are.
+//	- ReadFromProgress reads the header just like ReadFrom, so we know the total size up front.
+//	- Then it reads the value in chunks of progressChunkSize bytes, each with `io.ReadFull`,
+//	  and calls progress(done, total) after every chunk.
+//	- The last call always has done == total. A zero-length value reports (0, 0) once.
+//	- MaxPayloadSize is enforced before the value buffer is allocated, exactly like ReadFrom.
+
+// progressChunkSize is how much of the value is read between two progress calls.
+const progressChunkSize = 64 << 10 // 64KB
+
+// ReadFromProgress reads a Binary frame from r like ReadFrom and calls progress as the value arrives.
+func (m *Binary) ReadFromProgress(r io.Reader, progress func(done, total uint32)) (int64, error) {
+	var header [tlvHeaderSize]byte
+	o, err := io.ReadFull(r, header[:]) // 1-byte type + 4-byte size
+	n := int64(o)
+	if err != nil {
+		return n, err
+	}
+
+	if header[0] != BinaryType {
+		return n, errors.New("invalid Binary")
+	}
+	total := binary.BigEndian.Uint32(header[1:])
+	if total > MaxPayloadSize {
+		return n, ErrMaxPayloadSize
+	}
+
+	value := make([]byte, total)
+	var done uint32
+	for {
+		end := min(done+progressChunkSize, total)
+		o, err = io.ReadFull(r, value[done:end])
+		done += uint32(o)
+		n += int64(o)
+		if err != nil {
+			return n, err
+		}
+
+		progress(done, total)
+		if done == total {
+			break
+		}
+	}
+
+	*m = value
+	return n, nil
+}
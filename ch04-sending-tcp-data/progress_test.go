@@ -0,0 +1,65 @@
+package ch04
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func TestBinaryReadFromProgress(t *testing.T) {
+	data := make([]byte, 4<<20)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	b := Binary(data)
+
+	// A pipe hands the value over in small pieces, like a network connection.
+	r, w := io.Pipe()
+	go func() {
+		_, err := b.WriteTo(w)
+		_ = w.CloseWithError(err)
+	}()
+
+	var calls []uint32
+	var actual Binary
+	n, err := actual.ReadFromProgress(r, func(done, total uint32) {
+		if total != uint32(len(data)) {
+			t.Fatalf("expected total %d; actual: %d", len(data), total)
+		}
+		calls = append(calls, done)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := int64(tlvHeaderSize + len(data)); n != expected {
+		t.Errorf("expected %d bytes read; actual: %d", expected, n)
+	}
+	if !bytes.Equal(actual, data) {
+		t.Error("payload doesn't match")
+	}
+
+	if len(calls) < 2 {
+		t.Fatalf("expected several progress calls; actual: %d", len(calls))
+	}
+	for i := 1; i < len(calls); i++ {
+		if calls[i] <= calls[i-1] {
+			t.Fatalf("progress went from %d to %d", calls[i-1], calls[i])
+		}
+	}
+	if last := calls[len(calls)-1]; last != uint32(len(data)) {
+		t.Errorf("expected last progress %d; actual: %d", len(data), last)
+	}
+}
+
+func TestBinaryReadFromProgressMaxPayloadSize(t *testing.T) {
+	frame := []byte{BinaryType, 0xff, 0xff, 0xff, 0xff}
+
+	var b Binary
+	_, err := b.ReadFromProgress(bytes.NewReader(frame), func(uint32, uint32) {
+		t.Error("progress called for an oversized payload")
+	})
+	if err != ErrMaxPayloadSize {
+		t.Fatalf("expected ErrMaxPayloadSize; actual: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package ch04
+
+import (
+	"io"
+	"net"
+	"sync"
+)
+
+// ## A Bounded Send Queue with a Policy
+// A producer that is faster than the connection (or a peer that reads slowly) fills every buffer on the way.
+// An unbounded queue just moves the problem into our memory. QueueWriter bounds the queue and makes the
+// overflow explicit:
+//	- Send puts a payload in the queue; a background goroutine writes the queued payloads to w, in order.
+//	- When the queue holds capacity payloads, the OverflowPolicy decides:
+//		- OverflowBlock: Send waits until there is room. The producer slows down to the connection's pace.
+//		- OverflowDropOldest: the oldest queued payload is dropped to make room. Good for state updates,
+//		  where only the latest one matters. Dropped counts them.
+//		- OverflowError: Send returns ErrQueueFull (the same error as ReconnectingConn.Send) and the caller decides.
+//	- The payload being written is no longer in the queue: up to capacity payloads wait behind it.
+//	- After a failed write the queue is discarded and every Send returns that error: the stream is broken,
+//	  and writing the following payloads could leave half a frame followed by a whole one.
+//	- Close stops accepting payloads, waits until the queue is written (or a write fails) and returns the first
+//	  write error. With a consumer that never reads, Close waits too; closing the connection unblocks it.
+
+// OverflowPolicy decides what QueueWriter.Send does when the queue is full.
+type OverflowPolicy int
+
+const (
+	OverflowBlock OverflowPolicy = iota
+	OverflowDropOldest
+	OverflowError
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropOldest:
+		return "drop oldest"
+	case OverflowError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// QueueWriter writes payloads to w from a bounded queue in a background goroutine.
+//   - It is safe for concurrent use.
+type QueueWriter struct {
+	w        io.Writer
+	capacity int
+	policy   OverflowPolicy
+
+	mu      sync.Mutex
+	changed *sync.Cond // signaled when the queue or the state changes
+	queue   []Payload
+	dropped int
+	closed  bool
+	err     error // the first write error
+	done    chan struct{}
+}
+
+// NewQueueWriter starts writing to w and returns a QueueWriter holding at most capacity payloads.
+//   - A capacity below 1 is raised to 1.
+func NewQueueWriter(w io.Writer, capacity int, policy OverflowPolicy) *QueueWriter {
+	q := &QueueWriter{
+		w:        w,
+		capacity: max(capacity, 1),
+		policy:   policy,
+		done:     make(chan struct{}),
+	}
+	q.changed = sync.NewCond(&q.mu)
+	go q.run()
+
+	return q
+}
+
+// Send queues p, applying the overflow policy if the queue is full.
+//   - It returns the first write error once a write failed, and net.ErrClosed after Close.
+func (q *QueueWriter) Send(p Payload) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		switch {
+		case q.err != nil:
+			return q.err
+		case q.closed:
+			return net.ErrClosed
+		case len(q.queue) < q.capacity:
+			q.queue = append(q.queue, p)
+			q.changed.Broadcast()
+			return nil
+		}
+
+		switch q.policy {
+		case OverflowDropOldest:
+			q.queue[0] = nil // let the dropped payload be collected
+			q.queue = append(q.queue[1:], p)
+			q.dropped++
+			return nil
+		case OverflowError:
+			return ErrQueueFull
+		default:
+			q.changed.Wait() // until the writer takes a payload, or a write fails, or Close
+		}
+	}
+}
+
+// Len returns the number of payloads waiting in the queue.
+func (q *QueueWriter) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.queue)
+}
+
+// Dropped returns the number of payloads dropped by OverflowDropOldest.
+func (q *QueueWriter) Dropped() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// Close stops accepting payloads, waits until the queued ones are written and returns the first write error.
+//   - It doesn't close w.
+func (q *QueueWriter) Close() error {
+	q.mu.Lock()
+	q.closed = true
+	q.changed.Broadcast()
+	q.mu.Unlock()
+
+	<-q.done
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.err
+}
+
+// run writes the queued payloads until Close empties the queue or a write fails.
+func (q *QueueWriter) run() {
+	defer close(q.done)
+
+	for {
+		q.mu.Lock()
+		for len(q.queue) == 0 && !q.closed {
+			q.changed.Wait()
+		}
+		if len(q.queue) == 0 {
+			q.mu.Unlock()
+			return // closed and drained
+		}
+		p := q.queue[0]
+		q.queue[0] = nil
+		q.queue = q.queue[1:]
+		q.changed.Broadcast() // room for a blocked Send
+		q.mu.Unlock()
+
+		if _, err := p.WriteTo(q.w); err != nil {
+			q.mu.Lock()
+			q.err = err
+			q.queue = nil
+			q.changed.Broadcast()
+			q.mu.Unlock()
+			return
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package ch04
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestQueueWriter(t *testing.T) {
+	// stalled returns a QueueWriter whose consumer doesn't read: payload "1" is stuck in the write,
+	// "2" and "3" fill the queue of capacity 2.
+	stalled := func(t *testing.T, policy OverflowPolicy) (*QueueWriter, net.Conn) {
+		t.Helper()
+
+		server, client := net.Pipe()
+		t.Cleanup(func() { _ = server.Close() })
+		t.Cleanup(func() { _ = client.Close() })
+
+		q := NewQueueWriter(client, 2, policy)
+		for _, value := range []string{"1", "2", "3"} {
+			p := String(value)
+			if err := q.Send(&p); err != nil {
+				t.Fatal(err)
+			}
+			if value == "1" {
+				waitFor(t, func() bool { return q.Len() == 0 }) // taken by the writer
+			}
+		}
+
+		return q, server
+	}
+
+	// receive reads n payloads from the consumer's side.
+	receive := func(t *testing.T, server net.Conn, n int) []string {
+		t.Helper()
+
+		var values []string
+		for range n {
+			p, err := Decode(server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			values = append(values, p.String())
+		}
+		return values
+	}
+
+	t.Run("block", func(t *testing.T) {
+		q, server := stalled(t, OverflowBlock)
+
+		sent := make(chan error, 1)
+		go func() {
+			p := String("4")
+			sent <- q.Send(&p)
+		}()
+
+		select {
+		case err := <-sent:
+			t.Fatalf("expected Send to wait for room; returned %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		values := receive(t, server, 4) // the consumer catches up
+		if err := <-sent; err != nil {
+			t.Fatal(err)
+		}
+		if got := values[0] + values[1] + values[2] + values[3]; got != "1234" {
+			t.Errorf("expected every payload in order; actual: %q", values)
+		}
+		if err := q.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		q, server := stalled(t, OverflowDropOldest)
+
+		p := String("4")
+		if err := q.Send(&p); err != nil {
+			t.Fatal(err)
+		}
+		if q.Dropped() != 1 {
+			t.Errorf("expected 1 dropped payload; actual: %d", q.Dropped())
+		}
+
+		values := receive(t, server, 3)
+		if got := values[0] + values[1] + values[2]; got != "134" {
+			t.Errorf("expected 2 to be dropped and the newest to survive; actual: %q", values)
+		}
+		if err := q.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		q, server := stalled(t, OverflowError)
+
+		p := String("4")
+		if err := q.Send(&p); !errors.Is(err, ErrQueueFull) {
+			t.Fatalf("expected ErrQueueFull; actual: %v", err)
+		}
+
+		values := receive(t, server, 3)
+		if got := values[0] + values[1] + values[2]; got != "123" {
+			t.Errorf("expected the queued payloads only; actual: %q", values)
+		}
+		if err := q.Close(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		q, server := stalled(t, OverflowBlock)
+		_ = server.Close() // the consumer goes away
+
+		if err := q.Close(); err == nil {
+			t.Fatal("expected Close to report the failed write")
+		}
+		p := String("5")
+		if err := q.Send(&p); err == nil {
+			t.Fatal("expected Send to fail after a write error")
+		}
+	})
+}
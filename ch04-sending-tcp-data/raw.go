@@ -0,0 +1,56 @@
+package ch04
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ## Forwarding Types We Don't Know
+// A relay (like Proxy) sits between peers that may be newer than the relay itself.
+// When they start using a type byte the relay has never heard of, Decode fails with ErrUnknownType
+// and the relay breaks, even though it never needed to understand that payload.
+//	- Raw is a payload of ANY type: it keeps the type byte and the value bytes exactly as they arrived,
+//	  and writes the very same frame back out.
+//	- A Registry with SetRawFallback(true) decodes every unregistered type as a *Raw instead of failing.
+//	  (The Decoder, which uses DefaultRegistry, follows the same setting.)
+//	- Registered types are still decoded as usual; Raw is only the fallback.
+//	- Off by default: for most programs an unknown type is a real error.
+
+// Raw is a frame of a type without a registered payload, kept verbatim.
+type Raw struct {
+	Type  uint8
+	Value []byte
+}
+
+func (m Raw) Bytes() []byte  { return m.Value }
+func (m Raw) String() string { return fmt.Sprintf("raw type %d, %d bytes", m.Type, len(m.Value)) }
+
+// WriteTo writes the frame exactly as it was read.
+func (m Raw) WriteTo(w io.Writer) (int64, error) { return writeTLV(w, m.Type, m.Value) }
+
+// ReadFrom reads a frame of any type.
+func (m *Raw) ReadFrom(r io.Reader) (int64, error) {
+	var header [tlvHeaderSize]byte
+	n, err := io.ReadFull(r, header[:])
+	if err != nil {
+		if n > 0 {
+			err = truncated(err) // part of a header
+		}
+		return int64(n), err
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > MaxPayloadSize {
+		return int64(n), ErrMaxPayloadSize
+	}
+
+	value := make([]byte, size)
+	o, err := io.ReadFull(r, value)
+	if err != nil {
+		return int64(n + o), truncated(err)
+	}
+
+	m.Type, m.Value = header[0], value
+	return int64(n + o), nil
+}
@@ -0,0 +1,56 @@
+package ch04
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRegistryRawFallback(t *testing.T) {
+	const unknownType uint8 = 200
+
+	reg := NewRegistry()
+	reg.Register(BinaryType, func() Payload { return new(Binary) })
+
+	var stream bytes.Buffer
+	_, _ = writeTLV(&stream, unknownType, []byte("from the future"))
+	_, _ = Binary("known").WriteTo(&stream)
+	frames := stream.Bytes()
+
+	// Off: an unknown type is an error.
+	if _, err := reg.Decode(bytes.NewReader(frames)); !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("expected ErrUnknownType; actual: %v", err)
+	}
+
+	// On: it comes back verbatim, and the known type still decodes normally.
+	reg.SetRawFallback(true)
+	r := bytes.NewReader(frames)
+
+	p, err := reg.Decode(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, ok := p.(*Raw)
+	if !ok {
+		t.Fatalf("expected *Raw; actual: %T", p)
+	}
+	if raw.Type != unknownType || string(raw.Value) != "from the future" {
+		t.Errorf("unexpected Raw payload: type %d, %q", raw.Type, raw.Value)
+	}
+
+	if p, err = reg.Decode(r); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok = p.(*Binary); !ok {
+		t.Errorf("expected the registered type to decode as *Binary; actual: %T", p)
+	}
+
+	// Forwarding writes the identical frame.
+	var forwarded bytes.Buffer
+	if _, err = raw.WriteTo(&forwarded); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(forwarded.Bytes(), frames[:forwarded.Len()]) {
+		t.Error("the forwarded frame differs from the original")
+	}
+}
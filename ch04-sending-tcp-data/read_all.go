@@ -0,0 +1,86 @@
+package ch04
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// ## A Reusable Receive Loop
+// Listing 4-1 (read_test.go) reads into a fixed 512KB buffer until io.EOF. ReadAll is that loop as a helper,
+// with the buffer size left to the caller, because the right size depends on the workload:
+//	- a small buffer costs little memory per connection, but moving a lot of data takes many Read calls (system calls)
+//	- a large buffer needs fewer calls, but every connection holds it, even the ones that only ever see a few bytes
+// Every chunk read is written to sink; the loop ends at io.EOF (which is not an error) or at the first error.
+//
+// ### Stopping early
+// ReadAll only stops at the end of the stream. ReadAllContext can also be stopped through a context,
+// the same way ContextConn (chapter 3) does it:
+//	- a watcher registered with `context.AfterFunc` sets a read deadline in the past when ctx is done
+//	- the blocked Read returns a time-out right away, and ReadAllContext returns ctx.Err() with the bytes copied so far
+//	- before returning, the watcher is stopped (or waited for, if it already fired) and the deadline is cleared,
+//	  so the connection can be used again and nothing is left running
+
+// ErrInvalidBufferSize is returned for a buffer size that isn't positive.
+var ErrInvalidBufferSize = errors.New("buffer size must be positive")
+
+// ReadAll reads conn into a bufSize buffer and writes everything to sink until EOF.
+//   - It returns the number of bytes written to sink.
+func ReadAll(conn net.Conn, bufSize int, sink io.Writer) (int64, error) {
+	if bufSize <= 0 {
+		return 0, ErrInvalidBufferSize
+	}
+
+	return readAll(conn, bufSize, sink)
+}
+
+// ReadAllContext is ReadAll that stops when ctx is done and then returns ctx.Err().
+func ReadAllContext(ctx context.Context, conn net.Conn, bufSize int, sink io.Writer) (int64, error) {
+	if bufSize <= 0 {
+		return 0, ErrInvalidBufferSize
+	}
+
+	fired := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(fired)
+		_ = conn.SetReadDeadline(time.Unix(1, 0))
+	})
+
+	total, err := readAll(conn, bufSize, sink)
+
+	if !stop() {
+		// The watcher ran (or is running): wait for it, then undo its deadline.
+		<-fired
+		_ = conn.SetReadDeadline(time.Time{})
+		if isTimeout(err) {
+			err = ctx.Err()
+		}
+	}
+
+	return total, err
+}
+
+// readAll is the loop of ReadAll.
+func readAll(conn net.Conn, bufSize int, sink io.Writer) (int64, error) {
+	buf := make([]byte, bufSize)
+	var total int64
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			written, wErr := sink.Write(buf[:n])
+			total += int64(written)
+			if wErr != nil {
+				return total, wErr
+			}
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return total, nil
+			}
+			return total, err
+		}
+	}
+}
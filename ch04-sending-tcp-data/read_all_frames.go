@@ -0,0 +1,60 @@
+package ch04
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ## Reading Every Frame, Within a Total Budget
+// Some handlers need all of a client's messages before they can start (a batch upload, a transaction).
+// Decoding in a loop until io.EOF does that, but MaxPayloadSize only limits each frame:
+// a client sending a thousand frames just under the limit still makes us hold gigabytes.
+//	- ReadAllFrames decodes frames until io.EOF and counts the bytes of every frame, header included.
+//	- Before a value is read (and allocated), its header tells how large the frame is. If the frame would take
+//	  the total over maxTotalBytes, ReadAllFrames stops with ErrTotalSizeExceeded, leaving the value unread.
+//	- The header counts too, so a flood of empty frames is stopped just the same.
+//	- io.EOF between two frames is the normal end; inside a frame it is ErrTruncatedFrame, as with Decode.
+
+// ErrTotalSizeExceeded is returned by ReadAllFrames when the frames together exceed maxTotalBytes.
+var ErrTotalSizeExceeded = errors.New("frames exceed the total size limit")
+
+// ReadAllFrames decodes frames from r until io.EOF, as long as they take at most maxTotalBytes together.
+//   - On an error it returns the frames decoded before it along with the error.
+func ReadAllFrames(r io.Reader, maxTotalBytes int64) ([]Payload, error) {
+	var (
+		frames []Payload
+		total  int64
+	)
+	for {
+		// 1) The header alone: type and value size.
+		var header [tlvHeaderSize]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return frames, nil // between two frames: the end of the stream
+			}
+			return frames, truncated(err)
+		}
+
+		// 2) Check the budget before the value is allocated.
+		size := binary.BigEndian.Uint32(header[1:])
+		if size > MaxPayloadSize {
+			return frames, ErrMaxPayloadSize
+		}
+		frameSize := int64(tlvHeaderSize) + int64(size)
+		if total+frameSize > maxTotalBytes {
+			return frames, fmt.Errorf("%w: %d bytes read, next frame has %d of %d allowed",
+				ErrTotalSizeExceeded, total, frameSize, maxTotalBytes)
+		}
+
+		// 3) Decode the frame with its header put back in front of the value.
+		p, err := Decode(io.MultiReader(bytes.NewReader(header[:]), r))
+		if err != nil {
+			return frames, truncated(err)
+		}
+		frames = append(frames, p)
+		total += frameSize
+	}
+}
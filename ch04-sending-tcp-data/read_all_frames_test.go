@@ -0,0 +1,60 @@
+package ch04
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestReadAllFrames(t *testing.T) {
+	// stream returns count Binary frames with a 10-byte value each (15 bytes with the header).
+	stream := func(t *testing.T, count int) *bytes.Buffer {
+		t.Helper()
+
+		var buf bytes.Buffer
+		for range count {
+			p := Binary("0123456789")
+			if _, err := p.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return &buf
+	}
+
+	t.Run("under the cap", func(t *testing.T) {
+		frames, err := ReadAllFrames(stream(t, 4), 60) // exactly 4 × 15 bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frames) != 4 {
+			t.Fatalf("expected 4 frames; actual: %d", len(frames))
+		}
+		for i, p := range frames {
+			if p.String() != "0123456789" {
+				t.Errorf("frame %d: unexpected value %q", i, p)
+			}
+		}
+	})
+
+	t.Run("over the cap", func(t *testing.T) {
+		r := stream(t, 5)
+		frames, err := ReadAllFrames(r, 60)
+		if !errors.Is(err, ErrTotalSizeExceeded) {
+			t.Fatalf("expected ErrTotalSizeExceeded; actual: %v", err)
+		}
+		if len(frames) != 4 {
+			t.Errorf("expected the 4 frames within the cap; actual: %d", len(frames))
+		}
+		if r.Len() != 10 {
+			t.Errorf("expected the value of the fifth frame to stay unread; %d bytes left", r.Len())
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		r := stream(t, 2)
+		r.Truncate(r.Len() - 3)
+		if _, err := ReadAllFrames(r, 1<<20); !errors.Is(err, ErrTruncatedFrame) {
+			t.Fatalf("expected ErrTruncatedFrame; actual: %v", err)
+		}
+	})
+}
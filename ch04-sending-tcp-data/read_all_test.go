@@ -0,0 +1,91 @@
+package ch04
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadAll(t *testing.T) {
+	payload := make([]byte, 1<<24) // 16MB, as in Listing 4-1
+	if _, err := rand.Read(payload); err != nil {
+		t.Fatal(err)
+	}
+	expected := sha256.Sum256(payload)
+
+	for _, bufSize := range []int{4 << 10, 1 << 20} {
+		client, server := tcpPair(t)
+		go func() {
+			_, _ = server.Write(payload)
+			_ = server.Close()
+		}()
+
+		h := sha256.New()
+		n, err := ReadAll(client, bufSize, h)
+		if err != nil {
+			t.Fatalf("%d-byte buffer: %v", bufSize, err)
+		}
+		if n != int64(len(payload)) {
+			t.Errorf("%d-byte buffer: expected %d bytes; actual: %d", bufSize, len(payload), n)
+		}
+		if [32]byte(h.Sum(nil)) != expected {
+			t.Errorf("%d-byte buffer: the data changed on the way", bufSize)
+		}
+	}
+}
+
+func TestReadAllInvalidBufferSize(t *testing.T) {
+	client, _ := tcpPair(t)
+
+	if _, err := ReadAll(client, 0, io.Discard); !errors.Is(err, ErrInvalidBufferSize) {
+		t.Errorf("expected ErrInvalidBufferSize; actual: %v", err)
+	}
+}
+
+// progressWriter discards its input and counts the bytes; the count may be read from other goroutines.
+type progressWriter struct{ n atomic.Int64 }
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.n.Add(int64(len(p)))
+	return len(p), nil
+}
+
+func TestReadAllContextCancel(t *testing.T) {
+	client, server := tcpPair(t)
+
+	// The sender writes 1MB and then keeps the connection open without sending more.
+	chunk := make([]byte, 1<<20)
+	go func() { _, _ = server.Write(chunk) }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sink := &progressWriter{}
+	go func() {
+		for sink.n.Load() < int64(len(chunk)) {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+
+	start := time.Now()
+	n, err := ReadAllContext(ctx, client, 4<<10, sink)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled; actual: %v", err)
+	}
+	if n != int64(len(chunk)) {
+		t.Errorf("expected the %d bytes received before the cancel; actual: %d", len(chunk), n)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ReadAllContext returned %s after the start", elapsed)
+	}
+
+	// The deadline was cleared: the connection is still usable.
+	go func() { _, _ = server.Write([]byte("more")) }()
+	if _, err = io.ReadFull(client, make([]byte, 4)); err != nil {
+		t.Fatalf("read after ReadAllContext: %v", err)
+	}
+}
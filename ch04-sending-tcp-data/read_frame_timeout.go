@@ -0,0 +1,59 @@
+package ch04
+
+import (
+	"net"
+	"time"
+)
+
+// ## Reading One Frame Under One Deadline
+// A request/response server usually wants "the next request must arrive within 5 seconds".
+// Doing that by hand is easy to get wrong:
+//	- SetDeadline also limits the writes of the response; SetReadDeadline is the right call.
+//	- A deadline set before every Read call restarts the clock each time: a peer trickling one byte per second
+//	  keeps the frame alive forever. The deadline must cover the WHOLE frame: header and value.
+//	- The deadline must be cleared afterwards, or a later Read on the same connection fails for no visible reason.
+//
+// ReadFrameTimeout does all three. On a time-out, the error is the connection's own `net.Error`, so
+// `Timeout()` reports true and the caller can tell a slow peer from a broken one.
+
+// ReadFrameTimeout decodes one frame from conn, which must arrive completely within timeout.
+//   - The read deadline is cleared before returning.
+func ReadFrameTimeout(conn net.Conn, timeout time.Duration) (Payload, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
+
+	return Decode(conn)
+}
+
+// ### Measuring idle time instead
+// For a large value the "whole frame" deadline has the opposite problem: 10MB over a slow but healthy link
+// may take longer than any sensible timeout, and the frame fails although data never stopped flowing.
+// ReadFromRolling limits the time WITHOUT progress instead:
+//	- every Read gets a fresh deadline of perChunkTimeout, so each chunk that arrives pushes the deadline forward
+//	- a peer that stalls for perChunkTimeout fails with a time-out, however much it sent before
+//	- the price is the trickle problem above: a peer sending a byte just in time, every time, is never cut off.
+//	  Use it for bulk transfers from peers you trust to be well-behaved, and ReadFrameTimeout for requests.
+
+// ReadFromRolling decodes one frame from conn, failing only if no data arrives for perChunkTimeout.
+//   - The read deadline is cleared before returning.
+func ReadFromRolling(conn net.Conn, perChunkTimeout time.Duration) (Payload, error) {
+	defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
+
+	return Decode(&rollingReader{conn: conn, timeout: perChunkTimeout})
+}
+
+// rollingReader sets a read deadline of timeout before every Read on conn.
+type rollingReader struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (r *rollingReader) Read(p []byte) (int, error) {
+	if err := r.conn.SetReadDeadline(time.Now().Add(r.timeout)); err != nil {
+		return 0, err
+	}
+
+	return r.conn.Read(p)
+}
@@ -0,0 +1,109 @@
+package ch04
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// trickle writes the frame of p one chunk at a time, pausing between chunks.
+func trickle(conn net.Conn, p Payload, chunk int, pause time.Duration) {
+	var frame bytes.Buffer
+	_, _ = p.WriteTo(&frame)
+
+	data := frame.Bytes()
+	for len(data) > 0 {
+		n := min(chunk, len(data))
+		if _, err := conn.Write(data[:n]); err != nil {
+			return
+		}
+		data = data[n:]
+		time.Sleep(pause)
+	}
+}
+
+func TestReadFrameTimeoutExceeded(t *testing.T) {
+	client, server := tcpPair(t)
+
+	// 13 bytes at 1 byte per 50ms: every Read makes progress, but the whole frame takes 650ms.
+	b := Binary("trickle!")
+	go trickle(client, &b, 1, 50*time.Millisecond)
+
+	start := time.Now()
+	_, err := ReadFrameTimeout(server, 200*time.Millisecond)
+
+	var nErr net.Error
+	if !errors.As(err, &nErr) || !nErr.Timeout() {
+		t.Fatalf("expected a time-out net.Error; actual: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("the deadline didn't cover the whole frame: returned after %s", elapsed)
+	}
+}
+
+func TestReadFrameTimeoutInTime(t *testing.T) {
+	client, server := tcpPair(t)
+
+	// 3 chunks, 50ms apart: ~100ms for a 300ms timeout.
+	s := String("in time")
+	go trickle(client, &s, 5, 50*time.Millisecond)
+
+	payload, err := ReadFrameTimeout(server, 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.String() != "in time" {
+		t.Fatalf("unexpected payload: %q", payload)
+	}
+
+	// The deadline was cleared: a frame arriving well after it still reads.
+	time.Sleep(350 * time.Millisecond)
+	go func() { _, _ = String("later").WriteTo(client) }()
+	if _, err = Decode(server); err != nil {
+		t.Fatalf("read after ReadFrameTimeout failed: %v", err)
+	}
+}
+
+func TestReadFromRolling(t *testing.T) {
+	const perChunk = 200 * time.Millisecond
+	value := Binary(bytes.Repeat([]byte("steady"), 40<<10)) // 240KB
+
+	t.Run("slow but steady", func(t *testing.T) {
+		client, server := tcpPair(t)
+
+		// 16KB every 50ms: about 750ms in total, much longer than perChunk, but never idle for long.
+		go trickle(client, &value, 16<<10, 50*time.Millisecond)
+
+		start := time.Now()
+		p, err := ReadFromRolling(server, perChunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(p.Bytes(), value) {
+			t.Error("the received value differs from the sent one")
+		}
+		if elapsed := time.Since(start); elapsed < perChunk {
+			t.Errorf("expected the transfer to take longer than %s; took %s", perChunk, elapsed)
+		}
+	})
+
+	t.Run("stalled", func(t *testing.T) {
+		client, server := tcpPair(t)
+
+		var frame bytes.Buffer
+		_, _ = value.WriteTo(&frame)
+		go func() {
+			_, _ = client.Write(frame.Bytes()[:frame.Len()/2])
+			time.Sleep(3 * perChunk) // stalls mid-value
+			_, _ = client.Write(frame.Bytes()[frame.Len()/2:])
+		}()
+
+		_, err := ReadFromRolling(server, perChunk)
+		var nErr net.Error
+		if !errors.As(err, &nErr) || !nErr.Timeout() {
+			t.Fatalf("expected a time-out; actual: %v", err)
+		}
+	})
+}
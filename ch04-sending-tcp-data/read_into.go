@@ -0,0 +1,58 @@
+package ch04
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ## Reading a Frame into the Caller's Buffer
+// Binary.ReadFrom allocates a new slice for every frame, and the Decoder's ReuseBuffer keeps a buffer of its own.
+// A caller that already has a scratch buffer can skip both: ReadInto reads the frame straight into it.
+//	- It reads the 5-byte header, checks the length against MaxPayloadSize and len(buf), and fills buf[:length].
+//	- It returns the type byte and the value length: the value is buf[:n].
+//	- A frame larger than buf fails with ErrBufferTooSmall, after the header: the value is still in r.
+//	  Grow the buffer and skip or read the value, or close the connection, like after ErrMaxPayloadSize.
+//	- With a buf of at least 5 bytes nothing is allocated: even the header is read into buf (it is overwritten
+//	  by the value right after). So buf's contents are undefined after an error.
+//	- A shorter buf gets a 5-byte header allocated for each call. A stack array wouldn't help: r is an interface,
+//	  so anything passed to r.Read escapes to the heap.
+
+// ErrBufferTooSmall is returned by ReadInto when a frame's value doesn't fit in the buffer.
+var ErrBufferTooSmall = errors.New("buffer too small for frame")
+
+// ReadInto reads one TLV frame from r into buf and returns its type byte and value length n.
+//   - A stream that ends before the first byte returns io.EOF; one that ends mid-frame returns ErrTruncatedFrame.
+//   - It allocates only when buf is shorter than the 5-byte header.
+func ReadInto(r io.Reader, buf []byte) (typ uint8, n int, err error) {
+	var header []byte
+	if len(buf) >= tlvHeaderSize {
+		header = buf[:tlvHeaderSize]
+	} else {
+		header = make([]byte, tlvHeaderSize) // a buffer this small can only hold tiny values anyway
+	}
+
+	if got, err := io.ReadFull(r, header); err != nil {
+		if got > 0 {
+			err = truncated(err) // part of a header
+		}
+		return 0, 0, err
+	}
+	typ = header[0]
+	size := binary.BigEndian.Uint32(header[1:])
+
+	if size > MaxPayloadSize {
+		return typ, 0, ErrMaxPayloadSize
+	}
+	if uint64(size) > uint64(len(buf)) {
+		return typ, 0, fmt.Errorf("%w: need %d bytes, have %d", ErrBufferTooSmall, size, len(buf))
+	}
+
+	n, err = io.ReadFull(r, buf[:size])
+	if err != nil {
+		return typ, n, truncated(err)
+	}
+
+	return typ, n, nil
+}
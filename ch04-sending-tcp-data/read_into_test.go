@@ -0,0 +1,59 @@
+package ch04
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestReadInto(t *testing.T) {
+	buf := make([]byte, 64)
+
+	t.Run("fits", func(t *testing.T) {
+		frame, err := AppendFrame(nil, ptr(String("hello, buffer")))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		typ, n, err := ReadInto(bytes.NewReader(frame), buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != StringType || string(buf[:n]) != "hello, buffer" {
+			t.Errorf("expected String %q; actual: type %d, %q", "hello, buffer", typ, buf[:n])
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		frame, err := AppendFrame(nil, ptr(Binary(bytes.Repeat([]byte("x"), 65))))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := bytes.NewReader(frame)
+		if _, _, err := ReadInto(r, buf); !errors.Is(err, ErrBufferTooSmall) {
+			t.Fatalf("expected ErrBufferTooSmall; actual: %v", err)
+		}
+		if r.Len() != 65 {
+			t.Errorf("expected the value to stay in the reader; %d bytes left", r.Len())
+		}
+	})
+
+	t.Run("no allocations", func(t *testing.T) {
+		frame, err := AppendFrame(nil, ptr(Binary(bytes.Repeat([]byte("x"), 32))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := bytes.NewReader(frame)
+
+		allocs := testing.AllocsPerRun(100, func() {
+			r.Reset(frame)
+			if _, _, err := ReadInto(r, buf); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("expected no allocations; actual: %.1f", allocs)
+		}
+	})
+}
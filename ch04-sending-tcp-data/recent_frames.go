@@ -0,0 +1,78 @@
+package ch04
+
+import "sync"
+
+// ## Keeping the Last Frames for Post-Mortems
+// When a protocol conversation goes wrong, the error alone ("unknown type", "invalid UTF-8", ...) rarely explains why.
+// What helps is the traffic just before it, and a full packet capture is often not available in production.
+//	- With DecoderOptions.RecentFrames = N the Decoder keeps the last N frames it decoded: type, length and value.
+//	- RecentFrames() returns them oldest first, for example to log them next to the error.
+//	- The frames live in a ring buffer: once it is full, each new frame replaces the oldest one,
+//	  so memory stays bounded (by N times the largest value, so keep N small).
+//	- Every value is copied, so a frame stays intact even with ReuseBuffer.
+//	- The ring has its own lock: RecentFrames can be called from another goroutine (a debug handler, a signal
+//	  handler dumping state, ...) while Decode is running.
+//	- Like MaxSizes, it records TLV frames only; a Codec reads its own headers.
+
+// RecentFrame is a frame recorded by a Decoder with RecentFrames set.
+type RecentFrame struct {
+	Type   uint8
+	Length uint32
+	Value  []byte
+}
+
+// frameRing holds the last frames added to it.
+type frameRing struct {
+	mu     sync.Mutex
+	frames []RecentFrame // len(frames) is the capacity; filled slots are the first count ones in ring order
+	next   int           // slot for the next frame
+	count  int           // filled slots
+}
+
+func newFrameRing(size int) *frameRing {
+	return &frameRing{frames: make([]RecentFrame, size)}
+}
+
+// add records a frame, replacing the oldest one if the ring is full.
+func (r *frameRing) add(f RecentFrame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.frames[r.next] = f
+	r.next = (r.next + 1) % len(r.frames)
+	r.count = min(r.count+1, len(r.frames))
+}
+
+// snapshot returns the recorded frames, oldest first.
+func (r *frameRing) snapshot() []RecentFrame {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	frames := make([]RecentFrame, 0, r.count)
+	start := (r.next - r.count + len(r.frames)) % len(r.frames)
+	for i := range r.count {
+		frames = append(frames, r.frames[(start+i)%len(r.frames)])
+	}
+
+	return frames
+}
+
+// reset forgets all recorded frames.
+func (r *frameRing) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	clear(r.frames)
+	r.next, r.count = 0, 0
+}
+
+// RecentFrames returns the last frames the Decoder decoded, oldest first.
+//   - It returns nil unless DecoderOptions.RecentFrames is set.
+//   - It is safe to call while another goroutine is in Decode.
+func (d *Decoder) RecentFrames() []RecentFrame {
+	if d.recent == nil {
+		return nil
+	}
+
+	return d.recent.snapshot()
+}
@@ -0,0 +1,89 @@
+package ch04
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestDecoderRecentFrames(t *testing.T) {
+	const n = 3
+
+	// 7 frames of alternating types: more than the ring holds.
+	var stream bytes.Buffer
+	var expected []RecentFrame
+	for i := range 7 {
+		value := fmt.Sprintf("frame %d", i)
+		var p Payload = ptr(Binary(value))
+		typ := BinaryType
+		if i%2 == 1 {
+			p, typ = ptr(String(value)), StringType
+		}
+		if _, err := p.WriteTo(&stream); err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, RecentFrame{Type: typ, Length: uint32(len(value)), Value: []byte(value)})
+	}
+	expected = expected[len(expected)-n:]
+
+	for _, reuse := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ReuseBuffer=%t", reuse), func(t *testing.T) {
+			dec := NewDecoder(bytes.NewReader(stream.Bytes()), DecoderOptions{RecentFrames: n, ReuseBuffer: reuse})
+
+			// Another goroutine looks at the recent frames while Decode runs.
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			wg.Go(func() {
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						if frames := dec.RecentFrames(); len(frames) > n {
+							t.Errorf("expected at most %d frames; actual: %d", n, len(frames))
+						}
+					}
+				}
+			})
+
+			for {
+				if _, err := dec.Decode(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+			close(stop)
+			wg.Wait()
+
+			actual := dec.RecentFrames()
+			if len(actual) != n {
+				t.Fatalf("expected %d frames; actual: %d", n, len(actual))
+			}
+			for i, f := range actual {
+				e := expected[i]
+				if f.Type != e.Type || f.Length != e.Length || !bytes.Equal(f.Value, e.Value) {
+					t.Errorf("frame %d: expected %d/%d/%q; actual: %d/%d/%q",
+						i, e.Type, e.Length, e.Value, f.Type, f.Length, f.Value)
+				}
+			}
+
+			dec.Reset(bytes.NewReader(nil))
+			if frames := dec.RecentFrames(); len(frames) != 0 {
+				t.Errorf("expected no frames after Reset; actual: %d", len(frames))
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		dec := NewDecoder(bytes.NewReader(stream.Bytes()), DecoderOptions{})
+		if _, err := dec.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		if frames := dec.RecentFrames(); frames != nil {
+			t.Errorf("expected nil; actual: %v", frames)
+		}
+	})
+}
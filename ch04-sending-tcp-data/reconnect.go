@@ -0,0 +1,254 @@
+package ch04
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ## A Client That Survives a Dropped Connection
+// A long-running client will eventually see its connection drop: the server restarts, a NAT entry expires, ...
+// ReconnectingConn hides those outages from the code that sends payloads.
+//	- Send never writes to the network itself. It puts the payload in a bounded queue and returns.
+//	- A background goroutine owns the connection:
+//		1. dial (and on failure wait with Backoff before dialing again)
+//		2. take payloads from the queue and write them
+//		3. if a write fails, keep that payload, re-dial and write it again on the new connection
+//	- A second goroutine reads from the same connection. It delivers payloads to Receive and, just as important,
+//	  it notices immediately when the peer closes the connection, so we stop writing into a dead socket.
+//	- The queue is bounded: during a long outage Send returns ErrQueueFull instead of growing memory forever.
+//
+// NOTE:
+//	- A payload written just before the peer died may still be lost: the kernel accepted it,
+//	  but the peer never read it. Guaranteed delivery needs acknowledgments on top of this.
+
+// ErrQueueFull is returned when a payload doesn't fit in a bounded send queue.
+var ErrQueueFull = errors.New("send queue full")
+
+// ConnState describes the connection behind a ReconnectingConn.
+type ConnState int
+
+const (
+	StateConnecting ConnState = iota
+	StateConnected
+	StateDisconnected
+	StateClosed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// ReconnectingConn sends and receives payloads over a connection that is re-dialed when it fails.
+type ReconnectingConn struct {
+	dial     func(ctx context.Context) (net.Conn, error)
+	queue    chan Payload
+	incoming chan Payload
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	backoff Backoff // only used by the run goroutine
+
+	mu    sync.Mutex
+	state ConnState
+	conn  net.Conn
+}
+
+// NewReconnectingConn starts dialing with dial and returns immediately.
+//   - queueSize bounds both the outgoing queue and the incoming payloads waiting for Receive.
+//     A queueSize below 1 is raised to 1: an unbuffered queue would make every Send fail with ErrQueueFull.
+//   - backoff controls the wait between failed dials; the zero value uses the defaults.
+func NewReconnectingConn(dial func(ctx context.Context) (net.Conn, error), queueSize int, backoff Backoff) *ReconnectingConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	queueSize = max(queueSize, 1)
+
+	c := &ReconnectingConn{
+		dial:     dial,
+		queue:    make(chan Payload, queueSize),
+		incoming: make(chan Payload, queueSize),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		backoff:  backoff,
+	}
+	go c.run()
+
+	return c
+}
+
+// State returns the current connection state.
+func (c *ReconnectingConn) State() ConnState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.state
+}
+
+// Conn returns the current underlying connection, or nil while disconnected.
+func (c *ReconnectingConn) Conn() net.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.conn
+}
+
+func (c *ReconnectingConn) setConn(state ConnState, conn net.Conn) {
+	c.mu.Lock()
+	if c.state != StateClosed {
+		c.state, c.conn = state, conn
+	}
+	c.mu.Unlock()
+}
+
+// Send queues p for delivery. It returns ErrQueueFull if the queue is full and net.ErrClosed after Close.
+func (c *ReconnectingConn) Send(p Payload) error {
+	if c.ctx.Err() != nil {
+		return net.ErrClosed
+	}
+
+	select {
+	case c.queue <- p:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Receive returns the next payload read from any of the connections, waiting until one arrives or ctx is done.
+func (c *ReconnectingConn) Receive(ctx context.Context) (Payload, error) {
+	select {
+	case p := <-c.incoming:
+		return p, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops reconnecting and closes the current connection. Payloads still queued are dropped.
+func (c *ReconnectingConn) Close() error {
+	c.cancel()
+	<-c.done
+
+	c.mu.Lock()
+	c.state, c.conn = StateClosed, nil
+	c.mu.Unlock()
+
+	return nil
+}
+
+// run owns the connection: dial, write until something fails, repeat.
+func (c *ReconnectingConn) run() {
+	defer close(c.done)
+
+	var pending Payload // a payload whose write failed and must be retried
+	for {
+		conn, err := c.connect()
+		if err != nil {
+			return // closed
+		}
+
+		// Close unblocks a write that is stuck on this connection.
+		stop := context.AfterFunc(c.ctx, func() { _ = conn.Close() })
+
+		lost := make(chan struct{})
+		readDone := make(chan struct{})
+		go func() {
+			defer close(readDone)
+			c.readLoop(conn, lost)
+		}()
+
+		pending = c.writeLoop(conn, pending, lost)
+
+		stop()
+		_ = conn.Close() // also stops the read loop
+		<-readDone
+		c.setConn(StateDisconnected, nil)
+
+		if c.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// connect dials until it succeeds or the ReconnectingConn is closed.
+func (c *ReconnectingConn) connect() (net.Conn, error) {
+	for {
+		c.setConn(StateConnecting, nil)
+
+		conn, err := c.dial(c.ctx)
+		if err == nil {
+			c.backoff.Reset()
+			c.setConn(StateConnected, conn)
+			return conn, nil
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return nil, c.ctx.Err()
+		case <-time.After(c.backoff.Next()):
+		}
+	}
+}
+
+// writeLoop writes queued payloads to conn and returns the payload it failed to write, if any.
+func (c *ReconnectingConn) writeLoop(conn net.Conn, pending Payload, lost <-chan struct{}) Payload {
+	for {
+		if pending == nil {
+			// select picks at random among ready cases: check lost first, so a payload isn't taken
+			// from the queue only to be written to a connection that is already known to be dead.
+			select {
+			case <-lost:
+				return nil
+			default:
+			}
+
+			select {
+			case <-c.ctx.Done():
+				return nil
+			case <-lost:
+				return nil
+			case pending = <-c.queue:
+			}
+		}
+
+		if _, err := pending.WriteTo(conn); err != nil {
+			return pending
+		}
+		pending = nil
+	}
+}
+
+// readLoop delivers payloads from conn and closes lost when the connection fails.
+func (c *ReconnectingConn) readLoop(conn net.Conn, lost chan<- struct{}) {
+	defer close(lost)
+
+	for {
+		p, err := Decode(conn)
+		if err != nil {
+			return
+		}
+
+		select {
+		case c.incoming <- p:
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package ch04
+
+import (
+	"context"
+	"maps"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ## Request/Response Calls That Survive a Reconnect
+// Client matches responses to requests by ID, but only over one connection: when it drops, every waiting Call fails.
+// ReconnectingConn re-dials, but knows nothing about requests and responses. ReconnectingClient combines the two:
+//	- Call works like Client.Call: register the request under a new ID, write it, wait for the response with that ID.
+//	- A background goroutine owns the connection: dial (with Backoff), read responses, re-dial when the connection fails.
+//	- The pending map keeps every request until its response arrives, so after a reconnect the client knows what
+//	  is still outstanding and replays it over the new connection, in ID order.
+//	- A request whose response already arrived has left the pending map and is never replayed.
+//	- Each request is written at most once per connection: a request replayed on a new connection isn't written
+//	  again by its Call, and vice versa.
+//
+// NOTE:
+//	- The client can't know whether the server processed a request whose response was lost; it replays it anyway.
+//	  So the server may see a request twice (once per connection), and replayed requests must be safe to repeat.
+//	- A Call waits (until its ctx is done) while the client is reconnecting, instead of failing.
+
+// ReconnectingClient sends correlated requests over a connection that is re-dialed when it fails.
+//   - It is safe for concurrent use.
+type ReconnectingClient struct {
+	dial    func(ctx context.Context) (net.Conn, error)
+	backoff Backoff // only used by the run goroutine
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	writeMu sync.Mutex // held while writing, and while the connection is swapped; taken before mu
+	conn    net.Conn   // the current connection, nil while reconnecting; guarded by writeMu
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]*pendingCall
+}
+
+// pendingCall is a request waiting for its response.
+type pendingCall struct {
+	request  Payload
+	response chan Payload // buffered: the response is delivered once
+	sentOn   net.Conn     // the connection the request was last written to; guarded by writeMu
+}
+
+// NewReconnectingClient starts dialing with dial and returns immediately.
+//   - backoff controls the wait between failed dials; the zero value uses the defaults.
+func NewReconnectingClient(dial func(ctx context.Context) (net.Conn, error), backoff Backoff) *ReconnectingClient {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := &ReconnectingClient{
+		dial:    dial,
+		backoff: backoff,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		pending: make(map[uint64]*pendingCall),
+	}
+	go c.run()
+
+	return c
+}
+
+// Call sends p and waits for the response with the same request ID, across reconnects, until ctx is done.
+//   - After Close it returns net.ErrClosed.
+func (c *ReconnectingClient) Call(ctx context.Context, p Payload) (Payload, error) {
+	if c.ctx.Err() != nil {
+		return nil, net.ErrClosed
+	}
+
+	// 1) Register before writing, so the response and a replay both find the request.
+	call := &pendingCall{request: p, response: make(chan Payload, 1)}
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	// 2) Send it now if connected; otherwise the replay after the next connect sends it.
+	c.writeMu.Lock()
+	if c.conn != nil {
+		c.send(c.conn, id, call)
+	}
+	c.writeMu.Unlock()
+
+	// 3) Wait for the response.
+	select {
+	case r := <-call.response:
+		return r, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops reconnecting and closes the current connection; waiting Calls return net.ErrClosed.
+func (c *ReconnectingClient) Close() error {
+	c.cancel()
+	<-c.done
+
+	return nil
+}
+
+// send writes call to conn unless it was already written there. c.writeMu must be held.
+//   - A failed write closes conn: the read loop notices, and the request is replayed on the next connection.
+func (c *ReconnectingClient) send(conn net.Conn, id uint64, call *pendingCall) {
+	if call.sentOn == conn {
+		return
+	}
+
+	if err := writeCorrelated(conn, id, call.request); err != nil {
+		_ = conn.Close()
+		return
+	}
+	call.sentOn = conn
+}
+
+// run owns the connection: dial, replay, read responses until the connection fails, repeat.
+func (c *ReconnectingClient) run() {
+	defer close(c.done)
+
+	for {
+		conn, err := c.connect()
+		if err != nil {
+			return // closed
+		}
+		stop := context.AfterFunc(c.ctx, func() { _ = conn.Close() })
+
+		c.attach(conn)
+		c.readLoop(conn)
+
+		stop()
+		_ = conn.Close()
+		c.writeMu.Lock()
+		c.conn = nil
+		c.writeMu.Unlock()
+
+		if c.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// attach makes conn the current connection and replays the outstanding requests on it, oldest first.
+//   - writeMu is held throughout, so no Call writes in between and nothing is sent twice.
+func (c *ReconnectingClient) attach(conn net.Conn) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn = conn
+
+	c.mu.Lock()
+	ids := slices.Sorted(maps.Keys(c.pending))
+	calls := make([]*pendingCall, len(ids))
+	for i, id := range ids {
+		calls[i] = c.pending[id]
+	}
+	c.mu.Unlock()
+
+	for i, call := range calls {
+		c.send(conn, ids[i], call)
+	}
+}
+
+// connect dials until it succeeds or the client is closed.
+func (c *ReconnectingClient) connect() (net.Conn, error) {
+	for {
+		conn, err := c.dial(c.ctx)
+		if err == nil {
+			c.backoff.Reset()
+			return conn, nil
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return nil, c.ctx.Err()
+		case <-time.After(c.backoff.Next()):
+		}
+	}
+}
+
+// readLoop delivers every response on conn to the Call waiting for its ID, until conn fails.
+func (c *ReconnectingClient) readLoop(conn net.Conn) {
+	for {
+		id, p, err := readCorrelated(conn)
+		if err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		call, ok := c.pending[id]
+		delete(c.pending, id) // answered: never replayed
+		c.mu.Unlock()
+		if ok {
+			call.response <- p
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package ch04
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The first connection answers the first request and drops the second one without a response.
+// The client reconnects and replays only the unanswered request.
+func TestReconnectingClientReplay(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	var mu sync.Mutex
+	received := make(map[int][]string) // requests per connection
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		for i := 0; ; i++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			for answered := 0; ; answered++ {
+				id, p, err := readCorrelated(conn)
+				if err != nil {
+					break
+				}
+				mu.Lock()
+				received[i] = append(received[i], p.String())
+				mu.Unlock()
+
+				if i == 0 && answered == 1 {
+					break // the connection drops before the second response
+				}
+				response := String("response to " + p.String())
+				if err = writeCorrelated(conn, id, &response); err != nil {
+					break
+				}
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	var d net.Dialer
+	client := NewReconnectingClient(func(ctx context.Context) (net.Conn, error) {
+		return d.DialContext(ctx, "tcp", listener.Addr().String())
+	}, Backoff{Min: 10 * time.Millisecond, Max: 50 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, request := range []string{"first", "second"} {
+		p, err := client.Call(ctx, ptr(String(request)))
+		if err != nil {
+			t.Fatalf("%s: %v", request, err)
+		}
+		if expected := "response to " + request; p.String() != expected {
+			t.Errorf("expected %q; actual: %q", expected, p)
+		}
+	}
+
+	_ = client.Close()
+	_ = listener.Close()
+	<-served
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(received[0], []string{"first", "second"}) {
+		t.Errorf("first connection: expected both requests; actual: %q", received[0])
+	}
+	if !slices.Equal(received[1], []string{"second"}) {
+		t.Errorf("second connection: expected only the unanswered request, once; actual: %q", received[1])
+	}
+}
+
+func TestReconnectingClientClose(t *testing.T) {
+	client := NewReconnectingClient(func(ctx context.Context) (net.Conn, error) {
+		return nil, net.ErrClosed // never connects
+	}, Backoff{Min: 10 * time.Millisecond, Max: 10 * time.Millisecond})
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.Call(context.Background(), ptr(String("waiting")))
+		result <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	_ = client.Close()
+
+	select {
+	case err := <-result:
+		if err != net.ErrClosed {
+			t.Errorf("expected net.ErrClosed; actual: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Call did not return after Close")
+	}
+}
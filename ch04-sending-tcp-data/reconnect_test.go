@@ -0,0 +1,159 @@
+package ch04
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// waitForState polls c until it reaches one of the states or the test times out.
+func waitForState(t *testing.T, c *ReconnectingConn, states ...ConnState) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		current := c.State()
+		for _, s := range states {
+			if current == s {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected state %v; actual: %v", states, c.State())
+}
+
+// serveOnce accepts one connection on listener and sends every decoded payload's text to received.
+func serveOnce(t *testing.T, listener net.Listener, received chan<- string) net.Conn {
+	t.Helper()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			p, err := Decode(conn)
+			if err != nil {
+				return
+			}
+			received <- p.String()
+		}
+	}()
+
+	return conn
+}
+
+// This test kills the server in the middle of the stream and starts it again on the same address.
+//   - Payloads sent while the server is down wait in the queue.
+//   - After the reconnection they arrive in order.
+func TestReconnectingConn(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+
+	var d net.Dialer
+	c := NewReconnectingConn(func(ctx context.Context) (net.Conn, error) {
+		return d.DialContext(ctx, "tcp", addr)
+	}, 16, Backoff{Min: 10 * time.Millisecond, Max: 50 * time.Millisecond})
+	defer c.Close()
+
+	received := make(chan string, 16)
+	server := serveOnce(t, listener, received)
+
+	for _, s := range []string{"1", "2"} {
+		p := String(s)
+		if err := c.Send(&p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, expected := range []string{"1", "2"} {
+		if actual := <-received; actual != expected {
+			t.Fatalf("expected %q; actual: %q", expected, actual)
+		}
+	}
+
+	// Kill the server.
+	_ = listener.Close()
+	_ = server.Close()
+	waitForState(t, c, StateConnecting, StateDisconnected)
+
+	for _, s := range []string{"3", "4"} {
+		p := String(s)
+		if err := c.Send(&p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Restart the server on the same address.
+	listener, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	server = serveOnce(t, listener, received)
+	defer server.Close()
+
+	for _, expected := range []string{"3", "4"} {
+		select {
+		case actual := <-received:
+			if actual != expected {
+				t.Fatalf("expected %q; actual: %q", expected, actual)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("payload %q did not arrive after reconnecting", expected)
+		}
+	}
+
+	if s := c.State(); s != StateConnected {
+		t.Errorf("expected %v; actual: %v", StateConnected, s)
+	}
+}
+
+// Beyond its capacity the queue refuses payloads instead of growing.
+func TestReconnectingConnQueueFull(t *testing.T) {
+	c := NewReconnectingConn(func(context.Context) (net.Conn, error) {
+		return nil, errors.New("server down")
+	}, 2, Backoff{})
+	defer c.Close()
+
+	p := Binary("x")
+	for i := 0; i < 2; i++ {
+		if err := c.Send(&p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.Send(&p); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull; actual: %v", err)
+	}
+
+	_ = c.Close()
+	if err := c.Send(&p); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed after Close; actual: %v", err)
+	}
+	if s := c.State(); s != StateClosed {
+		t.Errorf("expected %v; actual: %v", StateClosed, s)
+	}
+}
+
+// A queueSize of 0 still queues one payload instead of rejecting every Send.
+func TestReconnectingConnZeroQueueSize(t *testing.T) {
+	c := NewReconnectingConn(func(context.Context) (net.Conn, error) {
+		return nil, errors.New("server down")
+	}, 0, Backoff{})
+	defer c.Close()
+
+	p := Binary("x")
+	if err := c.Send(&p); err != nil {
+		t.Fatalf("expected the first payload to be queued; actual: %v", err)
+	}
+	if err := c.Send(&p); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull; actual: %v", err)
+	}
+}
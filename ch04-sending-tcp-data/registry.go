@@ -0,0 +1,108 @@
+package ch04
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ## Decoding Through a Registry
+// The decode function from Listing 4-9 has a switch that knows only about Binary and String.
+//	- Every new payload type would mean editing that switch.
+//	- A registry turns the switch into a map: type byte → function that creates an empty payload.
+//	- New types simply register themselves, and Decode works for them without any change.
+//	- This is the same idea as `http.DefaultServeMux`: there is a package-level registry that most code uses,
+//	  and you can create your own Registry when you need a different set of types (for example in a test).
+
+// ErrUnknownType is returned when a frame's type byte has no registered payload.
+var ErrUnknownType = errors.New("unknown type")
+
+// Registry maps type bytes to payload constructors.
+//   - It is safe for concurrent use.
+type Registry struct {
+	mu          sync.RWMutex
+	types       map[uint8]func() Payload
+	rawFallback bool // see raw.go
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{types: make(map[uint8]func() Payload)}
+}
+
+// Register associates typ with a function returning a new, empty payload (like `new(Binary)`).
+//   - Registering the same type twice replaces the previous constructor.
+func (reg *Registry) Register(typ uint8, newPayload func() Payload) {
+	reg.mu.Lock()
+	reg.types[typ] = newPayload
+	reg.mu.Unlock()
+}
+
+// SetRawFallback chooses whether unregistered types decode as *Raw (true) or fail with ErrUnknownType (false, the default).
+func (reg *Registry) SetRawFallback(on bool) {
+	reg.mu.Lock()
+	reg.rawFallback = on
+	reg.mu.Unlock()
+}
+
+// lookup returns the constructor for typ, if any.
+//   - With the raw fallback on, every type has one.
+func (reg *Registry) lookup(typ uint8) (func() Payload, bool) {
+	reg.mu.RLock()
+	newPayload, ok := reg.types[typ]
+	fallback := reg.rawFallback
+	reg.mu.RUnlock()
+
+	if !ok && fallback {
+		return func() Payload { return new(Raw) }, true
+	}
+	return newPayload, ok
+}
+
+// Decode reads one frame from r and returns it as the registered payload type.
+//   - It works exactly like decode from Listing 4-9:
+//     1. read the 1-byte type
+//     2. find (create) the matching payload
+//     3. put the type byte back in front of the reader with `io.MultiReader` and call ReadFrom
+func (reg *Registry) Decode(r io.Reader) (Payload, error) {
+	var typ uint8
+	err := binary.Read(r, binary.BigEndian, &typ)
+	if err != nil {
+		return nil, err
+	}
+
+	return reg.decodeType(typ, r)
+}
+
+// decodeType finishes decoding a frame whose type byte was already read from r.
+func (reg *Registry) decodeType(typ uint8, r io.Reader) (Payload, error) {
+	newPayload, ok := reg.lookup(typ)
+	if !ok {
+		return nil, ErrUnknownType
+	}
+
+	payload := newPayload()
+	_, err := payload.ReadFrom(io.MultiReader(bytes.NewReader([]byte{typ}), r))
+	if err != nil {
+		return nil, truncated(err) // the type byte was read: we are inside a frame
+	}
+
+	return payload, nil
+}
+
+// DefaultRegistry is the registry used by Register and Decode.
+//   - It knows the Binary and String types out of the box.
+var DefaultRegistry = NewRegistry()
+
+func init() {
+	Register(BinaryType, func() Payload { return new(Binary) })
+	Register(StringType, func() Payload { return new(String) })
+}
+
+// Register adds a payload type to DefaultRegistry.
+func Register(typ uint8, newPayload func() Payload) { DefaultRegistry.Register(typ, newPayload) }
+
+// Decode reads one frame from r using DefaultRegistry.
+func Decode(r io.Reader) (Payload, error) { return DefaultRegistry.Decode(r) }
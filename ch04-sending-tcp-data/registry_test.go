@@ -0,0 +1,37 @@
+package ch04
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// This test uses its own Registry that only knows the Binary type.
+//   - A Binary frame decodes as usual.
+//   - A String frame has no registered constructor, so Decode returns ErrUnknownType.
+func TestRegistryDecode(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(BinaryType, func() Payload { return new(Binary) })
+
+	buf := new(bytes.Buffer)
+	b := Binary("binary")
+	s := String("string")
+	for _, p := range []Payload{&b, &s} {
+		if _, err := p.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	actual, err := reg.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual.Bytes(), b) {
+		t.Errorf("value mismatch: %q != %q", b, actual)
+	}
+
+	_, err = reg.Decode(buf)
+	if !errors.Is(err, ErrUnknownType) {
+		t.Errorf("expected ErrUnknownType; actual: %v", err)
+	}
+}
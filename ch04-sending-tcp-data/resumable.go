@@ -0,0 +1,168 @@
+package ch04
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ## Resuming an Interrupted Transfer
+// A large file sent over a flaky link may break at 90%. Starting over wastes the 90% that already arrived,
+// and on a link that breaks often enough, the transfer never finishes. Resuming needs only one number:
+// how many bytes the receiver already has.
+//	1. The receiver opens every transfer with a Resume frame holding its offset:
+//		- [ResumeType][Length = 8] [Offset: 8 bytes, big-endian]
+//	2. The sender seeks its source (an io.ReadSeeker) to that offset.
+//	3. The sender streams the rest as Chunk frames (see chunk.go), the final chunk flagged as such.
+//	4. The receiver writes each chunk's data once the whole chunk arrived, and only then advances its offset.
+// So the offset always counts bytes that were received completely and written: a chunk cut off by the drop
+// is simply sent again on the next attempt.
+//	- The offset survives in the ResumableReceiver. To resume across restarts, create the receiver with the
+//	  size of the partial file.
+//	- Seq starts at 0 in every attempt: it checks the chunks of one attempt, the offset connects the attempts.
+//	- An offset past the end of the source fails with ErrInvalidResume: the receiver has a different file.
+
+// ResumeType is the type byte of a Resume frame.
+const ResumeType uint8 = 12
+
+// resumeSize is the length of a Resume value.
+const resumeSize = 8
+
+// ErrInvalidResume is returned for a Resume frame whose value isn't exactly 8 bytes,
+// or whose offset is past the end of the source.
+var ErrInvalidResume = errors.New("invalid Resume")
+
+func init() {
+	Register(ResumeType, func() Payload { return new(Resume) })
+}
+
+// Resume tells the sender how many bytes of the transfer the receiver already has.
+type Resume struct {
+	Offset uint64
+}
+
+// Bytes returns the encoded 8-byte value.
+func (m Resume) Bytes() []byte { return binary.BigEndian.AppendUint64(nil, m.Offset) }
+
+func (m Resume) String() string { return fmt.Sprintf("resume at %d", m.Offset) }
+
+func (m Resume) WriteTo(w io.Writer) (int64, error) { return writeTLV(w, ResumeType, m.Bytes()) }
+
+// ReadFrom reads a Resume frame.
+func (m *Resume) ReadFrom(r io.Reader) (int64, error) {
+	value, n, err := readTLV(r, ResumeType, "Resume")
+	if err != nil {
+		return n, err
+	}
+	if len(value) != resumeSize {
+		return n, ErrInvalidResume
+	}
+
+	m.Offset = binary.BigEndian.Uint64(value)
+	return n, nil
+}
+
+// ResumableSender sends a source to a ResumableReceiver, starting where the receiver left off.
+type ResumableSender struct {
+	src  io.ReadSeeker
+	size int
+}
+
+// NewResumableSender returns a ResumableSender reading from src and sending at most chunkSize bytes per chunk.
+//   - Zero means DefaultChunkSize; a size that doesn't fit in a frame is lowered to fit.
+func NewResumableSender(src io.ReadSeeker, chunkSize int) *ResumableSender {
+	return &ResumableSender{src: src, size: clampChunkSize(chunkSize)}
+}
+
+// Send reads the receiver's Resume frame from rw, then writes the source from that offset to the end.
+//   - It returns the number of source bytes sent in this attempt.
+func (s *ResumableSender) Send(rw io.ReadWriter) (int64, error) {
+	// 1) Where does the receiver stand?
+	var resume Resume
+	if _, err := resume.ReadFrom(rw); err != nil {
+		return 0, err
+	}
+
+	// 2) Skip what it already has.
+	end, err := s.src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if resume.Offset > uint64(end) {
+		return 0, fmt.Errorf("%w: offset %d is past the end of the source (%d bytes)", ErrInvalidResume, resume.Offset, end)
+	}
+	if _, err = s.src.Seek(int64(resume.Offset), io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	// 3) Stream the rest. A short read means the source is exhausted: that chunk is the final one.
+	buf := make([]byte, s.size)
+	var sent int64
+	for seq := uint32(0); ; seq++ {
+		n, err := io.ReadFull(s.src, buf)
+		final := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !final {
+			return sent, err
+		}
+
+		chunk := Chunk{Seq: seq, Final: final, Data: buf[:n]}
+		if _, err = chunk.WriteTo(rw); err != nil {
+			return sent, err
+		}
+		sent += int64(n)
+
+		if final {
+			return sent, nil
+		}
+	}
+}
+
+// ResumableReceiver receives a source from a ResumableSender, over as many attempts as it takes.
+type ResumableReceiver struct {
+	w      io.Writer
+	offset int64
+}
+
+// NewResumableReceiver returns a ResumableReceiver writing to w, which already holds the first offset bytes.
+func NewResumableReceiver(w io.Writer, offset int64) *ResumableReceiver {
+	return &ResumableReceiver{w: w, offset: offset}
+}
+
+// Offset returns how many bytes have been received and written so far.
+func (r *ResumableReceiver) Offset() int64 { return r.offset }
+
+// Receive sends the current offset on rw and writes the chunks that follow to w, up to the final one.
+//   - It returns the number of bytes received in this attempt.
+//   - If the connection ends before the final chunk, the error is io.ErrUnexpectedEOF or ErrTruncatedFrame;
+//     call Receive again on a new connection to continue.
+func (r *ResumableReceiver) Receive(rw io.ReadWriter) (int64, error) {
+	if _, err := (Resume{Offset: uint64(r.offset)}).WriteTo(rw); err != nil {
+		return 0, err
+	}
+
+	var received int64
+	for seq := uint32(0); ; seq++ {
+		var chunk Chunk
+		if _, err := chunk.ReadFrom(rw); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF // the transfer is incomplete
+			}
+			return received, err
+		}
+		if chunk.Seq != seq {
+			return received, fmt.Errorf("%w: expected %d; got %d", ErrChunkSequence, seq, chunk.Seq)
+		}
+
+		n, err := r.w.Write(chunk.Data)
+		r.offset += int64(n)
+		received += int64(n)
+		if err != nil {
+			return received, err
+		}
+
+		if chunk.Final {
+			return received, nil
+		}
+	}
+}
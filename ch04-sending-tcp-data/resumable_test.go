@@ -0,0 +1,77 @@
+package ch04
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestResumableTransfer(t *testing.T) {
+	source := make([]byte, 1<<20)
+	_, _ = rand.Read(source)
+	const chunkSize = 32 << 10
+
+	var file bytes.Buffer
+	receiver := NewResumableReceiver(&file, 0)
+
+	// attempt transfers over a fresh connection; a non-zero budget drops it after that many bytes.
+	attempt := func(budget int64) (int64, error) {
+		server, client := net.Pipe()
+		defer client.Close()
+
+		var conn net.Conn = server
+		if budget > 0 {
+			conn = NewBudgetConn(server, Budget{Write: budget})
+		}
+
+		sent := make(chan error, 1)
+		go func() {
+			_, err := NewResumableSender(bytes.NewReader(source), chunkSize).Send(conn)
+			_ = conn.Close()
+			sent <- err
+		}()
+
+		n, err := receiver.Receive(client)
+		<-sent
+		return n, err
+	}
+
+	// 1) The connection drops about halfway through.
+	if _, err := attempt(int64(len(source) / 2)); err == nil {
+		t.Fatal("expected the first attempt to fail")
+	}
+	half := receiver.Offset()
+	if half == 0 || half >= int64(len(source)) || half%chunkSize != 0 {
+		t.Fatalf("expected a partial transfer of whole chunks; offset: %d", half)
+	}
+	if !bytes.Equal(file.Bytes(), source[:half]) {
+		t.Fatal("the partial file doesn't match the start of the source")
+	}
+
+	// 2) Resume: only the rest is sent.
+	n, err := attempt(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(source))-half {
+		t.Fatalf("expected the resumed attempt to send %d bytes; actual: %d", int64(len(source))-half, n)
+	}
+	if !bytes.Equal(file.Bytes(), source) {
+		t.Fatal("the received file doesn't match the source")
+	}
+}
+
+func TestResumableSenderInvalidOffset(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() { _, _ = (Resume{Offset: 100}).WriteTo(client) }()
+
+	_, err := NewResumableSender(bytes.NewReader(make([]byte, 10)), 0).Send(server)
+	if !errors.Is(err, ErrInvalidResume) {
+		t.Fatalf("expected ErrInvalidResume; actual: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package ch04
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// ## Dispatching Frames by Type
+// Every server built on TLV ends up with the same loop: read a frame, switch on its type, answer.
+// A Router replaces that switch with handlers registered per type byte (like `http.ServeMux` does for paths).
+//	- ServeConn reads the type byte itself, so it can look up the handler BEFORE decoding the value.
+//	- A frame without a handler is skipped (its value is discarded) and answered with a ProtocolError frame,
+//	  so the client learns what went wrong and the stream stays in sync for the next frame.
+//	- A handler's error is also sent back as a ProtocolError; a nil response means "no answer".
+
+// ErrorType is the type byte of a ProtocolError frame.
+const ErrorType uint8 = 5
+
+func init() {
+	Register(ErrorType, func() Payload { return new(ProtocolError) })
+}
+
+// ProtocolError is a payload carrying an error message back to the peer.
+type ProtocolError string
+
+func (m ProtocolError) Bytes() []byte  { return []byte(m) }
+func (m ProtocolError) String() string { return string(m) }
+
+func (m ProtocolError) WriteTo(w io.Writer) (int64, error) { return writeTLV(w, ErrorType, []byte(m)) }
+
+func (m *ProtocolError) ReadFrom(r io.Reader) (int64, error) {
+	value, n, err := readTLV(r, ErrorType, "ProtocolError")
+	if err != nil {
+		return n, err
+	}
+
+	*m = ProtocolError(value)
+	return n, nil
+}
+
+// Handler answers a decoded payload. Returning a nil Payload sends nothing back.
+type Handler func(Payload) (Payload, error)
+
+// Router dispatches frames to handlers registered by type byte.
+type Router struct {
+	mu       sync.RWMutex
+	handlers map[uint8]Handler
+}
+
+// NewRouter returns a Router without any handlers.
+func NewRouter() *Router {
+	return &Router{handlers: make(map[uint8]Handler)}
+}
+
+// Handle registers h for frames of type typ, replacing any previous handler.
+func (rt *Router) Handle(typ uint8, h func(Payload) (Payload, error)) {
+	rt.mu.Lock()
+	rt.handlers[typ] = h
+	rt.mu.Unlock()
+}
+
+func (rt *Router) handler(typ uint8) (Handler, bool) {
+	rt.mu.RLock()
+	h, ok := rt.handlers[typ]
+	rt.mu.RUnlock()
+
+	return h, ok
+}
+
+// ServeConn reads frames from conn and writes each handler's response back, until EOF or an error.
+//   - It returns nil when the peer closes the connection between frames.
+//   - It does not close conn; that is up to the caller.
+func (rt *Router) ServeConn(conn net.Conn) error {
+	for {
+		// 1) Read the type byte ourselves so we can find the handler first.
+		var typ uint8
+		err := binary.Read(conn, binary.BigEndian, &typ)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		// 2) No handler: skip the value and tell the peer.
+		h, ok := rt.handler(typ)
+		if !ok {
+			if err = discardValue(conn); err != nil {
+				return err
+			}
+			if err = reply(conn, nil, fmt.Errorf("no handler for type %d", typ)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// 3) Decode the rest of the frame and call the handler.
+		// 	- A handler for a type the registry doesn't know is treated like a missing handler.
+		request, err := DefaultRegistry.decodeType(typ, conn)
+		if errors.Is(err, ErrUnknownType) {
+			if err = discardValue(conn); err != nil {
+				return err
+			}
+			err = reply(conn, nil, fmt.Errorf("unknown type %d", typ))
+		} else if err == nil {
+			response, hErr := h(request)
+			err = reply(conn, response, hErr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// reply writes response to w, or a ProtocolError frame if err is not nil.
+func reply(w io.Writer, response Payload, err error) error {
+	if err != nil {
+		pErr := ProtocolError(err.Error())
+		response = &pErr
+	}
+	if response == nil {
+		return nil
+	}
+
+	_, err = response.WriteTo(w)
+	return err
+}
+
+// discardValue reads the 4-byte length that follows a type byte and throws the value away.
+func discardValue(r io.Reader) error {
+	var size uint32
+	err := binary.Read(r, binary.BigEndian, &size) // 4-byte size
+	if err != nil {
+		return err
+	}
+
+	if size > MaxPayloadSize {
+		return ErrMaxPayloadSize
+	}
+
+	_, err = io.CopyN(io.Discard, r, int64(size))
+	return err
+}
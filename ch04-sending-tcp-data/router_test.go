@@ -0,0 +1,82 @@
+package ch04
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// This test registers handlers for Binary and Ping frames:
+//   - a Binary request is answered with the upper-cased Binary
+//   - a Ping request is answered with a Pong carrying the same bytes
+//   - a String request has no handler, so the reply is a ProtocolError frame
+//     and the following frame is still routed correctly
+func TestRouter(t *testing.T) {
+	router := NewRouter()
+	router.Handle(BinaryType, func(p Payload) (Payload, error) {
+		b := Binary(bytes.ToUpper(p.Bytes()))
+		return &b, nil
+	})
+	router.Handle(PingType, func(p Payload) (Payload, error) {
+		pong := Pong(p.Bytes())
+		return &pong, nil
+	})
+
+	server, client := net.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- router.ServeConn(server)
+		_ = server.Close()
+	}()
+
+	b := Binary("don't panic.")
+	ping := Ping("1")
+	s := String("no handler")
+	upper := Binary("DON'T PANIC.")
+	pong := Pong("1")
+
+	tests := []struct {
+		request  Payload
+		expected Payload
+	}{
+		{&b, &upper},
+		{&ping, &pong},
+		{&s, nil}, // ProtocolError
+		{&ping, &pong},
+	}
+
+	for i, tc := range tests {
+		go func() {
+			if _, err := tc.request.WriteTo(client); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		actual, err := Decode(client)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tc.expected == nil {
+			pErr, ok := actual.(*ProtocolError)
+			if !ok {
+				t.Fatalf("%d: expected *ProtocolError; actual: %T", i, actual)
+			}
+			if !strings.Contains(pErr.String(), "no handler") {
+				t.Errorf("%d: unexpected error message %q", i, pErr)
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(tc.expected, actual) {
+			t.Errorf("%d: value mismatch: %v != %v", i, tc.expected, actual)
+		}
+	}
+
+	_ = client.Close()
+	if err := <-done; err != nil {
+		t.Errorf("expected nil error after EOF; actual: %v", err)
+	}
+}
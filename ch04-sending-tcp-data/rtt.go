@@ -0,0 +1,63 @@
+package ch04
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ## Measuring the Round-Trip Time
+// Probe answers "is the peer alive?"; a health dashboard also wants "how fast does it answer?".
+// RTT sends a Ping and measures the time until the matching Pong comes back:
+//	- The Ping carries the send time (Unix nanoseconds). The peer echoes it in its Pong (the Ping/Pong convention),
+//	  so a late Pong from an earlier Ping (with another timestamp) isn't mistaken for ours.
+//	- The time itself is measured with the local monotonic clock, not from the echoed timestamp:
+//	  the wall clock may jump between sending and receiving.
+//	- The measurement includes the peer's processing time: it's what a caller of the peer's application sees,
+//	  not just the network.
+//	- Other frames may arrive while we wait (the peer was sending something anyway).
+//	  By default RTT skips them; with FailOnData it returns ErrUnexpectedPayload instead,
+//	  for connections where skipping would lose data someone else needs.
+//	- The whole exchange shares one deadline: timeout. The connection's deadlines are cleared afterwards.
+
+// RTTMeter measures round-trip times. The zero value skips unrelated frames.
+type RTTMeter struct {
+	// FailOnData makes RTT fail with ErrUnexpectedPayload when a frame other than the matching Pong arrives.
+	FailOnData bool
+}
+
+// RTT measures the round-trip time of a Ping on conn with a zero RTTMeter.
+func RTT(conn net.Conn, timeout time.Duration) (time.Duration, error) {
+	var m RTTMeter
+	return m.RTT(conn, timeout)
+}
+
+// RTT sends a Ping on conn and returns the time until the Pong echoing it arrives, waiting at most timeout.
+func (m *RTTMeter) RTT(conn net.Conn, timeout time.Duration) (time.Duration, error) {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return 0, err
+	}
+	defer func() { _ = conn.SetDeadline(time.Time{}) }()
+
+	start := time.Now()
+	ping := Ping(binary.BigEndian.AppendUint64(nil, uint64(start.UnixNano())))
+	if _, err := ping.WriteTo(conn); err != nil {
+		return 0, err
+	}
+
+	for {
+		p, err := Decode(conn)
+		if err != nil {
+			return 0, err
+		}
+
+		if pong, ok := p.(*Pong); ok && bytes.Equal(*pong, ping) {
+			return time.Since(start), nil
+		}
+		if m.FailOnData {
+			return 0, fmt.Errorf("%w: expected the matching *Pong, got %T", ErrUnexpectedPayload, p)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ch04
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// respond answers every Ping on conn with a matching Pong after delay, sending noise first.
+func respond(conn net.Conn, delay time.Duration, noise ...Payload) {
+	for {
+		p, err := Decode(conn)
+		if err != nil {
+			return
+		}
+		ping, ok := p.(*Ping)
+		if !ok {
+			continue
+		}
+
+		for _, n := range noise {
+			if _, err = n.WriteTo(conn); err != nil {
+				return
+			}
+		}
+		time.Sleep(delay) // processing time
+		if _, err = Pong(*ping).WriteTo(conn); err != nil {
+			return
+		}
+	}
+}
+
+func TestRTT(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	noise := []Payload{ptr(Binary("unrelated")), ptr(Pong("a stale pong"))}
+
+	t.Run("skips other frames", func(t *testing.T) {
+		client, server := tcpPair(t)
+		go respond(server, delay, noise...)
+
+		rtt, err := RTT(client, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rtt < delay {
+			t.Errorf("expected an RTT of at least %s; actual: %s", delay, rtt)
+		}
+		t.Logf("RTT: %s", rtt)
+	})
+
+	t.Run("fails on data", func(t *testing.T) {
+		client, server := tcpPair(t)
+		go respond(server, delay, noise...)
+
+		m := RTTMeter{FailOnData: true}
+		if _, err := m.RTT(client, time.Second); !errors.Is(err, ErrUnexpectedPayload) {
+			t.Errorf("expected ErrUnexpectedPayload; actual: %v", err)
+		}
+	})
+
+	t.Run("silent peer", func(t *testing.T) {
+		client, _ := tcpPair(t)
+
+		if _, err := RTT(client, delay); !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("expected a time-out; actual: %v", err)
+		}
+	})
+}
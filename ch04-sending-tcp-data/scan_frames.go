@@ -0,0 +1,54 @@
+package ch04
+
+import "encoding/binary"
+
+// ## Scanning TLV Frames with bufio.Scanner
+// In Listing 4-3 `bufio.Scanner` split the stream into words with `bufio.ScanWords`.
+// A Scanner can split on anything: you only have to give it a `bufio.SplitFunc`.
+//	- The Scanner calls the split function with the bytes it has buffered so far (data) and atEOF.
+//	- The function answers with one of three things:
+//		- "I need more data": return 0, nil, nil → the Scanner reads more from the connection and calls again
+//		- "Here is a token": return how many bytes to consume and the token itself
+//		- "This stream is broken": return an error → Scan stops and Err returns it
+//	- For TLV the answer is easy to compute:
+//		- fewer than 5 bytes → we can't even read the header → need more data
+//		- header complete → the length tells us exactly how big the frame is
+//		- fewer than 5+length bytes → need more data (the frame spans several reads)
+//		- otherwise → the first 5+length bytes are one frame
+//	- The length is checked against MaxPayloadSize BEFORE asking for more data.
+//	  Otherwise a peer could declare 4GB and the Scanner would keep growing its buffer.
+//
+// NOTE:
+//	- The Scanner never buffers more than its maximum token size (64KB by default).
+//	- Frames larger than that fail with `bufio.ErrTooLong`, so raise the limit with
+//	  `scanner.Buffer(buf, 5+int(MaxPayloadSize))` if you expect big payloads.
+//	- The token is the raw frame (header included); pass it to Decode through a `bytes.Reader`.
+//	  It is only valid until the next call to Scan.
+//	- A stream that ends inside a frame fails with ErrTruncatedFrame, like Decode (see frame.go).
+
+const tlvHeaderSize = 5 // 1-byte type + 4-byte length
+
+// ScanFrames is a `bufio.SplitFunc` that returns each complete TLV frame as a token.
+func ScanFrames(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if len(data) < tlvHeaderSize {
+		if atEOF && len(data) > 0 {
+			return 0, nil, ErrTruncatedFrame // the stream ended inside a header
+		}
+		return 0, nil, nil // need more data (or clean EOF)
+	}
+
+	size := binary.BigEndian.Uint32(data[1:tlvHeaderSize])
+	if size > MaxPayloadSize {
+		return 0, nil, ErrMaxPayloadSize
+	}
+
+	frameSize := tlvHeaderSize + int(size)
+	if len(data) < frameSize {
+		if atEOF {
+			return 0, nil, ErrTruncatedFrame // the stream ended inside a value
+		}
+		return 0, nil, nil // the frame spans several reads
+	}
+
+	return frameSize, data[:frameSize], nil
+}
@@ -0,0 +1,128 @@
+package ch04
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// The server writes several back-to-back frames of very different sizes.
+// The largest one is bigger than the Scanner's default buffer and certainly spans several reads.
+func TestScanFrames(t *testing.T) {
+	sizes := []int{1, 100, 70_000, 3, 1 << 20}
+
+	var payloads []Payload
+	for i, size := range sizes {
+		b := Binary(bytes.Repeat([]byte{byte('a' + i)}, size))
+		payloads = append(payloads, &b)
+	}
+	s := String("Errors are values.")
+	payloads = append(payloads, &s)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		for _, p := range payloads {
+			if _, err := p.WriteTo(conn); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), tlvHeaderSize+int(MaxPayloadSize))
+	scanner.Split(ScanFrames)
+
+	i := 0
+	for scanner.Scan() {
+		if i >= len(payloads) {
+			t.Fatalf("unexpected extra frame")
+		}
+
+		actual, err := Decode(bytes.NewReader(scanner.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(actual.Bytes(), payloads[i].Bytes()) {
+			t.Errorf("%d: frame of %d bytes doesn't match", i, len(actual.Bytes()))
+		}
+		i++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if i != len(payloads) {
+		t.Errorf("expected %d frames; actual: %d", len(payloads), i)
+	}
+}
+
+// A declared length above MaxPayloadSize stops the Scanner before it buffers anything.
+func TestScanFramesMaxPayloadSize(t *testing.T) {
+	header := []byte{BinaryType, 0x40, 0, 0, 0} // 1GB
+	scanner := bufio.NewScanner(io.MultiReader(bytes.NewReader(header), neverEnding{}))
+	scanner.Split(ScanFrames)
+
+	if scanner.Scan() {
+		t.Fatal("expected Scan to fail")
+	}
+	if err := scanner.Err(); !errors.Is(err, ErrMaxPayloadSize) {
+		t.Errorf("expected ErrMaxPayloadSize; actual: %v", err)
+	}
+}
+
+// A stream that ends inside a frame fails with ErrTruncatedFrame; one that ends between frames doesn't fail.
+func TestScanFramesTruncated(t *testing.T) {
+	var stream bytes.Buffer
+	if _, err := Binary("hello").WriteTo(&stream); err != nil {
+		t.Fatal(err)
+	}
+	frame := stream.Bytes()
+
+	for _, cut := range []int{0, 2, tlvHeaderSize + 2, len(frame)} {
+		scanner := bufio.NewScanner(bytes.NewReader(frame[:cut]))
+		scanner.Split(ScanFrames)
+		for scanner.Scan() {
+		}
+
+		err := scanner.Err()
+		switch cut {
+		case 0, len(frame):
+			if err != nil {
+				t.Errorf("cut at %d: expected a clean end; actual: %v", cut, err)
+			}
+		default:
+			if !errors.Is(err, ErrTruncatedFrame) || !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("cut at %d: expected ErrTruncatedFrame; actual: %v", cut, err)
+			}
+		}
+	}
+}
+
+// neverEnding is an endless source of zero bytes.
+type neverEnding struct{}
+
+func (neverEnding) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,167 @@
+package ch04
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// tempFile creates a file of size random bytes and returns it together with its contents.
+func tempFile(t *testing.T, size int) (*os.File, []byte) {
+	t.Helper()
+
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(t.TempDir(), "payload")
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+
+	return f, data
+}
+
+// tcpPair returns both ends of a TCP connection.
+func tcpPair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+
+	return client, server
+}
+
+func TestSendFile(t *testing.T) {
+	f, data := tempFile(t, 4<<20)
+	client, server := tcpPair(t)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		n, err := SendFile(client, f)
+		if err != nil {
+			t.Error(err)
+		}
+		if expected := int64(tlvHeaderSize + len(data)); n != expected {
+			t.Errorf("expected %d bytes written; actual: %d", expected, n)
+		}
+	}()
+
+	p, err := Decode(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	actual, ok := p.(*Binary)
+	if !ok {
+		t.Fatalf("expected *Binary; actual: %T", p)
+	}
+	if !bytes.Equal(*actual, data) {
+		t.Error("received bytes don't match the file")
+	}
+}
+
+// SendFile streams the file, so it must allocate far less than the file's size.
+func TestSendFileAllocations(t *testing.T) {
+	const size = 8 << 20
+	f, _ := tempFile(t, size)
+	client, server := tcpPair(t)
+
+	go func() { _, _ = io.Copy(io.Discard, server) }()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := SendFile(client, f); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/8 {
+		t.Errorf("SendFile allocated %d bytes for a %d-byte file", allocated, size)
+	}
+}
+
+func TestSendFileMaxPayloadSize(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "huge")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// A sparse file: large according to Stat, but it takes no disk space.
+	if err = f.Truncate(int64(MaxPayloadSize) + 1); err != nil {
+		t.Fatal(err)
+	}
+
+	client, _ := tcpPair(t)
+	n, err := SendFile(client, f)
+	if !errors.Is(err, ErrMaxPayloadSize) {
+		t.Errorf("expected ErrMaxPayloadSize; actual: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("expected nothing written; actual: %d bytes", n)
+	}
+}
+
+// shrinkConn truncates f to half its size right after the frame header has been written.
+type shrinkConn struct {
+	net.Conn
+	f *os.File
+}
+
+func (c shrinkConn) Write(p []byte) (int, error) {
+	if info, err := c.f.Stat(); err == nil && len(p) == tlvHeaderSize {
+		if err = os.Truncate(c.f.Name(), info.Size()/2); err != nil {
+			return 0, err
+		}
+	}
+	return c.Conn.Write(p)
+}
+
+// A file that shrinks after its size went out in the header fails with ErrTruncatedFrame, not a bare io.EOF.
+func TestSendFileShrinks(t *testing.T) {
+	f, data := tempFile(t, 64<<10)
+	client, server := tcpPair(t)
+	go func() { _, _ = io.Copy(io.Discard, server) }()
+
+	n, err := SendFile(shrinkConn{Conn: client, f: f}, f)
+	if !errors.Is(err, ErrTruncatedFrame) {
+		t.Fatalf("expected ErrTruncatedFrame; actual: %v", err)
+	}
+	if expected := int64(tlvHeaderSize + len(data)/2); n != expected {
+		t.Errorf("expected %d bytes written; actual: %d", expected, n)
+	}
+}
@@ -0,0 +1,374 @@
+package ch04
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Reza-1988/network-programming-with-go/ch03-tcp-conn-go-stdlib"
+)
+
+// ## A Reusable Accept Loop
+// EchoServer (and the listeners in chapter 3) all repeat the same loop: Accept, start a goroutine, handle the connection.
+// Server is that loop in one place, so features like shutdown or throttling are written once and every handler gets them.
+//	- Serve accepts connections from a listener and calls Handler for each one in its own goroutine.
+//	- The connection is closed when Handler returns.
+//	- HandlerContext is the same with a context: it is canceled by Shutdown, when the connection reaches MaxConnAge,
+//	  and when the handler returns. A handler blocked in a long operation can select on ctx.Done() to give up in time.
+//	- Shutdown stops accepting, waits for the handlers to return, and closes whatever is still open
+//	  once its context is done. After that, Serve returns ErrServerClosed.
+//
+// ### Throttling accepts under pressure
+// When the process is overloaded (memory, CPU, a slow backend), accepting even more connections makes things worse.
+//	- ShouldThrottle is asked before every Accept. While it reports true, Serve sleeps ThrottleDelay first.
+//	- Connections are not rejected: they wait in the kernel's accept queue, so the accept RATE drops
+//	  and the server gets time to recover.
+//
+// ### Rotating old connections
+//	- With MaxConnAge set, Handler gets a connection that half-closes itself when it gets too old (see conn_age.go).
+//
+// ### Recognizing the protocol
+//	- With RequirePreamble set, a connection must start with Preamble (see preamble.go); other connections are closed
+//	  without ever reaching Handler.
+//
+// ### Announcing the shutdown
+//	- With SendGoAway set, Shutdown first writes a GoAway frame on every open connection (see goaway.go),
+//	  so clients know to reconnect elsewhere instead of guessing from a closed connection.
+//
+// ### Instrumentation
+//	- The embedded ch03.ConnHooks report every connection's start and end (see conn_hooks.go in chapter 3).
+//
+// ### Limiting connections per client
+// One client opening hundreds of connections can use up the server's file descriptors and goroutines alone.
+//	- With MaxConnsPerIP set, the Server counts the open connections per remote IP (from conn.RemoteAddr).
+//	- A connection that would go over the limit is closed right after Accept, without reaching Handler.
+//	- The count goes down when a connection's handler is done and the connection is closed.
+//	- Clients behind one NAT share an IP: set the limit with that in mind.
+//
+// ### Surviving a buggy listener
+// A listener wrapper with a bug may return (nil, nil) from Accept. Calling Handler with a nil conn would panic
+// in its first Read, far away from the bug, and take the whole server down.
+//	- Serve logs the nil conn as a warning on Logger and accepts the next connection, as if nothing was accepted.
+
+// ErrServerClosed is returned by Serve after Shutdown.
+var ErrServerClosed = errors.New("server closed")
+
+// preambleTimeout limits the wait for a connection's preamble.
+const preambleTimeout = 5 * time.Second
+
+// defaultThrottleDelay is used when ShouldThrottle is set but ThrottleDelay isn't.
+const defaultThrottleDelay = 50 * time.Millisecond
+
+// Server accepts connections and hands each one to Handler.
+//   - Set the fields before calling Serve and don't change them afterwards.
+type Server struct {
+	// Handler serves one connection. The connection is closed when it returns.
+	Handler func(conn net.Conn)
+	// HandlerContext, if set, is used instead of Handler. ctx is canceled on Shutdown and when the connection
+	// reaches MaxConnAge.
+	HandlerContext func(ctx context.Context, conn net.Conn)
+
+	// ShouldThrottle, if set, is called before every Accept. While it returns true,
+	// Serve waits ThrottleDelay before accepting the next connection.
+	ShouldThrottle func() bool
+	// ThrottleDelay is the pause per accept while throttled. Zero means 50ms.
+	ThrottleDelay time.Duration
+
+	// MaxConnAge, if set, limits how long a connection is kept: once it is reached, the connection is
+	// half-closed at the next frame boundary and closed shortly after (see conn_age.go).
+	MaxConnAge time.Duration
+
+	// RequirePreamble makes the Server read Preamble from every connection before calling Handler.
+	// Connections without it (or without it within preambleTimeout) are closed.
+	RequirePreamble bool
+
+	// SendGoAway makes Shutdown write a GoAway frame on every open connection before waiting for the handlers.
+	// The handlers must speak TLV: the GoAway is written between their frames.
+	SendGoAway bool
+
+	// MaxConnsPerIP, if set, is the most connections one remote IP may have open; more are closed at once.
+	MaxConnsPerIP int
+
+	// Logger receives the problems Serve survives, like a nil connection from Accept; nil means slog.Default().
+	Logger *slog.Logger
+
+	// ConnHooks, if set, are attached to every accepted connection:
+	// OnConnect runs before Handler, OnClose after the connection is closed.
+	ch03.ConnHooks
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]*goAwayConn // the value is nil unless SendGoAway is set
+	perIP     map[string]int           // open connections per remote IP, with MaxConnsPerIP
+	ctx       context.Context          // canceled by Shutdown
+	cancel    context.CancelFunc
+	handlers  sync.WaitGroup
+}
+
+// Serve accepts connections on listener until Shutdown is called or Accept fails.
+//   - It closes listener before returning.
+//   - After Shutdown, Serve returns ErrServerClosed.
+func (s *Server) Serve(listener net.Listener) error {
+	if !s.track(listener) {
+		_ = listener.Close()
+		return ErrServerClosed
+	}
+	defer s.untrack(listener)
+	defer listener.Close()
+
+	for {
+		s.throttle()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if conn == nil {
+			s.logger().Warn("accept returned a nil connection without an error", "listener", listener.Addr())
+			continue
+		}
+
+		s.handle(conn)
+	}
+}
+
+// logger returns Logger, or the default logger.
+func (s *Server) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+// throttle sleeps while the server is under pressure, unless it shuts down meanwhile.
+func (s *Server) throttle() {
+	if s.ShouldThrottle == nil || !s.ShouldThrottle() {
+		return
+	}
+
+	delay := s.ThrottleDelay
+	if delay <= 0 {
+		delay = defaultThrottleDelay
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-s.doneChan():
+	}
+}
+
+// handle runs Handler for conn in its own goroutine and closes conn afterwards.
+func (s *Server) handle(conn net.Conn) {
+	ip := remoteIP(conn)
+
+	s.mu.Lock()
+	if s.isClosed() || !s.admitLocked(ip) {
+		s.mu.Unlock()
+		_ = conn.Close()
+		return
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]*goAwayConn)
+	}
+	s.conns[conn] = nil
+	s.handlers.Add(1)
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(s.context())
+	handlerConn := s.Attach(conn)
+	if s.MaxConnAge > 0 {
+		handlerConn = newAgeConn(handlerConn, s.MaxConnAge, cancel) // keep the hooks: closing it must still call OnClose
+	}
+	var missedGoAway bool
+	if s.SendGoAway {
+		ga := &goAwayConn{Conn: handlerConn}
+		handlerConn = ga
+
+		s.mu.Lock()
+		s.conns[conn] = ga
+		missedGoAway = s.isClosed() // Shutdown came before ga was registered
+		s.mu.Unlock()
+	}
+
+	go func() {
+		defer s.handlers.Done()
+		defer cancel()
+		defer func() {
+			_ = handlerConn.Close()
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.releaseLocked(ip)
+			s.mu.Unlock()
+		}()
+
+		if missedGoAway {
+			_ = handlerConn.(*goAwayConn).goAway(goAwayReason)
+		}
+		if s.RequirePreamble && !readPreamble(handlerConn) {
+			return
+		}
+		switch {
+		case s.HandlerContext != nil:
+			s.HandlerContext(ctx, handlerConn)
+		case s.Handler != nil:
+			s.Handler(handlerConn)
+		}
+	}()
+}
+
+// admitLocked counts a new connection from ip, unless ip already has MaxConnsPerIP. s.mu must be held.
+func (s *Server) admitLocked(ip string) bool {
+	if s.MaxConnsPerIP <= 0 {
+		return true
+	}
+	if s.perIP[ip] >= s.MaxConnsPerIP {
+		return false
+	}
+
+	if s.perIP == nil {
+		s.perIP = make(map[string]int)
+	}
+	s.perIP[ip]++
+	return true
+}
+
+// releaseLocked uncounts a closed connection from ip. s.mu must be held.
+func (s *Server) releaseLocked(ip string) {
+	if s.MaxConnsPerIP <= 0 {
+		return
+	}
+
+	if s.perIP[ip]--; s.perIP[ip] <= 0 {
+		delete(s.perIP, ip) // don't keep an entry for every IP ever seen
+	}
+}
+
+// remoteIP returns the IP part of conn's remote address, or the whole address if it has no port.
+func remoteIP(conn net.Conn) string {
+	addr := conn.RemoteAddr()
+	if addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		return host
+	}
+	return addr.String()
+}
+
+// readPreamble reads conn's preamble within preambleTimeout and reports whether it was correct.
+func readPreamble(conn net.Conn) bool {
+	if err := conn.SetReadDeadline(time.Now().Add(preambleTimeout)); err != nil {
+		return false
+	}
+	if err := ReadPreamble(conn); err != nil {
+		return false
+	}
+
+	return conn.SetReadDeadline(time.Time{}) == nil
+}
+
+// Shutdown stops all Serve loops and waits for the handlers to return.
+//   - With SendGoAway set, every open connection gets a GoAway frame first.
+//   - When ctx is done first, the remaining connections are closed, Shutdown still waits
+//     for their handlers and then returns ctx.Err().
+func (s *Server) Shutdown(ctx context.Context) error {
+	var goAways sync.WaitGroup
+	s.mu.Lock()
+	s.contextLocked()
+	s.cancel()
+	for l := range s.listeners {
+		_ = l.Close()
+	}
+	for _, ga := range s.conns {
+		if ga != nil {
+			// A client that doesn't read may block the write; closing the connection at ctx.Done ends it.
+			goAways.Go(func() { _ = ga.goAway(goAwayReason) })
+		}
+	}
+	s.mu.Unlock()
+	defer goAways.Wait()
+
+	idle := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(idle)
+	}()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			_ = conn.Close()
+		}
+		s.mu.Unlock()
+		<-idle
+		return ctx.Err()
+	}
+}
+
+// track registers a listener; it returns false after Shutdown.
+func (s *Server) track(listener net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isClosed() {
+		return false
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+	}
+	s.listeners[listener] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(listener net.Listener) {
+	s.mu.Lock()
+	delete(s.listeners, listener)
+	s.mu.Unlock()
+}
+
+func (s *Server) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isClosed()
+}
+
+// doneChan returns the channel closed by Shutdown.
+func (s *Server) doneChan() <-chan struct{} {
+	return s.context().Done()
+}
+
+// context returns the context canceled by Shutdown.
+func (s *Server) context() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.contextLocked()
+}
+
+// contextLocked is context with s.mu held.
+func (s *Server) contextLocked() context.Context {
+	if s.ctx == nil {
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+	}
+	return s.ctx
+}
+
+// isClosed reports whether Shutdown was called. s.mu must be held.
+func (s *Server) isClosed() bool {
+	select {
+	case <-s.contextLocked().Done():
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,433 @@
+package ch04
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startServer serves s on a local listener and shuts it down when the test ends.
+func startServer(t *testing.T, s *Server) net.Addr {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(listener) }()
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = s.Shutdown(ctx)
+		if err := <-served; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("expected ErrServerClosed from Serve; actual: %v", err)
+		}
+	})
+
+	return listener.Addr()
+}
+
+func TestServerShutdown(t *testing.T) {
+	handling := make(chan struct{})
+	s := &Server{Handler: func(conn net.Conn) {
+		close(handling)
+		_, _ = conn.Read(make([]byte, 1)) // blocks until the connection is closed
+	}}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(listener) }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	<-handling
+
+	// The handler never returns on its own, so Shutdown must give up on ctx and close the connection.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err = s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded; actual: %v", err)
+	}
+	if err = <-served; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("expected ErrServerClosed; actual: %v", err)
+	}
+
+	if err = s.Serve(listener); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("expected ErrServerClosed after Shutdown; actual: %v", err)
+	}
+}
+
+// acceptedWithin dials n connections and returns how many of them the server handled within d.
+func acceptedWithin(t *testing.T, addr net.Addr, handled *atomic.Int32, n int, d time.Duration) int32 {
+	t.Helper()
+
+	before := handled.Load()
+	for i := 0; i < n; i++ {
+		conn, err := net.Dial("tcp", addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+	}
+	time.Sleep(d)
+
+	return handled.Load() - before
+}
+
+// While ShouldThrottle reports true, the server accepts at most one connection per ThrottleDelay.
+func TestServerThrottle(t *testing.T) {
+	var throttled atomic.Bool
+	var handled atomic.Int32
+
+	s := &Server{
+		Handler:        func(net.Conn) { handled.Add(1) },
+		ShouldThrottle: throttled.Load,
+		ThrottleDelay:  100 * time.Millisecond,
+	}
+	addr := startServer(t, s)
+
+	if n := acceptedWithin(t, addr, &handled, 10, 300*time.Millisecond); n != 10 {
+		t.Fatalf("expected all 10 connections accepted without throttling; actual: %d", n)
+	}
+
+	throttled.Store(true)
+	time.Sleep(150 * time.Millisecond) // let the pending throttle check pick up the toggle
+
+	// 300ms at one accept per 100ms: about 3 connections, certainly not all 10.
+	n := acceptedWithin(t, addr, &handled, 10, 300*time.Millisecond)
+	if n > 5 {
+		t.Fatalf("expected at most 5 connections accepted while throttled; actual: %d", n)
+	}
+	t.Logf("accepted %d of 10 connections in 300ms while throttled", n)
+
+	// Turning throttling off lets the backlog drain.
+	throttled.Store(false)
+	time.Sleep(300 * time.Millisecond)
+	if total := handled.Load(); total != 20 {
+		t.Fatalf("expected all 20 connections handled eventually; actual: %d", total)
+	}
+}
+
+func TestServerConnHooks(t *testing.T) {
+	var connects atomic.Int32
+	closed := make(chan error, 2)
+
+	s := &Server{
+		Handler: func(conn net.Conn) {
+			// Echo one frame and return: a clean close.
+			if p, err := Decode(conn); err == nil {
+				_, _ = p.WriteTo(conn)
+			}
+		},
+	}
+	s.OnConnect = func(net.Conn) { connects.Add(1) }
+	s.OnClose = func(_ net.Conn, err error) { closed <- err }
+	addr := startServer(t, s)
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err = String("hello").WriteTo(conn); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Decode(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err = <-closed:
+		if err != nil {
+			t.Errorf("expected a nil terminal error; actual: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnClose wasn't called")
+	}
+	if n := connects.Load(); n != 1 {
+		t.Errorf("expected OnConnect once; actual: %d", n)
+	}
+	select {
+	case err = <-closed:
+		t.Errorf("OnClose called twice (%v)", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// The connection wrapped for MaxConnAge must still report its close to the hooks.
+func TestServerConnHooksMaxConnAge(t *testing.T) {
+	var connects atomic.Int32
+	closed := make(chan error, 2)
+
+	s := &Server{
+		MaxConnAge: time.Minute,
+		Handler: func(conn net.Conn) {
+			if p, err := Decode(conn); err == nil {
+				_, _ = p.WriteTo(conn)
+			}
+		},
+	}
+	s.OnConnect = func(net.Conn) { connects.Add(1) }
+	s.OnClose = func(_ net.Conn, err error) { closed <- err }
+	addr := startServer(t, s)
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err = String("hello").WriteTo(conn); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Decode(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err = <-closed:
+		if err != nil {
+			t.Errorf("expected a nil terminal error; actual: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnClose wasn't called")
+	}
+	if n := connects.Load(); n != 1 {
+		t.Errorf("expected OnConnect once; actual: %d", n)
+	}
+	select {
+	case err = <-closed:
+		t.Errorf("OnClose called twice (%v)", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// A handler blocked on ctx.Done() is released by Shutdown, and by MaxConnAge.
+func TestServerHandlerContext(t *testing.T) {
+	newServer := func(maxAge time.Duration) (*Server, chan struct{}, chan error) {
+		started := make(chan struct{})
+		released := make(chan error, 1)
+		return &Server{
+			MaxConnAge: maxAge,
+			HandlerContext: func(ctx context.Context, _ net.Conn) {
+				close(started)
+				<-ctx.Done()
+				released <- ctx.Err()
+			},
+		}, started, released
+	}
+
+	t.Run("Shutdown", func(t *testing.T) {
+		s, started, released := newServer(0)
+		conn, err := net.Dial("tcp", startServer(t, s).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err = s.Shutdown(ctx); err != nil {
+			t.Fatalf("expected the handler to return before the deadline; actual: %v", err)
+		}
+
+		select {
+		case err = <-released:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled; actual: %v", err)
+			}
+		default:
+			t.Fatal("the handler wasn't released by Shutdown")
+		}
+	})
+
+	t.Run("MaxConnAge", func(t *testing.T) {
+		s, _, released := newServer(50 * time.Millisecond)
+		conn, err := net.Dial("tcp", startServer(t, s).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		select {
+		case <-released:
+		case <-time.After(2 * time.Second):
+			t.Fatal("the handler wasn't released at MaxConnAge")
+		}
+	})
+}
+
+// nilOnceListener returns (nil, nil) from its first Accept, then behaves like the listener it wraps.
+type nilOnceListener struct {
+	net.Listener
+	once sync.Once
+}
+
+func (l *nilOnceListener) Accept() (net.Conn, error) {
+	nilConn := false
+	l.once.Do(func() { nilConn = true })
+	if nilConn {
+		return nil, nil
+	}
+	return l.Listener.Accept()
+}
+
+// A nil connection from Accept is logged and skipped; the next, real connection is handled.
+func TestServerNilConn(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	handled := make(chan struct{})
+	s := &Server{
+		Handler: func(conn net.Conn) { close(handled) },
+		Logger:  slog.New(slog.NewTextHandler(&logs, nil)),
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(&nilOnceListener{Listener: listener}) }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	select {
+	case <-handled:
+	case err := <-served:
+		t.Fatalf("Serve stopped: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("the real connection was never handled")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = s.Shutdown(ctx)
+	if err := <-served; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("expected ErrServerClosed; actual: %v", err)
+	}
+	if !strings.Contains(logs.String(), "nil connection") {
+		t.Errorf("expected the nil connection to be logged; actual log: %q", logs.String())
+	}
+}
+
+// fixedAddrListener makes every accepted connection report the same remote address,
+// as if all of them came from one client.
+type fixedAddrListener struct {
+	net.Listener
+	addr net.Addr
+}
+
+func (l *fixedAddrListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &fixedAddrConn{Conn: conn, addr: l.addr}, nil
+}
+
+type fixedAddrConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c *fixedAddrConn) RemoteAddr() net.Addr { return c.addr }
+
+// With MaxConnsPerIP=2, a third connection from the same IP is closed at once;
+// after one of the first two is gone, the IP may connect again.
+func TestServerMaxConnsPerIP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 4242}
+
+	var handled atomic.Int32
+	s := &Server{
+		MaxConnsPerIP: 2,
+		Handler: func(conn net.Conn) {
+			handled.Add(1)
+			_, _ = conn.Read(make([]byte, 1)) // until the client closes
+		},
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(&fixedAddrListener{Listener: listener, addr: client}) }()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = s.Shutdown(ctx)
+		<-served
+	}()
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	// closedByServer reports whether the server closed conn right away.
+	closedByServer := func(conn net.Conn) bool {
+		_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		_, err := conn.Read(make([]byte, 1))
+		return errors.Is(err, io.EOF)
+	}
+
+	first, second := dial(), dial()
+	defer second.Close()
+	waitFor(t, func() bool { return handled.Load() == 2 })
+
+	third := dial()
+	defer third.Close()
+	if !closedByServer(third) {
+		t.Fatal("expected the third connection from the same IP to be closed")
+	}
+	if n := handled.Load(); n != 2 {
+		t.Errorf("expected 2 handled connections; actual: %d", n)
+	}
+
+	// Closing one connection frees a slot.
+	_ = first.Close()
+	waitFor(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.perIP[client.IP.String()] == 1
+	})
+	fourth := dial()
+	defer fourth.Close()
+	waitFor(t, func() bool { return handled.Load() == 3 })
+}
+
+// waitFor polls cond for up to a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within a second")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,92 @@
+package ch04
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// writeFrameSteps is the three-Write implementation of Listing 4-5, kept to compare the fast path against.
+func writeFrameSteps(w io.Writer, typ uint8, value []byte) error {
+	if err := binary.Write(w, binary.BigEndian, typ); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(len(value))); err != nil {
+		return err
+	}
+	_, err := w.Write(value)
+	return err
+}
+
+func TestSmallFrameIdentical(t *testing.T) {
+	for _, size := range []int{0, 1, 10, smallFrameMax, smallFrameMax + 1, 64 << 10} {
+		value := bytes.Repeat([]byte{0xAB}, size)
+
+		var expected bytes.Buffer
+		if err := writeFrameSteps(&expected, BinaryType, value); err != nil {
+			t.Fatal(err)
+		}
+
+		var fast bytes.Buffer
+		if _, err := Binary(value).WriteTo(&fast); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(fast.Bytes(), expected.Bytes()) {
+			t.Errorf("%d-byte Binary: frames differ", size)
+		}
+
+		var str bytes.Buffer
+		if _, err := String(value).WriteTo(&str); err != nil {
+			t.Fatal(err)
+		}
+		expected.Bytes()[0] = StringType
+		if !bytes.Equal(str.Bytes(), expected.Bytes()) {
+			t.Errorf("%d-byte String: frames differ", size)
+		}
+	}
+}
+
+func TestSmallFrameSingleWrite(t *testing.T) {
+	tiny := Binary("tiny")
+	w := &countingWriter{}
+	if _, err := tiny.WriteTo(w); err != nil {
+		t.Fatal(err)
+	}
+	if w.writes != 1 {
+		t.Errorf("expected 1 Write for a small frame; actual: %d", w.writes)
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { _, _ = tiny.WriteTo(io.Discard) }); allocs != 0 {
+		t.Errorf("expected no allocations for a small frame; actual: %.1f", allocs)
+	}
+}
+
+// BenchmarkSmallFrames writes 10000 small Binary frames per iteration.
+// The writes/op metric stands in for system calls on a real connection.
+func BenchmarkSmallFrames(b *testing.B) {
+	const frames = 10000
+	value := Binary("a small request")
+
+	b.Run("steps", func(b *testing.B) {
+		b.ReportAllocs()
+		w := &countingWriter{}
+		for b.Loop() {
+			for range frames {
+				_ = writeFrameSteps(w, BinaryType, value)
+			}
+		}
+		b.ReportMetric(float64(w.writes)/float64(b.N), "writes/op")
+	})
+
+	b.Run("fast path", func(b *testing.B) {
+		b.ReportAllocs()
+		w := &countingWriter{}
+		for b.Loop() {
+			for range frames {
+				_, _ = value.WriteTo(w)
+			}
+		}
+		b.ReportMetric(float64(w.writes)/float64(b.N), "writes/op")
+	})
+}
@@ -0,0 +1,48 @@
+package ch04
+
+import (
+	"net"
+	"sync"
+)
+
+// ## Writing Frames from Many Goroutines
+// A payload's WriteTo makes several Write calls (type, length, value). When two goroutines write payloads
+// to the same connection, those calls interleave: one frame's header ends up in the middle of another frame's value,
+// and the receiver decodes garbage from then on.
+//	- SyncConn puts a mutex around the write side:
+//		- WritePayload writes a whole frame while holding it, so every frame is written in one piece
+//		- Write holds it for a single call, so raw writes can't cut into a frame either
+//	- Use WritePayload, not p.WriteTo(syncConn): WriteTo would take the lock once per Write call,
+//	  and another goroutine could still slip in between the header and the value.
+//	- Read is NOT synchronized. A TLV stream can only be decoded by one reader at a time anyway,
+//	  so use a single reading goroutine (for example a read loop that dispatches the payloads).
+
+// SyncConn is a net.Conn whose writes are serialized.
+type SyncConn struct {
+	net.Conn
+	writeMu sync.Mutex
+}
+
+// NewSyncConn wraps conn.
+func NewSyncConn(conn net.Conn) *SyncConn {
+	return &SyncConn{Conn: conn}
+}
+
+// NetConn returns the wrapped connection.
+func (c *SyncConn) NetConn() net.Conn { return c.Conn }
+
+// Write writes p without being interleaved with other writes.
+func (c *SyncConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.Conn.Write(p)
+}
+
+// WritePayload writes the complete frame of p without being interleaved with other writes.
+func (c *SyncConn) WritePayload(p Payload) (int64, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return p.WriteTo(c.Conn)
+}
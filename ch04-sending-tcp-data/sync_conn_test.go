@@ -0,0 +1,45 @@
+package ch04
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestSyncConnConcurrentFrames(t *testing.T) {
+	const senders = 20
+
+	client, server := tcpPair(t)
+	conn := NewSyncConn(client)
+
+	// Each sender has a distinct value of a distinct size: frame i is 1000+i copies of byte i.
+	var wg sync.WaitGroup
+	for i := range senders {
+		wg.Go(func() {
+			p := Binary(bytes.Repeat([]byte{byte(i)}, 1000+i))
+			if _, err := conn.WritePayload(&p); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	seen := make(map[int]bool)
+	for range senders {
+		p, err := Decode(server)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value := p.Bytes()
+		i := int(value[0])
+		if len(value) != 1000+i || !bytes.Equal(value, bytes.Repeat([]byte{byte(i)}, len(value))) {
+			t.Fatalf("frame of sender %d is corrupt: %d bytes", i, len(value))
+		}
+		if seen[i] {
+			t.Fatalf("frame of sender %d received twice", i)
+		}
+		seen[i] = true
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,61 @@
+package ch04
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// ## Transforming Values Before They Become Payloads
+// Some links wrap every value: encrypted, then compressed, or base64-encoded for a text-only channel.
+// The payload types shouldn't know about that. TransformDecoder undoes the wrapping between the wire and the payload:
+//	1. Read one frame as is (type byte and value), like Raw.
+//	2. Run the value through the transforms, in order: the output of one is the input of the next
+//	   (decrypt, then decompress).
+//	3. Check the final value against MaxPayloadSize: a small compressed frame may expand to gigabytes,
+//	   and this is where such a value is refused (ErrMaxPayloadSize), before a payload is built from it.
+//	4. Build the registered payload for the type byte from the final value, exactly like Decode would.
+//	- A transform error stops Decode; it is wrapped with the transform's position, so the caller knows which step failed.
+//	- The type byte is never transformed: it still selects the payload type.
+
+// Transform turns a frame's value as read from the wire into the value its payload is built from.
+type Transform func(value []byte) ([]byte, error)
+
+// TransformDecoder decodes frames whose values went through a series of transforms.
+//   - It is not safe for concurrent use.
+type TransformDecoder struct {
+	r          io.Reader
+	transforms []Transform
+}
+
+// NewTransformDecoder returns a TransformDecoder reading from r and applying transforms in order.
+func NewTransformDecoder(r io.Reader, transforms ...Transform) *TransformDecoder {
+	return &TransformDecoder{r: r, transforms: transforms}
+}
+
+// Decode reads the next frame, transforms its value and returns it as the registered payload type.
+func (d *TransformDecoder) Decode() (Payload, error) {
+	var raw Raw
+	if _, err := raw.ReadFrom(d.r); err != nil {
+		return nil, err
+	}
+
+	value := raw.Value
+	for i, transform := range d.transforms {
+		var err error
+		if value, err = transform(value); err != nil {
+			return nil, fmt.Errorf("transform %d: %w", i, err)
+		}
+	}
+	if len(value) > int(MaxPayloadSize) {
+		return nil, ErrMaxPayloadSize
+	}
+
+	// The payloads read frames, so the final value becomes a frame again.
+	var frame bytes.Buffer
+	if _, err := writeTLV(&frame, raw.Type, value); err != nil {
+		return nil, err
+	}
+
+	return Decode(&frame)
+}
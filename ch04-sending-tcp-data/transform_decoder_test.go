@@ -0,0 +1,61 @@
+package ch04
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestTransformDecoder(t *testing.T) {
+	base64Decode := func(value []byte) ([]byte, error) {
+		out := make([]byte, base64.StdEncoding.DecodedLen(len(value)))
+		n, err := base64.StdEncoding.Decode(out, value)
+		return out[:n], err
+	}
+	upper := func(value []byte) ([]byte, error) { return bytes.ToUpper(value), nil }
+
+	var stream bytes.Buffer
+	encoded := String(base64.StdEncoding.EncodeToString([]byte("hello, transforms")))
+	if _, err := encoded.WriteTo(&stream); err != nil {
+		t.Fatal(err)
+	}
+	bad := String("not base64!")
+	if _, err := bad.WriteTo(&stream); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := NewTransformDecoder(&stream, base64Decode, upper)
+
+	p, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := p.(*String)
+	if !ok {
+		t.Fatalf("expected *String; actual: %T", p)
+	}
+	if expected := "HELLO, TRANSFORMS"; string(*s) != expected {
+		t.Errorf("expected %q; actual: %q", expected, *s)
+	}
+
+	var corrupt base64.CorruptInputError
+	if _, err = dec.Decode(); !errors.As(err, &corrupt) {
+		t.Errorf("expected the base64 error; actual: %v", err)
+	}
+}
+
+// A transform that expands the value beyond MaxPayloadSize is refused.
+func TestTransformDecoderMaxPayloadSize(t *testing.T) {
+	expand := func([]byte) ([]byte, error) { return make([]byte, MaxPayloadSize+1), nil }
+
+	var stream bytes.Buffer
+	small := Binary("tiny")
+	if _, err := small.WriteTo(&stream); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewTransformDecoder(&stream, expand).Decode(); !errors.Is(err, ErrMaxPayloadSize) {
+		t.Errorf("expected ErrMaxPayloadSize; actual: %v", err)
+	}
+}
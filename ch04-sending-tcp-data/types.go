@@ -208,6 +208,13 @@ func (m Binary) String() string { return string(m) } // (3)
 
 func (m Binary) WriteTo(w io.Writer) (int64, error) { // (4)
 
+	// 4.0) Small values take the fast path (small_frame.go): the same bytes, in a single Write.
+	// 		- The steps below are what it does, one Write at a time.
+
+	if len(m) <= smallFrameMax {
+		return writeSmallFrame(w, BinaryType, m)
+	}
+
 	// 4.1) Write Type (1 byte)
 	// 		- What does it do here?
 	// 			- `BinaryType` is a uint8 number (e.g. 1)
@@ -305,7 +312,7 @@ func (m *Binary) ReadFrom(r io.Reader) (int64, error) {
 	var size uint32
 	err = binary.Read(r, binary.BigEndian, &size) // 4-byte size
 	if err != nil {
-		return n, err
+		return n, truncated(err) // the type byte was read: we are inside a frame (see frame.go)
 	}
 
 	// So far:
@@ -339,14 +346,16 @@ func (m *Binary) ReadFrom(r io.Reader) (int64, error) {
 	// 	- Now it should read the actual size of bytes from the network and put it into `*m`
 	// 	- o means:
 	// 		- How many bytes were actually read
+	// 	- A single `r.Read` may return fewer than size bytes (a large payload arrives in many TCP segments),
+	// 	  so `io.ReadFull` keeps reading until *m is full or the reader fails.
 
-	o, err := r.Read(*m) // payload
+	o, err := io.ReadFull(r, *m) // payload
 
 	// And finally:
 	// 	- `n` (header = 5 bytes) +
 	// 	- `o` (payload) that we read
 
-	return n + int64(o), err
+	return n + int64(o), truncated(err)
 }
 
 // Listing 4-7  Creating the String type
@@ -384,6 +393,12 @@ func (m String) String() string { return string(m) } // (2)
 
 func (m String) WriteTo(w io.Writer) (int64, error) { // (3)
 
+	// 4.0) Short strings take the fast path (small_frame.go), exactly like Binary.
+
+	if len(m) <= smallFrameMax {
+		return writeSmallFrame(w, StringType, m)
+	}
+
 	// 4.1) Write Type (here StringType)
 	// 	- Since this message is of type “String”, the first byte should be `StringType` (e.g. 2)
 	// 	- So the receiver understands: “I should interpret this payload as text”
@@ -463,19 +478,25 @@ func (m *String) ReadFrom(r io.Reader) (int64, error) {
 	var size uint32
 	err = binary.Read(r, binary.BigEndian, &size) // 4-byte size
 	if err != nil {
-		return 0, err
+		return 0, truncated(err) // the type byte was read: we are inside a frame (see frame.go)
 	}
 	n += 4 // So far, the entire header has been read: 1 + 4 = 5 bytes.
 
+	// Security check (like Binary)
+	// 	- Without it, a peer could send a huge length and make us allocate that much memory before a single value byte arrives.
+	if size > MaxPayloadSize {
+		return n, ErrMaxPayloadSize
+	}
+
 	// 4) Create a buffer the size of the payload and read the payload
 	// 	- Creates a slice of size
 	// 	- Reads payload into it
 	// 	- `o` means how many bytes were actually read
 
 	buf := make([]byte, size)
-	o, err := r.Read(buf) // payload
+	o, err := io.ReadFull(r, buf) // payload
 	if err != nil {
-		return n, err
+		return n + int64(o), truncated(err) // count the bytes that did arrive (see PartialReadError)
 	}
 
 	// 5) Convert payload to String and store in `m`
@@ -486,11 +507,8 @@ func (m *String) ReadFrom(r io.Reader) (int64, error) {
 	return n + int64(o), nil // Total number of bytes read = 5 (header) + `o` (payload)
 
 	// An important point (like Binary)
-	// 	- Here again as before:
-	// 		- `o, err := r.Read(buf)`
-	// 		- It does not guarantee to read all size bytes at once.
-	// 		- In the network it is better to use `io.ReadFull` to read exactly the full size bytes.
-	// 	- But to understand TLV, this simple model is shown.
+	// 	- A plain `r.Read(buf)` does not guarantee to read all size bytes at once.
+	// 	- In the network we must use `io.ReadFull` to read exactly the full size bytes.
 }
 
 // Listing 4-9: Decoding bytes from a reader into a Binary or String type
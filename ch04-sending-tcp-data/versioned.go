@@ -0,0 +1,56 @@
+package ch04
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ## Versioning the Wire Format
+// Once two programs talk TLV, changing the format is risky: an old peer will happily misread a new frame.
+//	- A version byte in front of each frame lets the receiver detect the mismatch instead of misreading the data.
+//	- The versioned frame looks like this:
+//		- [Version:1 byte][Type:1 byte][Length:4 bytes][Value:Length bytes]
+//	- The unversioned framing (WriteTo / Decode) stays exactly as it is,
+//	  so both sides must agree on which of the two they speak.
+//	- When the reader sees a version it doesn't know, it stops with ErrUnsupportedVersion
+//	  and does not touch the rest of the frame.
+//	- The version byte belongs to the frame: a stream that ends right after it fails with ErrTruncatedFrame,
+//	  while io.EOF still means the stream ended cleanly before the version byte.
+
+// ProtocolVersion is the version written in front of every versioned frame.
+const ProtocolVersion uint8 = 1
+
+// ErrUnsupportedVersion is returned when a versioned frame carries an unknown version.
+var ErrUnsupportedVersion = errors.New("unsupported protocol version")
+
+// WriteVersioned writes the ProtocolVersion byte followed by the TLV frame of p.
+func WriteVersioned(w io.Writer, p Payload) (int64, error) {
+	err := binary.Write(w, binary.BigEndian, ProtocolVersion) // 1-byte version
+	if err != nil {
+		return 0, err
+	}
+	var n int64 = 1
+
+	o, err := p.WriteTo(w) // type, length, value
+	return n + o, err
+}
+
+// ReadVersioned reads a version byte, checks it and decodes the TLV frame that follows.
+func ReadVersioned(r io.Reader) (Payload, error) {
+	var version uint8
+	err := binary.Read(r, binary.BigEndian, &version) // 1-byte version
+	if err != nil {
+		return nil, err
+	}
+
+	if version != ProtocolVersion {
+		return nil, ErrUnsupportedVersion
+	}
+
+	p, err := Decode(r)
+	if err != nil {
+		return nil, truncated(err) // the version byte was read: we are inside a frame
+	}
+	return p, nil
+}
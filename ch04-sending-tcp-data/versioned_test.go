@@ -0,0 +1,57 @@
+package ch04
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestVersionedRoundTrip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	expected := String("Errors are values.")
+
+	n, err := WriteVersioned(buf, &expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("expected %d bytes written; actual: %d", buf.Len(), n)
+	}
+	if v := buf.Bytes()[0]; v != ProtocolVersion {
+		t.Errorf("expected version %d first; actual: %d", ProtocolVersion, v)
+	}
+
+	actual, err := ReadVersioned(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&expected, actual) {
+		t.Errorf("value mismatch: %v != %v", &expected, actual)
+	}
+}
+
+func TestVersionedUnknownVersion(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p := Binary("Don't panic.")
+
+	if _, err := WriteVersioned(buf, &p); err != nil {
+		t.Fatal(err)
+	}
+	buf.Bytes()[0] = ProtocolVersion + 1 // a peer from the future
+
+	_, err := ReadVersioned(buf)
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("expected ErrUnsupportedVersion; actual: %v", err)
+	}
+}
+
+func TestVersionedTruncated(t *testing.T) {
+	if _, err := ReadVersioned(bytes.NewReader(nil)); err != io.EOF {
+		t.Errorf("expected io.EOF before the version byte; actual: %v", err)
+	}
+	if _, err := ReadVersioned(bytes.NewReader([]byte{ProtocolVersion})); !errors.Is(err, ErrTruncatedFrame) {
+		t.Errorf("expected ErrTruncatedFrame right after the version byte; actual: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package ch04
+
+import (
+	"net"
+	"time"
+)
+
+// ## Writing Everything Before a Deadline
+// When a write deadline fires in the middle of a large Write, part of the data may already be on its way:
+// Write returns n > 0 together with the time-out error.
+//	- Retrying with the whole buffer again would send those n bytes twice and corrupt the TLV stream.
+//	- WriteAllDeadline keeps track of the offset and only ever writes what hasn't been accepted yet.
+//	- By itself it gives up at the first time-out and reports how many bytes made it.
+//	- WriteAllExtending is the opt-in variant for slow links: every time a write made progress before timing out,
+//	  the deadline is pushed forward by extend and the rest is written. A peer that accepts nothing at all within
+//	  extend still fails, so a dead link can't keep us forever.
+
+// WriteAllDeadline writes data to conn under deadline and returns the number of bytes written.
+//   - On a time-out it returns the bytes written so far and the time-out error; they are never resent.
+func WriteAllDeadline(conn net.Conn, data []byte, deadline time.Time) (int, error) {
+	return WriteAllExtending(conn, data, deadline, 0)
+}
+
+// WriteAllExtending is WriteAllDeadline that, after each time-out with progress, sets the deadline to now + extend and continues.
+//   - extend <= 0 disables extending, which is exactly WriteAllDeadline.
+func WriteAllExtending(conn net.Conn, data []byte, deadline time.Time, extend time.Duration) (int, error) {
+	var written int
+	for {
+		if err := conn.SetWriteDeadline(deadline); err != nil {
+			return written, err
+		}
+
+		n, err := conn.Write(data[written:]) // always from the last offset
+		written += n
+		if err == nil {
+			return written, nil
+		}
+
+		if extend <= 0 || n == 0 || !isTimeout(err) {
+			return written, err
+		}
+		deadline = time.Now().Add(extend)
+	}
+}
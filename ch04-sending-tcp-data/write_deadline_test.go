@@ -0,0 +1,98 @@
+package ch04
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowConn accepts chunk bytes every delay and honors the write deadline, like a congested link.
+type slowConn struct {
+	net.Conn // only Write and SetWriteDeadline are implemented
+
+	chunk int
+	delay time.Duration
+
+	mu       sync.Mutex
+	deadline time.Time
+	received bytes.Buffer
+	writes   int
+}
+
+func (c *slowConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *slowConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writes++
+	var n int
+	for n < len(p) {
+		if !c.deadline.IsZero() && time.Now().Add(c.delay).After(c.deadline) {
+			return n, os.ErrDeadlineExceeded
+		}
+		time.Sleep(c.delay)
+
+		end := min(n+c.chunk, len(p))
+		c.received.Write(p[n:end])
+		n = end
+	}
+
+	return n, nil
+}
+
+func TestWriteAllDeadline(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100) // 1000 bytes, 10 chunks of 100
+
+	t.Run("time-out reports the offset", func(t *testing.T) {
+		conn := &slowConn{chunk: 100, delay: 20 * time.Millisecond}
+
+		n, err := WriteAllDeadline(conn, data, time.Now().Add(90*time.Millisecond))
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("expected a time-out; actual: %v", err)
+		}
+		if n == 0 || n == len(data) {
+			t.Fatalf("expected a partial write; actual: %d bytes", n)
+		}
+		if !bytes.Equal(conn.received.Bytes(), data[:n]) {
+			t.Errorf("reported %d bytes, peer received %d", n, conn.received.Len())
+		}
+	})
+
+	t.Run("extending resumes from the offset", func(t *testing.T) {
+		conn := &slowConn{chunk: 100, delay: 20 * time.Millisecond}
+
+		n, err := WriteAllExtending(conn, data, time.Now().Add(90*time.Millisecond), 90*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(data) {
+			t.Fatalf("expected %d bytes written; actual: %d", len(data), n)
+		}
+		// Nothing was sent twice.
+		if !bytes.Equal(conn.received.Bytes(), data) {
+			t.Fatalf("peer received %d bytes that don't match the data", conn.received.Len())
+		}
+		if conn.writes < 2 {
+			t.Errorf("expected the deadline to be extended at least once; writes: %d", conn.writes)
+		}
+	})
+
+	t.Run("no progress gives up", func(t *testing.T) {
+		conn := &slowConn{chunk: 100, delay: 200 * time.Millisecond}
+
+		n, err := WriteAllExtending(conn, data, time.Now().Add(50*time.Millisecond), time.Second)
+		if !errors.Is(err, os.ErrDeadlineExceeded) || n != 0 {
+			t.Fatalf("expected a time-out with 0 bytes; actual: %d bytes, %v", n, err)
+		}
+	})
+}